@@ -0,0 +1,540 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/awnumar/memguard"
+)
+
+/*
+
+Agent
+
+Similar to ssh-agent, the agent keeps the master keys of unlocked
+journals in locked memory (memguard) and encrypts and decrypts
+entries for other invocations of journal, so the password only has
+to be entered (and the key derived) once:
+
+  journal agent start [path...]   unlocks the journals, then runs the agent in the background
+  journal agent add <path>        unlocks a journal and passes its key to the agent
+  journal agent list|lock|stop
+
+The master keys never leave the agent once added. Operations that
+need the master key itself (changing passwords, key slots, enabling
+sealed entries) still require the password. After the idle timeout,
+i.e. when no key was used for that long, the agent removes all keys.
+
+The agent listens on a Unix socket with mode 0600, at
+$JOURNAL_AGENT_SOCK, $XDG_RUNTIME_DIR/journal-agent.sock or
+<tmp>/journal-agent-<uid>.sock. Both sides check that the other
+one is run by the same user (SO_PEERCRED).
+
+Requests and responses are messages of the following format:
+
+  type (u8), number of fields (u16), fields
+
+  field: length (u32), value
+
+The response to a request is agentMsg_Ok with the resulting fields,
+or agentMsg_Error with the error message.
+
+The fields of a message are limited to 64 MiB in total, so larger
+entries can't be encrypted or decrypted by the agent.
+
+*/
+
+var AgentAlreadyRunning = errors.New("An agent is already running!")
+var AgentNotStarted = errors.New("The agent didn't start!")
+var AgentJournalLocked = errors.New("The journal isn't unlocked in the agent!")
+var AgentPeerNotAllowed = errors.New("The agent socket is used by another user!")
+var InvalidAgentMessage = errors.New("Invalid agent message!")
+var MasterKeyUnavailable = errors.New("The journal was unlocked by the agent, this needs the password!")
+
+const DefaultAgentTimeout = 15 * time.Minute
+const agentStartTimeout = 5 * time.Second
+
+const agentMaxFields = 1024
+const agentMaxMessageSize = 64 * 1024 * 1024 // sum of the field lengths
+const agentMaxEntrySize = agentMaxMessageSize - 64 * 1024 // leaves room for the other fields
+
+const (
+	agentMsg_Ok = uint8(0)
+	agentMsg_Error = uint8(1)    // error message
+	agentMsg_Add = uint8(2)      // journal id, path, master key
+	agentMsg_Encrypt = uint8(3)  // journal id, text, timestamp, associated data -> ciphertext, salt, nonce prefix
+	agentMsg_Decrypt = uint8(4)  // journal id, ciphertext, salt, nonce prefix, timestamp, associated data -> text
+	agentMsg_Open = uint8(5)     // like agentMsg_Decrypt for sealed entries, with the public key and wrapped private key after the journal id
+	agentMsg_List = uint8(6)     // -> journal id, path, journal id, path, ...
+	agentMsg_Lock = uint8(7)
+	agentMsg_Stop = uint8(8)
+	agentMsg_Mac = uint8(9)      // journal id, header data -> header MAC (see HeaderMac)
+)
+
+// errors that are recognized by the client
+var agentErrors = []error{AgentJournalLocked, InvalidAgentMessage, CorruptedJournalHeader, EntryTooLarge}
+
+func AgentSocket() string {
+	if s := os.Getenv("JOURNAL_AGENT_SOCK"); s != "" { return s }
+	if d := os.Getenv("XDG_RUNTIME_DIR"); d != "" { return filepath.Join(d, "journal-agent.sock") }
+	return filepath.Join(os.TempDir(), fmt.Sprintf("journal-agent-%v.sock", os.Getuid()))
+}
+
+func writeAgentMessage(w io.Writer, t uint8, fields ...[]byte) error {
+	b := []byte{t}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(fields)))
+	for _, f := range fields {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(f)))
+		b = append(b, f...)
+	}
+	_, err := w.Write(b)
+	clear(b) // may contain a key or text
+	return err
+}
+
+func readAgentMessage(r io.Reader) (uint8, [][]byte, error) {
+	h := make([]byte, 3)
+	_, err := io.ReadFull(r, h)
+	if err != nil { return 0, nil, err }
+	n := int(binary.BigEndian.Uint16(h[1:]))
+	if n > agentMaxFields { return 0, nil, InvalidAgentMessage }
+	fields := make([][]byte, n)
+	l := make([]byte, 4)
+	size := uint64(0)
+	for i := range fields {
+		_, err = io.ReadFull(r, l)
+		if err != nil { return 0, nil, err }
+		size += uint64(binary.BigEndian.Uint32(l))
+		if size > agentMaxMessageSize { return 0, nil, InvalidAgentMessage }
+		fields[i] = make([]byte, binary.BigEndian.Uint32(l))
+		_, err = io.ReadFull(r, fields[i])
+		if err != nil { return 0, nil, err }
+	}
+	return h[0], fields, nil
+}
+
+func checkAgentPeer(c net.Conn) error {
+	// the other side must be run by the same user
+	uc, ok := c.(*net.UnixConn)
+	if !ok { return AgentPeerNotAllowed }
+	raw, err := uc.SyscallConn()
+	if err != nil { return err }
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil { return err }
+	if credErr != nil { return credErr }
+	if int(cred.Uid) != os.Getuid() { return AgentPeerNotAllowed }
+	return nil
+}
+
+// agent (server)
+
+type Agent struct {
+	Timeout time.Duration // 0 means never
+	journals map[[16]byte]*agentJournal
+	mu sync.Mutex
+	idleTimer *time.Timer
+	listener net.Listener
+}
+
+type agentJournal struct {
+	path string
+	key *memguard.Enclave // master key
+	publicKey []byte
+	privateKey *memguard.Enclave // unwrapped when the first sealed entry is opened
+}
+
+func NewAgent(timeout time.Duration) *Agent {
+	return &Agent{Timeout: timeout, journals: map[[16]byte]*agentJournal{}}
+}
+
+func (a *Agent) AddJournal(j *JournalFile) error {
+	if j.key == nil { return MasterKeyUnavailable }
+	path, err := filepath.Abs(j.Filepath)
+	if err != nil { return err }
+	a.mu.Lock()
+	a.journals[j.Header.Id] = &agentJournal{path: path, key: j.key}
+	a.mu.Unlock()
+	a.touch()
+	return nil
+}
+
+func (a *Agent) Lock() {
+	// removes all keys
+	a.mu.Lock()
+	a.journals = map[[16]byte]*agentJournal{}
+	a.mu.Unlock()
+}
+
+func (a *Agent) touch() {
+	// restarts the idle timer
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.Timeout <= 0 { return }
+	if a.idleTimer == nil {
+		a.idleTimer = time.AfterFunc(a.Timeout, a.Lock)
+	} else {
+		a.idleTimer.Reset(a.Timeout)
+	}
+}
+
+func (a *Agent) journal(id []byte) (*agentJournal, error) {
+	if len(id) != 16 { return nil, InvalidAgentMessage }
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	aj := a.journals[[16]byte(id)]
+	if aj == nil { return nil, AgentJournalLocked }
+	return aj, nil
+}
+
+func (a *Agent) Serve(socket string) error {
+	// Listens on the socket until the agent is stopped.
+	// A stale socket from an agent that didn't exit cleanly is replaced.
+	if c, err := net.Dial("unix", socket); err == nil {
+		c.Close()
+		return AgentAlreadyRunning
+	}
+	if fi, err := os.Lstat(socket); err == nil && fi.Mode() & os.ModeSocket != 0 {
+		os.Remove(socket)
+	}
+	umask := syscall.Umask(0177)
+	l, err := net.Listen("unix", socket)
+	syscall.Umask(umask)
+	if err != nil { return err }
+	a.mu.Lock()
+	a.listener = l
+	a.mu.Unlock()
+	for {
+		c, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) { return nil }
+		if err != nil { return err }
+		go a.serveConn(c)
+	}
+}
+
+func (a *Agent) Stop() {
+	a.Lock()
+	a.mu.Lock()
+	if a.listener != nil { a.listener.Close() }
+	a.mu.Unlock()
+}
+
+func StartAgentProcess(socket string, timeout time.Duration) (*AgentClient, int, error) {
+	// Runs the agent in a new process, detached from the terminal,
+	// and connects to it. Returns the client and the PID of the agent.
+	if c, err := net.Dial("unix", socket); err == nil {
+		c.Close()
+		return nil, 0, AgentAlreadyRunning
+	}
+	exe, err := os.Executable()
+	if err != nil { return nil, 0, err }
+	cmd := exec.Command(exe, "agent", "--foreground", "--timeout", timeout.String(), "start")
+	cmd.Env = append(os.Environ(), "JOURNAL_AGENT_SOCK=" + socket)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true} // no controlling terminal
+	err = cmd.Start()
+	if err != nil { return nil, 0, err }
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	deadline := time.Now().Add(agentStartTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-exited:
+			return nil, 0, AgentNotStarted
+		case <-time.After(20 * time.Millisecond):
+		}
+		a, err := ConnectAgent(socket)
+		if err == nil { return a, cmd.Process.Pid, nil }
+	}
+	cmd.Process.Kill()
+	return nil, 0, AgentNotStarted
+}
+
+func (a *Agent) serveConn(c net.Conn) {
+	defer c.Close()
+	if checkAgentPeer(c) != nil { return }
+	for {
+		t, fields, err := readAgentMessage(c)
+		if err != nil { return }
+		res, err := a.handleRequest(t, fields)
+		if err != nil {
+			err = writeAgentMessage(c, agentMsg_Error, []byte(err.Error()))
+		} else {
+			err = writeAgentMessage(c, agentMsg_Ok, res...)
+		}
+		if err != nil { return }
+		if t == agentMsg_Stop { a.Stop(); return } // after the response was sent
+	}
+}
+
+func (a *Agent) handleRequest(t uint8, fs [][]byte) ([][]byte, error) {
+	switch t {
+	case agentMsg_Add:
+		if len(fs) != 3 || len(fs[0]) != 16 || len(fs[2]) != MasterKeyLength { return nil, InvalidAgentMessage }
+		a.mu.Lock()
+		a.journals[[16]byte(fs[0])] = &agentJournal{path: string(fs[1]), key: memguard.NewEnclave(fs[2])} // wipes fs[2]
+		a.mu.Unlock()
+		a.touch()
+		return nil, nil
+	case agentMsg_Encrypt:
+		if len(fs) != 4 || len(fs[2]) != 8 { return nil, InvalidAgentMessage }
+		aj, err := a.journal(fs[0])
+		if err != nil { return nil, err }
+		a.touch()
+		ct, salt, noncePfx, err := EncryptText(aj.key, string(fs[1]), binary.BigEndian.Uint64(fs[2]), fs[3])
+		if err != nil { return nil, err }
+		return [][]byte{ct, salt[:], noncePfx[:]}, nil
+	case agentMsg_Decrypt, agentMsg_Open:
+		if t == agentMsg_Open {
+			if len(fs) != 8 { return nil, InvalidAgentMessage }
+		} else if len(fs) != 6 {
+			return nil, InvalidAgentMessage
+		}
+		aj, err := a.journal(fs[0])
+		if err != nil { return nil, err }
+		a.touch()
+		key := aj.key
+		if t == agentMsg_Open {
+			key, err = a.privateKey(fs[0], aj, fs[1], fs[2])
+			if err != nil { return nil, err }
+			fs = append(fs[:1], fs[3:]...)
+		}
+		if len(fs[2]) != 12 || len(fs[3]) != 16 || len(fs[4]) != 8 { return nil, InvalidAgentMessage }
+		ts := binary.BigEndian.Uint64(fs[4])
+		var txt string
+		if t == agentMsg_Open {
+			txt, err = OpenSealedText(key, fs[1], [12]byte(fs[2]), [16]byte(fs[3]), ts, fs[5])
+		} else {
+			txt, err = DecryptText(key, fs[1], [12]byte(fs[2]), [16]byte(fs[3]), ts, fs[5])
+		}
+		if err != nil { return nil, err }
+		return [][]byte{[]byte(txt)}, nil
+	case agentMsg_Mac:
+		if len(fs) != 2 { return nil, InvalidAgentMessage }
+		aj, err := a.journal(fs[0])
+		if err != nil { return nil, err }
+		a.touch()
+		mac, err := HeaderMac(aj.key, fs[1])
+		if err != nil { return nil, err }
+		return [][]byte{mac}, nil
+	case agentMsg_List:
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		res := [][]byte{}
+		for id, aj := range a.journals {
+			res = append(res, id[:], []byte(aj.path))
+		}
+		return res, nil
+	case agentMsg_Lock:
+		a.Lock()
+		return nil, nil
+	case agentMsg_Stop:
+		return nil, nil
+	}
+	return nil, InvalidAgentMessage
+}
+
+func (a *Agent) privateKey(id []byte, aj *agentJournal, publicKey []byte, wrapped []byte) (*memguard.Enclave, error) {
+	// unwraps the private key using the master key, see unlockPrivateKey()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if aj.privateKey != nil && slices.Equal(aj.publicKey, publicKey) { return aj.privateKey, nil }
+	priv, err := UnwrapPrivateKey(aj.key, wrapped, privateKeyAssociatedData([16]byte(id), publicKey))
+	if err != nil { return nil, CorruptedJournalHeader }
+	if !PublicKeyMatches(priv, publicKey) { return nil, CorruptedJournalHeader }
+	aj.publicKey = publicKey
+	aj.privateKey = priv
+	return priv, nil
+}
+
+// client
+
+type AgentClient struct {
+	socket string
+	conns chan net.Conn // idle connections, entries are decrypted in parallel
+}
+
+type AgentJournal struct {
+	Id [16]byte
+	Path string
+}
+
+func ConnectAgent(socket string) (*AgentClient, error) {
+	a := &AgentClient{socket: socket, conns: make(chan net.Conn, runtime.NumCPU())}
+	c, err := a.dial()
+	if err != nil { return nil, err }
+	a.conns <- c
+	return a, nil
+}
+
+func (a *AgentClient) dial() (net.Conn, error) {
+	c, err := net.Dial("unix", a.socket)
+	if err != nil { return nil, err }
+	err = checkAgentPeer(c)
+	if err != nil { c.Close(); return nil, err }
+	return c, nil
+}
+
+func (a *AgentClient) Close() {
+	for {
+		select {
+		case c := <-a.conns:
+			c.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (a *AgentClient) request(t uint8, fields ...[]byte) ([][]byte, error) {
+	var c net.Conn
+	select {
+	case c = <-a.conns:
+	default:
+		var err error
+		c, err = a.dial()
+		if err != nil { return nil, err }
+	}
+	rt, res, err := uint8(0), [][]byte(nil), writeAgentMessage(c, t, fields...)
+	if err == nil { rt, res, err = readAgentMessage(c) }
+	if err != nil { c.Close(); return nil, err }
+	select {
+	case a.conns <- c:
+	default:
+		c.Close()
+	}
+	switch rt {
+	case agentMsg_Ok:
+		return res, nil
+	case agentMsg_Error:
+		if len(res) != 1 { return nil, InvalidAgentMessage }
+		for _, e := range agentErrors {
+			if e.Error() == string(res[0]) { return nil, e }
+		}
+		return nil, errors.New(string(res[0]))
+	}
+	return nil, InvalidAgentMessage
+}
+
+func (a *AgentClient) Add(j *JournalFile) error {
+	// passes the master key of the journal to the agent
+	if j.key == nil { return MasterKeyUnavailable }
+	path, err := filepath.Abs(j.Filepath)
+	if err != nil { return err }
+	lb, err := j.key.Open()
+	if err != nil { return err }
+	defer lb.Destroy()
+	_, err = a.request(agentMsg_Add, j.Header.Id[:], []byte(path), lb.Bytes())
+	return err
+}
+
+func (a *AgentClient) List() ([]AgentJournal, error) {
+	res, err := a.request(agentMsg_List)
+	if err != nil { return nil, err }
+	if len(res) % 2 != 0 { return nil, InvalidAgentMessage }
+	js := []AgentJournal{}
+	for i := 0; i < len(res); i += 2 {
+		if len(res[i]) != 16 { return nil, InvalidAgentMessage }
+		js = append(js, AgentJournal{[16]byte(res[i]), string(res[i+1])})
+	}
+	slices.SortFunc(js, func(x AgentJournal, y AgentJournal) int {
+		if x.Path < y.Path { return -1 }
+		if x.Path > y.Path { return 1 }
+		return 0
+	})
+	return js, nil
+}
+
+func (a *AgentClient) Lock() error {
+	_, err := a.request(agentMsg_Lock)
+	return err
+}
+
+func (a *AgentClient) Stop() error {
+	_, err := a.request(agentMsg_Stop)
+	return err
+}
+
+func (a *AgentClient) encryptEntry(id [16]byte, e *EncryptedEntry, text string, ad []byte) error {
+	if len(text) > agentMaxEntrySize { return EntryTooLarge }
+	res, err := a.request(agentMsg_Encrypt, id[:], []byte(text), binary.BigEndian.AppendUint64(nil, e.Timestamp), ad)
+	if err != nil { return err }
+	if len(res) != 3 || len(res[1]) != 12 || len(res[2]) != 16 { return InvalidAgentMessage }
+	e.EncryptedText = res[0]
+	e.Salt = [12]byte(res[1])
+	e.NoncePfx = [16]byte(res[2])
+	return nil
+}
+
+func (a *AgentClient) decryptEntry(h *JournalHeader, e *EncryptedEntry, ad []byte) (string, error) {
+	if len(e.EncryptedText) > agentMaxEntrySize { return "", EntryTooLarge }
+	fields := [][]byte{h.Id[:]}
+	t := agentMsg_Decrypt
+	if e.Kind == EntryKind_Sealed {
+		t = agentMsg_Open
+		fields = append(fields, h.PublicKey, h.WrappedPrivateKey)
+	}
+	fields = append(fields, e.EncryptedText, e.Salt[:], e.NoncePfx[:], binary.BigEndian.AppendUint64(nil, e.Timestamp), ad)
+	res, err := a.request(t, fields...)
+	if err != nil { return "", err }
+	if len(res) != 1 { return "", InvalidAgentMessage }
+	return string(res[0]), nil
+}
+
+func (a *AgentClient) headerMac(id [16]byte, data []byte) ([]byte, error) {
+	res, err := a.request(agentMsg_Mac, id[:], data)
+	if err != nil { return nil, err }
+	if len(res) != 1 || len(res[0]) != HeaderMacLength { return nil, InvalidAgentMessage }
+	return res[0], nil
+}
+
+func OpenJournalFileWithAgent(file string, agent *AgentClient, readOnly bool) (*JournalFile, error) {
+	// Opens an existing journal that was unlocked in the agent,
+	// all entries are encrypted and decrypted by the agent.
+	// If readOnly is true, the journal is not locked (see lock.go).
+	j := JournalFile{}
+	j.Filepath = file
+	j.keySlot = -1
+	j.readOnly = readOnly
+	fileinfo, err := os.Stat(j.Filepath)
+	if err != nil { return &j, err }
+	if fileinfo.IsDir() { return &j, FilepathIsDirectory }
+	if !readOnly {
+		j.lock, err = LockJournalFile(file)
+		if err != nil { return &j, err }
+	}
+	err = j.openWithAgent(agent)
+	if err != nil && j.lock != nil {
+		j.lock.Unlock()
+		j.lock = nil
+	}
+	return &j, err
+}
+
+func (j *JournalFile) openWithAgent(agent *AgentClient) error {
+	err := j.read(); if err != nil { return err }
+	j.agent = agent
+	// check if the agent has the master key
+	e0 := j.GetEntry(0)
+	if e0 == nil { return CorruptedJournalFile }
+	_, err = j.decryptEntry(e0)
+	if err != nil { return err }
+	err = j.checkHeaderMac(&j.Header)
+	if err != nil { return err }
+	return j.loadSearchIndex()
+}
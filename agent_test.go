@@ -0,0 +1,106 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestAgent(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+	j, err := OpenJournalFile(JournalTestFile, passwd)
+	if err != nil { t.Fatal("Could not create test journal; ", err) }
+	j.EnableSealing()
+	j.Write()
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	agent := NewAgent(0)
+	served := make(chan error)
+	go func() { served <- agent.Serve(socket) }()
+	var c *AgentClient
+	for range 50 {
+		c, err = ConnectAgent(socket)
+		if err == nil { break }
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil { t.Fatal("Could not connect to agent; ", err) }
+	defer c.Close()
+	t.Run("Socket", func(t *testing.T) {
+		fi, err := os.Stat(socket)
+		if err != nil { t.Fatal(err) }
+		if fi.Mode().Perm() != 0600 { t.Errorf("Unexpected socket permissions %v", fi.Mode().Perm()) }
+		if NewAgent(0).Serve(socket) != AgentAlreadyRunning { t.Error("Could start a second agent!") }
+	})
+	t.Run("Locked", func(t *testing.T) {
+		_, err := OpenJournalFileWithAgent(JournalTestFile, c, true)
+		if err != AgentJournalLocked { t.Errorf("Expected %v, but got %v", AgentJournalLocked, err) }
+	})
+	var ts uint64
+	t.Run("Add", func(t *testing.T) {
+		err := c.Add(j)
+		if err != nil { t.Fatal("Could not add journal; ", err) }
+		js, err := c.List()
+		if err != nil { t.Fatal("Could not list journals; ", err) }
+		if len(js) != 1 || js[0].Id != j.Header.Id { t.Errorf("Unexpected journals %v", js) }
+		j.Close()
+		// sealed entry, opened by the agent
+		wj, err := OpenJournalFileWriteOnly(JournalTestFile)
+		if err != nil { t.Fatal(err) }
+		e, _ := wj.NewEntry("sealed entry")
+		wj.AddEntry(e)
+		wj.Write()
+		wj.Close()
+		ts = e.Timestamp
+	})
+	t.Run("EncryptDecrypt", func(t *testing.T) {
+		aj, err := OpenJournalFileWithAgent(JournalTestFile, c, false)
+		if err != nil { t.Fatal("Could not open journal with agent; ", err) }
+		if txt, err := aj.Decrypt(aj.GetEntry(ts)); err != nil || txt != "sealed entry" {
+			t.Errorf("Could not open sealed entry (%v); %v", txt, err)
+		}
+		e, err := aj.NewEntryWithMetadata("agent entry", EntryMetadata{Tags: []string{"agent"}})
+		if err != nil { t.Fatal("Could not encrypt entry; ", err) }
+		aj.AddEntry(e)
+		aj.Write()
+		if aj.ChangePassword(0, passwd, testKdfParams) != MasterKeyUnavailable { t.Error("Could change password without master key!") }
+		aj.Close()
+		// must be readable with the password
+		pj, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal(err) }
+		defer pj.Close()
+		p, err := pj.DecryptPayload(pj.GetEntry(e.Timestamp))
+		if err != nil || p.Text != "agent entry" || !p.HasTag("agent") { t.Errorf("Unexpected entry %v; %v", p, err) }
+	})
+	t.Run("MessageSize", func(t *testing.T) {
+		// a field of 4 GiB - 1 must be rejected before it is read
+		msg := []byte{agentMsg_Encrypt, 0, 1, 0xff, 0xff, 0xff, 0xff}
+		if _, _, err := readAgentMessage(bytes.NewReader(msg)); err != InvalidAgentMessage {
+			t.Errorf("Expected %v, but got %v", InvalidAgentMessage, err)
+		}
+		msg = []byte{agentMsg_Encrypt, 0, 2, 0, 0, 0, 1, 'a', 0, 0, 0, 1, 'b'}
+		if _, fields, err := readAgentMessage(bytes.NewReader(msg)); err != nil || len(fields) != 2 {
+			t.Errorf("Could not read message (%v); %v", fields, err)
+		}
+	})
+	t.Run("IdleTimeout", func(t *testing.T) {
+		agent.Timeout = 50 * time.Millisecond
+		agent.touch()
+		time.Sleep(200 * time.Millisecond)
+		if _, err := OpenJournalFileWithAgent(JournalTestFile, c, false); err != AgentJournalLocked { t.Error("Agent wasn't locked after timeout; ", err) }
+	})
+	t.Run("Stop", func(t *testing.T) {
+		err := c.Stop()
+		if err != nil { t.Fatal("Could not stop agent; ", err) }
+		if err := <-served; err != nil { t.Error("Unexpected error; ", err) }
+		if _, err := os.Stat(socket); !os.IsNotExist(err) { t.Error("The socket wasn't removed!") }
+	})
+}
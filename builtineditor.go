@@ -0,0 +1,512 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"bytes"
+	"os"
+	"unicode/utf8"
+
+	"github.com/awnumar/memguard"
+	"golang.org/x/term"
+)
+
+/*
+
+The built-in full-screen editor, running in raw terminal mode.
+
+The text is kept in a memguard buffer (see EditorBuffer), as are
+the snapshots used for undo and redo. Long lines are wrapped at
+word boundaries. Pasted text is inserted as-is if the terminal
+supports bracketed paste, with all line breaks converted to \n.
+
+Keys:
+
+  Arrow keys, Home, End,
+  Page Up, Page Down        move the cursor
+  Ctrl+S or Ctrl+D          save
+  Ctrl+Q or Ctrl+C          cancel (asks before changes are discarded)
+  Ctrl+Z / Ctrl+Y           undo / redo
+  Ctrl+E                    continue in the external editor
+
+*/
+
+const (
+	EditorSaved = iota
+	EditorCancelled
+	EditorExternal // the user wants to continue in the external editor
+)
+
+const editorMaxUndo = 200
+
+// buffer
+
+type EditorBuffer struct {
+	lb *memguard.LockedBuffer
+	len int
+}
+
+func NewEditorBuffer(initial []byte) *EditorBuffer {
+	b := &EditorBuffer{lb: memguard.NewBuffer(max(2 * len(initial), 1024))}
+	copy(b.lb.Bytes(), initial)
+	b.len = len(initial)
+	return b
+}
+
+func (b *EditorBuffer) Bytes() []byte {
+	return b.lb.Bytes()[:b.len]
+}
+
+func (b *EditorBuffer) Len() int {
+	return b.len
+}
+
+func (b *EditorBuffer) Insert(pos int, data []byte) {
+	if b.len + len(data) > b.lb.Size() {
+		// grow
+		lb := memguard.NewBuffer(2 * (b.len + len(data)))
+		copy(lb.Bytes(), b.Bytes())
+		b.lb.Destroy()
+		b.lb = lb
+	}
+	buf := b.lb.Bytes()
+	copy(buf[pos+len(data):], buf[pos:b.len])
+	copy(buf[pos:], data)
+	b.len += len(data)
+}
+
+func (b *EditorBuffer) Delete(start int, end int) {
+	buf := b.lb.Bytes()
+	copy(buf[start:], buf[end:b.len])
+	b.len -= end - start
+	clear(buf[b.len:b.len + end - start])
+}
+
+func (b *EditorBuffer) Snapshot() *memguard.Enclave {
+	// returns nil for an empty buffer
+	if b.len == 0 { return nil }
+	snap := memguard.NewBuffer(b.len)
+	copy(snap.Bytes(), b.Bytes())
+	return snap.Seal()
+}
+
+func (b *EditorBuffer) Restore(snap *memguard.Enclave) error {
+	b.Delete(0, b.len)
+	if snap == nil { return nil }
+	lb, err := snap.Open()
+	if err != nil { return err }
+	defer lb.Destroy()
+	b.Insert(0, lb.Bytes())
+	return nil
+}
+
+func (b *EditorBuffer) Destroy() {
+	b.lb.Destroy()
+}
+
+// layout
+
+type editorRow struct {
+	start int
+	end int // excluding the newline
+	wrapped bool // the line continues in the next row
+}
+
+func wrapRows(text []byte, width int) []editorRow {
+	rows := []editorRow{}
+	width = max(width, 2)
+	ls := 0 // start of the current line
+	for ls <= len(text) {
+		le := ls // end of the current line
+		for le < len(text) && text[le] != '\n' { le++ }
+		pos := ls
+		for {
+			n := 0
+			i := pos
+			lastSpace := -1
+			for i < le && n < width {
+				r, size := utf8.DecodeRune(text[i:])
+				i += size
+				n++
+				if r == ' ' { lastSpace = i }
+			}
+			if i >= le {
+				rows = append(rows, editorRow{pos, le, false})
+				break
+			}
+			brk := i
+			if lastSpace > pos { brk = lastSpace }
+			rows = append(rows, editorRow{pos, brk, true})
+			pos = brk
+		}
+		ls = le + 1
+	}
+	return rows
+}
+
+func cursorRow(rows []editorRow, cursor int) int {
+	for i, r := range rows {
+		if cursor >= r.start && (cursor < r.end || (cursor == r.end && !r.wrapped)) {
+			return i
+		}
+	}
+	return len(rows) - 1
+}
+
+func runeCount(b []byte) int {
+	return utf8.RuneCount(b)
+}
+
+func offsetAtColumn(text []byte, r editorRow, col int) int {
+	// returns the byte offset of the column in the row
+	pos := r.start
+	for n := 0; n < col && pos < r.end; n++ {
+		_, size := utf8.DecodeRune(text[pos:])
+		if r.wrapped && pos + size >= r.end { break } // stay in this row
+		pos += size
+	}
+	return pos
+}
+
+// editor
+
+type editorState struct {
+	text *memguard.Enclave
+	cursor int
+}
+
+type Editor struct {
+	Title string
+	buf *EditorBuffer
+	cursor int // byte offset
+	wantCol int // column to keep when moving up and down
+	scroll int // first visible row
+	undo []editorState
+	redo []editorState
+	lastAction int
+	afterBoundary bool // the last inserted character was a space or newline
+	modified bool
+	confirmCancel bool // asking whether the changes should be discarded
+}
+
+const (
+	editorActionNone = iota
+	editorActionInsert
+	editorActionDelete
+	editorActionPaste
+)
+
+func NewEditor(title string, initial []byte) *Editor {
+	return &Editor{Title: title, buf: NewEditorBuffer(initial), cursor: len(initial)}
+}
+
+func (e *Editor) Text() []byte {
+	return e.buf.Bytes()
+}
+
+func (e *Editor) TakeText() *memguard.LockedBuffer {
+	// moves the text into a new locked buffer, the text
+	// of the editor is wiped
+	lb := memguard.NewBufferFromBytes(e.buf.Bytes())
+	e.buf.len = 0
+	e.cursor = 0
+	return lb
+}
+
+func (e *Editor) SetText(text []byte) {
+	// replaces the text, this can be undone
+	e.pushUndo()
+	e.redo = nil
+	e.lastAction = editorActionNone
+	e.modified = true
+	e.buf.Restore(nil)
+	e.buf.Insert(0, text)
+	e.cursor = min(e.cursor, e.buf.Len())
+}
+
+func (e *Editor) Destroy() {
+	e.buf.Destroy()
+	e.undo = nil
+	e.redo = nil
+}
+
+func (e *Editor) Run() (int, error) {
+	// returns EditorSaved, EditorCancelled or EditorExternal
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil { return EditorCancelled, err }
+	defer term.Restore(fd, state)
+	defer Out(AS_ERASE_SCREEN, AS_CUR_HOME)
+	Out(editorPasteOn)
+	defer Out(editorPasteOff)
+	input := make([]byte, 64)
+	pending := []byte{}
+	for {
+		e.render()
+		n, err := os.Stdin.Read(input)
+		if err != nil { return EditorCancelled, err }
+		pending = append(pending, input[:n]...)
+		for len(pending) > 0 {
+			key, size := parseKey(pending)
+			if size == 0 { break } // incomplete escape sequence
+			pending = pending[size:]
+			if result := e.handleKey(key); result >= 0 {
+				clear(pending)
+				clear(input)
+				return result, nil
+			}
+		}
+		clear(input)
+	}
+}
+
+// keys
+
+const (
+	keyNone = iota
+	keyRune
+	keyEnter
+	keyBackspace
+	keyDelete
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyPageUp
+	keyPageDown
+	keySave
+	keyCancel
+	keyUndo
+	keyRedo
+	keyExternal
+	keyPaste
+)
+
+// bracketed paste
+const (
+	editorPasteOn = "\x1b[?2004h"
+	editorPasteOff = "\x1b[?2004l"
+	editorPasteStart = "\x1b[200~"
+	editorPasteEnd = "\x1b[201~"
+)
+
+type editorKey struct {
+	kind int
+	r []byte // utf-8 encoded rune for keyRune, the pasted text for keyPaste
+}
+
+func parseKey(b []byte) (editorKey, int) {
+	// returns the key and the number of bytes used, 0 if incomplete
+	switch b[0] {
+	case 0x13, 0x04: return editorKey{kind: keySave}, 1         // Ctrl+S, Ctrl+D
+	case 0x11, 0x03: return editorKey{kind: keyCancel}, 1       // Ctrl+Q, Ctrl+C
+	case 0x1a: return editorKey{kind: keyUndo}, 1               // Ctrl+Z
+	case 0x19: return editorKey{kind: keyRedo}, 1               // Ctrl+Y
+	case 0x05: return editorKey{kind: keyExternal}, 1           // Ctrl+E
+	case 0x01: return editorKey{kind: keyHome}, 1               // Ctrl+A
+	case '\r':
+		if len(b) > 1 && b[1] == '\n' { return editorKey{kind: keyEnter}, 2 }
+		return editorKey{kind: keyEnter}, 1
+	case '\n': return editorKey{kind: keyEnter}, 1
+	case 0x7f, 0x08: return editorKey{kind: keyBackspace}, 1
+	case '\t': return editorKey{kind: keyRune, r: []byte("    ")}, 1
+	case 0x1b:
+		if len(b) < 2 { return editorKey{}, 0 }
+		if b[1] != '[' && b[1] != 'O' { return editorKey{kind: keyNone}, 1 }
+		// find the end of the sequence
+		i := 2
+		for i < len(b) && (b[i] >= '0' && b[i] <= '9' || b[i] == ';') { i++ }
+		if i >= len(b) { return editorKey{}, 0 }
+		seq := string(b[2:i+1])
+		if seq == "200~" {
+			// the pasted text is complete once the end sequence was read
+			end := bytes.Index(b, []byte(editorPasteEnd))
+			if end < 0 { return editorKey{}, 0 }
+			return editorKey{kind: keyPaste, r: b[len(editorPasteStart):end]}, end + len(editorPasteEnd)
+		}
+		kinds := map[string]int{
+			"A": keyUp, "B": keyDown, "C": keyRight, "D": keyLeft,
+			"H": keyHome, "F": keyEnd, "1~": keyHome, "7~": keyHome, "4~": keyEnd, "8~": keyEnd,
+			"3~": keyDelete, "5~": keyPageUp, "6~": keyPageDown,
+		}
+		return editorKey{kind: kinds[seq]}, i + 1
+	}
+	if b[0] < 0x20 { return editorKey{kind: keyNone}, 1 } // other control characters
+	if !utf8.FullRune(b) { return editorKey{}, 0 }
+	_, size := utf8.DecodeRune(b)
+	return editorKey{kind: keyRune, r: b[:size]}, size
+}
+
+func (e *Editor) handleKey(k editorKey) int {
+	// returns the result if the editor should be closed, else -1
+	text := e.Text()
+	_, height := e.size()
+	rows := wrapRows(text, e.textWidth())
+	row := cursorRow(rows, e.cursor)
+	if e.confirmCancel {
+		e.confirmCancel = false
+		if k.kind == keyRune && (k.r[0] == 'y' || k.r[0] == 'Y') { return EditorCancelled }
+		return -1
+	}
+	switch k.kind {
+	case keySave: return EditorSaved
+	case keyCancel:
+		if !e.modified { return EditorCancelled }
+		e.confirmCancel = true
+		return -1
+	case keyExternal: return EditorExternal
+	case keyRune:
+		e.edit(editorActionInsert, k.r[0] == ' ')
+		e.buf.Insert(e.cursor, k.r)
+		e.cursor += len(k.r)
+	case keyEnter:
+		e.edit(editorActionInsert, true)
+		e.buf.Insert(e.cursor, []byte{'\n'})
+		e.cursor++
+	case keyPaste:
+		if len(k.r) == 0 { break }
+		text := normalizeNewlines(k.r)
+		e.edit(editorActionPaste, true)
+		e.buf.Insert(e.cursor, text)
+		e.cursor += len(text)
+	case keyBackspace:
+		if e.cursor == 0 { break }
+		_, size := utf8.DecodeLastRune(text[:e.cursor])
+		e.edit(editorActionDelete, false)
+		e.buf.Delete(e.cursor - size, e.cursor)
+		e.cursor -= size
+	case keyDelete:
+		if e.cursor >= len(text) { break }
+		_, size := utf8.DecodeRune(text[e.cursor:])
+		e.edit(editorActionDelete, false)
+		e.buf.Delete(e.cursor, e.cursor + size)
+	case keyLeft:
+		if e.cursor > 0 {
+			_, size := utf8.DecodeLastRune(text[:e.cursor])
+			e.cursor -= size
+		}
+	case keyRight:
+		if e.cursor < len(text) {
+			_, size := utf8.DecodeRune(text[e.cursor:])
+			e.cursor += size
+		}
+	case keyHome:
+		e.cursor = rows[row].start
+	case keyEnd:
+		e.cursor = offsetAtColumn(text, rows[row], runeCount(text[rows[row].start:rows[row].end]))
+	case keyUp, keyDown, keyPageUp, keyPageDown:
+		delta := map[int]int{keyUp: -1, keyDown: 1, keyPageUp: -(height - 2), keyPageDown: height - 2}[k.kind]
+		target := min(max(row + delta, 0), len(rows) - 1)
+		e.cursor = offsetAtColumn(text, rows[target], e.wantCol)
+		e.lastAction = editorActionNone
+		return -1 // keep the column
+	case keyUndo:
+		e.restore(&e.undo, &e.redo)
+	case keyRedo:
+		e.restore(&e.redo, &e.undo)
+	}
+	if k.kind != keyRune && k.kind != keyBackspace && k.kind != keyDelete {
+		e.lastAction = editorActionNone
+	}
+	// remember the column
+	rows = wrapRows(e.Text(), e.textWidth())
+	r := rows[cursorRow(rows, e.cursor)]
+	e.wantCol = runeCount(e.Text()[r.start:e.cursor])
+	return -1
+}
+
+func (e *Editor) edit(action int, boundary bool) {
+	// Saves a snapshot for undo before the text is changed.
+	// Consecutive edits of the same kind are undone together,
+	// typed text word by word.
+	if action != e.lastAction || (e.afterBoundary && !boundary) {
+		e.pushUndo()
+	}
+	e.redo = nil
+	e.lastAction = action
+	e.afterBoundary = boundary
+	e.modified = true
+}
+
+func normalizeNewlines(b []byte) []byte {
+	// converts \r\n and \r to \n, in place
+	n := 0
+	for i := 0; i < len(b); i++ {
+		if b[i] == '\r' {
+			if i + 1 < len(b) && b[i+1] == '\n' { i++ }
+			b[n] = '\n'
+		} else {
+			b[n] = b[i]
+		}
+		n++
+	}
+	return b[:n]
+}
+
+func (e *Editor) pushUndo() {
+	e.undo = append(e.undo, editorState{e.buf.Snapshot(), e.cursor})
+	if len(e.undo) > editorMaxUndo { e.undo = e.undo[1:] }
+}
+
+func (e *Editor) restore(from *[]editorState, to *[]editorState) {
+	if len(*from) == 0 { return }
+	s := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, editorState{e.buf.Snapshot(), e.cursor})
+	if e.buf.Restore(s.text) == nil {
+		e.cursor = min(s.cursor, e.buf.Len())
+	}
+	e.lastAction = editorActionNone
+}
+
+// rendering
+
+func (e *Editor) size() (int, int) {
+	w, h, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil { return 80, 24 }
+	return max(w, 10), max(h, 4)
+}
+
+func (e *Editor) textWidth() int {
+	// leave one column for the cursor at the end of a row
+	w, _ := e.size()
+	return w - 1
+}
+
+func (e *Editor) render() {
+	width, height := e.size()
+	textHeight := height - 2
+	text := e.Text()
+	rows := wrapRows(text, e.textWidth())
+	row := cursorRow(rows, e.cursor)
+	// scroll, so that the cursor is visible
+	if row < e.scroll { e.scroll = row }
+	if row >= e.scroll + textHeight { e.scroll = row - textHeight + 1 }
+	out := os.Stdout
+	Out(AS_CUR_HOME)
+	// title
+	title := e.Title
+	if runeCount([]byte(title)) > width { title = string([]rune(title)[:width]) }
+	Out(Am(AC_SET_INVERTED), title, AS_ERASE_REST_OF_LINE, Am(AC_RESET_INVERTED), "\r\n")
+	// text
+	for i := e.scroll; i < e.scroll + textHeight; i++ {
+		if i < len(rows) {
+			out.Write(text[rows[i].start:rows[i].end])
+		}
+		Out(AS_ERASE_REST_OF_LINE, "\r\n")
+	}
+	// help
+	if e.confirmCancel {
+		Out("Discard changes? [y/N] ", AS_ERASE_REST_OF_LINE)
+		return // the cursor stays at the question
+	}
+	Out(Am(AC_SET_DIM), "Ctrl+S save  Ctrl+Q cancel  Ctrl+Z undo  Ctrl+Y redo")
+	if ExternalEditor() != "" { Out("  Ctrl+E external editor") }
+	Out(Am(AC_RESET_DIM), AS_ERASE_REST_OF_LINE)
+	// cursor
+	col := runeCount(text[rows[row].start:e.cursor])
+	Out(AS_CUR_HOME, ACurDown(row - e.scroll + 1))
+	if col > 0 { Out(ACurRight(col)) }
+}
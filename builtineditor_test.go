@@ -0,0 +1,115 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestBuiltinEditor(t *testing.T) {
+	defer memguard.Purge()
+	typeText := func(ed *Editor, txt string) {
+		for len(txt) > 0 {
+			k, size := parseKey([]byte(txt))
+			ed.handleKey(k)
+			txt = txt[size:]
+		}
+	}
+	t.Run("EditorBuffer", func(t *testing.T) {
+		b := NewEditorBuffer([]byte("hello"))
+		defer b.Destroy()
+		b.Insert(5, []byte(" world"))
+		b.Insert(0, []byte("> "))
+		b.Delete(2, 3)
+		if string(b.Bytes()) != "> ello world" { t.Errorf("Unexpected buffer content %q", b.Bytes()) }
+		long := make([]byte, 5000)
+		b.Insert(b.Len(), long)
+		if b.Len() != 5012 { t.Error("Buffer was not grown correctly!") }
+		snap := b.Snapshot()
+		b.Delete(0, b.Len())
+		if b.Restore(snap) != nil || b.Len() != 5012 { t.Error("Could not restore snapshot!") }
+	})
+	t.Run("WordWrap", func(t *testing.T) {
+		text := []byte("the quick brown fox\n\njumps")
+		rows := wrapRows(text, 10)
+		expected := []string{"the quick ", "brown fox", "", "jumps"}
+		got := []string{}
+		for _, r := range rows { got = append(got, string(text[r.start:r.end])) }
+		if !slices.Equal(got, expected) { t.Errorf("Expected rows %q, but got %q", expected, got) }
+		rows = wrapRows([]byte("äöüäöüäöü"), 4)
+		if len(rows) != 3 || !rows[0].wrapped || rows[2].wrapped { t.Error("Long words were not wrapped correctly!") }
+		if cursorRow(wrapRows(text, 10), 10) != 1 { t.Error("Cursor at a wrap was not placed in the next row!") }
+	})
+	t.Run("Editing", func(t *testing.T) {
+		ed := NewEditor("", []byte("first line"))
+		defer ed.Destroy()
+		typeText(ed, "\rsecönd")
+		typeText(ed, "\x1b[D\x1b[D\x7f")           // left, left, backspace
+		typeText(ed, "\x1b[A\x1b[H\x1b[3~F")       // up, home, delete, F
+		if string(ed.Text()) != "First line\nsecnd" { t.Errorf("Unexpected text %q", ed.Text()) }
+		typeText(ed, "\x1b[B\x1b[F!")              // down, end
+		if string(ed.Text()) != "First line\nsecnd!" { t.Errorf("Unexpected text %q", ed.Text()) }
+	})
+	t.Run("TakeText", func(t *testing.T) {
+		ed := NewEditor("", []byte("secret"))
+		defer ed.Destroy()
+		text := ed.buf.lb.Bytes()[:6]
+		lb := ed.TakeText()
+		defer lb.Destroy()
+		if lb.String() != "secret" { t.Errorf("Unexpected text %q", lb.String()) }
+		if ed.buf.Len() != 0 || string(text) != "\x00\x00\x00\x00\x00\x00" { t.Error("The text of the editor was not wiped!") }
+		ed = NewEditor("", []byte{})
+		defer ed.Destroy()
+		lb = ed.TakeText()
+		defer lb.Destroy()
+		if lb.String() != "" { t.Errorf("Expected empty text, got %q", lb.String()) }
+	})
+	t.Run("UndoRedo", func(t *testing.T) {
+		ed := NewEditor("", []byte{})
+		defer ed.Destroy()
+		typeText(ed, "one two")
+		typeText(ed, "\x7f\x7f")
+		typeText(ed, "\x1a")
+		if string(ed.Text()) != "one two" { t.Errorf("Expected deletion to be undone, got %q", ed.Text()) }
+		typeText(ed, "\x1a")
+		if string(ed.Text()) != "one " { t.Errorf("Expected word to be undone, got %q", ed.Text()) }
+		typeText(ed, "\x19\x19")
+		if string(ed.Text()) != "one t" { t.Errorf("Expected redo, got %q", ed.Text()) }
+		typeText(ed, "\x1a\x1a\x1a")
+		if ed.buf.Len() != 0 { t.Errorf("Expected empty text, got %q", ed.Text()) }
+	})
+	t.Run("Cancel", func(t *testing.T) {
+		ed := NewEditor("", []byte("text"))
+		defer ed.Destroy()
+		if r := ed.handleKey(editorKey{kind: keyCancel}); r != EditorCancelled { t.Error("Unmodified text was not cancelled at once!") }
+		typeText(ed, "!")
+		if r := ed.handleKey(editorKey{kind: keyCancel}); r != -1 || !ed.confirmCancel { t.Fatal("Changes were discarded without asking!") }
+		typeText(ed, "n")
+		if ed.confirmCancel || string(ed.Text()) != "text!" { t.Errorf("Expected the question to be declined, got %q", ed.Text()) }
+		ed.handleKey(editorKey{kind: keyCancel})
+		k, _ := parseKey([]byte("y"))
+		if r := ed.handleKey(k); r != EditorCancelled { t.Error("Changes were not discarded after confirming!") }
+	})
+	t.Run("Paste", func(t *testing.T) {
+		ed := NewEditor("", []byte{})
+		defer ed.Destroy()
+		typeText(ed, "a\r\nb")
+		if string(ed.Text()) != "a\nb" { t.Errorf("Expected CRLF as a single line break, got %q", ed.Text()) }
+		typeText(ed, "\x1b[200~ one\r\ntwo\rthree\n\tfour\x1b[201~")
+		if string(ed.Text()) != "a\nb one\ntwo\nthree\n\tfour" { t.Errorf("Unexpected pasted text %q", ed.Text()) }
+		typeText(ed, "\x1a")
+		if string(ed.Text()) != "a\nb" { t.Errorf("Expected paste to be undone at once, got %q", ed.Text()) }
+		if _, size := parseKey([]byte("\x1b[200~text")); size != 0 { t.Error("Incomplete paste was parsed!") }
+	})
+	t.Run("Keys", func(t *testing.T) {
+		for b, kind := range map[string]int{"\x13": keySave, "\x11": keyCancel, "\x05": keyExternal, "\x1b[5~": keyPageUp} {
+			k, size := parseKey([]byte(b))
+			if k.kind != kind || size != len(b) { t.Errorf("Key %q was not parsed correctly", b) }
+		}
+		if _, size := parseKey([]byte("\x1b[")); size != 0 { t.Error("Incomplete escape sequence was parsed!") }
+		if _, size := parseKey([]byte("ä")[:1]); size != 0 { t.Error("Incomplete rune was parsed!") }
+	})
+}
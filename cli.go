@@ -0,0 +1,986 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awnumar/memguard"
+	"golang.org/x/term"
+)
+
+/*
+
+This file includes the non-interactive subcommands.
+They are called using `journal <subcommand> [args...]`,
+without a subcommand, the interactive user interface is started
+(see Entrypoint()).
+
+The subcommands for scripting (new, list, show, delete, search
+and export) write prompts and errors to stderr, their results to
+stdout, and exit with one of the exit codes below. With --json,
+the results are written as JSON (see EntryJson).
+If the agent is running and has the key of the journal (see
+agent.go), they don't ask for the password.
+
+*/
+
+const (
+	ExitOk = 0
+	ExitError = 1
+	ExitUsage = 2
+	ExitNotFound = 3 // no such entry, or nothing found
+)
+
+type Subcommand struct {
+	Name string
+	Args string
+	Description string
+	Run func(args []string) int // returns the exit code
+}
+
+var subcommands = []Subcommand{
+	{"passwd", "[--keyfile <path>] [password options] [--new-keyfile <path>] [kdf options] <path>", "Change the password of a journal", CmdPasswd},
+	{"keyslots", "[--keyfile <path>] [password options] [--new-keyfile <path>] [kdf options] <list|add|remove> <path> [label|slot]", "Manage the passwords (key slots) of a journal", CmdKeySlots},
+	{"sealing", "[--keyfile <path>] [password options] <enable|disable|status> <path>", "Manage sealed entries, which can be written without a password", CmdSealing},
+	{"append", "<path>", "Add a sealed entry from stdin without a password", CmdAppend},
+	{"new", "[--keyfile <path>] [password options] [--json] [--at <date/time> [--shift]] [metadata options] <path> <--stdin|text...>", "Add an entry", CmdNew},
+	{"list", "[--keyfile <path>] [password options] [--json] [--filter <filter>] <path>", "List the entries", CmdList},
+	{"show", "[--keyfile <path>] [password options] [--json] <path> <timestamp|latest>", "Show an entry", CmdShow},
+	{"delete", "[--keyfile <path>] [password options] [--json] [--purge] <path> <timestamp>", "Move an entry to the trash, or delete it and its revisions permanently", CmdDelete},
+	{"trash", "[--keyfile <path>] [password options] [--json] [--older-than <days>] <list|restore|purge|empty> <path> [timestamp]", "List, restore or permanently delete entries in the trash", CmdTrash},
+	{"export", "[--keyfile <path>] [password options] [--format <text|markdown|json>] [--json] [--iso] [--output <path> [--per-entry]] <path>", "Export all entries to stdout, a file or one file per entry", CmdExport},
+	{"index", "[--keyfile <path>] [password options] <enable|disable|status> <path>", "Manage the encrypted search index", CmdIndex},
+	{"search", "[--keyfile <path>] [password options] [--json] [--regex|--word] <path> <query>", "Search the entries of a journal", CmdSearch},
+	{"import", "[--keyfile <path>] [password options] [--shift] <jrnl|dayone|markdown> <path> <file|directory>", "Import entries from jrnl, Day One or Markdown files", CmdImport},
+	{"agent", "[--timeout <duration>] [--foreground] [--keyfile <path>] [password options] <start|add|list|lock|stop> [path...]", "Keep journals unlocked for other invocations", CmdAgent},
+}
+
+func GetSubcommand(name string) *Subcommand {
+	for _, sc := range subcommands {
+		if sc.Name == name {
+			return &sc
+		}
+	}
+	return nil
+}
+
+func ShowSubcommandUsage(name string, args string) int {
+	a0Parts := strings.Split(os.Args[0], "/")
+	binName := a0Parts[len(a0Parts)-1]
+	Out("Usage: ", binName, " ", name, " ", args); Nl()
+	return ExitUsage
+}
+
+func NewFlagSet() *flag.FlagSet {
+	// errors and usage are handled by the caller
+	flags := flag.NewFlagSet("", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+func ParseFlags(flags *flag.FlagSet, args []string) error {
+	// Like flags.Parse(), but the flags can also follow the
+	// positional arguments, e.g. `new <path> --stdin`.
+	// Everything after "--" is a positional argument.
+	positional := []string{}
+	for {
+		err := flags.Parse(args)
+		if err != nil { return err }
+		rest := flags.Args()
+		if len(rest) == 0 { break }
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	// only the positional arguments are left for flags.Args()
+	return flags.Parse(append([]string{"--"}, positional...))
+}
+
+func LoadKeyfile(path string) (*memguard.Enclave, error) {
+	// returns nil if no path is given
+	if path == "" { return nil, nil }
+	return ReadKeyfile(path)
+}
+
+// kdf options, see KdfParams
+
+const KdfOptionsUsage = "[--kdf-memory <MiB>] [--kdf-time <passes>] [--kdf-threads <n>] [--kdf-calibrate <duration>]"
+
+type KdfFlags struct {
+	flags *flag.FlagSet
+	memory *uint
+	time *uint
+	threads *uint
+	calibrate *time.Duration
+}
+
+func AddKdfFlags(flags *flag.FlagSet) *KdfFlags {
+	return &KdfFlags{
+		flags: flags,
+		memory: flags.Uint("kdf-memory", 0, ""),
+		time: flags.Uint("kdf-time", 0, ""),
+		threads: flags.Uint("kdf-threads", 0, ""),
+		calibrate: flags.Duration("kdf-calibrate", 0, ""),
+	}
+}
+
+func (kf *KdfFlags) Params(base KdfParams) (KdfParams, error) {
+	// Returns the base parameters, overridden by the given flags.
+	// When calibrating, the number of passes is measured.
+	set := map[string]bool{}
+	kf.flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	p := base
+	if set["kdf-memory"] {
+		if *kf.memory > math.MaxUint32 / 1024 { return p, InvalidKdfParams }
+		p.Memory = uint32(*kf.memory) * 1024
+	}
+	if set["kdf-time"] {
+		if *kf.time > math.MaxUint32 { return p, InvalidKdfParams }
+		p.Time = uint32(*kf.time)
+	}
+	if set["kdf-threads"] {
+		if *kf.threads > math.MaxUint8 { return p, InvalidKdfParams }
+		p.Threads = uint8(*kf.threads)
+	}
+	if !p.Valid() { return p, InvalidKdfParams }
+	if set["kdf-calibrate"] {
+		if *kf.calibrate <= 0 || set["kdf-time"] { return p, InvalidKdfParams }
+		Out("[Calibrating key derivation ...] ")
+		p = CalibrateKdfParams(*kf.calibrate, p.Memory, p.Threads)
+		Out("\r", AS_ERASE_LINE)
+	}
+	return p, nil
+}
+
+func ExitWithError(err error, msg string) int {
+	Out(Am(AC_COL_RED_FG), msg, Am(AC_COL_RESET_FG)); Nl()
+	if err != nil { Out(err); Nl() }
+	return ExitError
+}
+
+// subcommands
+
+func CmdPasswd(args []string) int {
+	usage := "[--keyfile <path>] " + PasswordOptionsUsage + " [--new-keyfile <path>] " + KdfOptionsUsage + " <path>"
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	newKeyfilePath := flags.String("new-keyfile", "", "")
+	kdfFlags := AddKdfFlags(flags)
+	if ParseFlags(flags, args) != nil || flags.NArg() != 1 {
+		return ShowSubcommandUsage("passwd", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	file := flags.Arg(0)
+	if _, err := os.Stat(file); err != nil {
+		return ExitWithError(err, "Couldn't open journal file!")
+	}
+	keyfile, err := LoadKeyfile(*keyfilePath)
+	if err != nil { return ExitWithError(err, "Couldn't read keyfile!") }
+	newKeyfile, err := LoadKeyfile(*newKeyfilePath)
+	if err != nil { return ExitWithError(err, "Couldn't read keyfile!") }
+	PrintVersion()
+	passwd, err := ReadKey("Please enter the current password.", keyfile)
+	if err != nil { return ExitWithError(err, "Couldn't get password from commandline safely.") }
+	j, err := OpenJournalFile(file, passwd)
+	if err != nil { return ExitWithError(err, "Couldn't open journal file!") }
+	defer j.Close()
+	kdf, err := kdfFlags.Params(j.GetKeySlots()[j.UnlockedKeySlot()].Kdf)
+	if err != nil { return ExitWithError(err, "Invalid kdf options!") }
+	newPasswd, err := ReadNewKey(newKeyfile)
+	if err != nil { return ExitWithError(err, "Couldn't get password from commandline safely.") }
+	err = j.ChangePassword(j.UnlockedKeySlot(), newPasswd, kdf)
+	if err != nil { return ExitWithError(err, "Couldn't change password!") }
+	err = j.Write()
+	if err != nil { return ExitWithError(err, "Couldn't write journal file!") }
+	Out("The password was changed."); Nl()
+	return 0
+}
+
+func CmdKeySlots(args []string) int {
+	usage := "[--keyfile <path>] " + PasswordOptionsUsage + " [--new-keyfile <path>] " + KdfOptionsUsage + " <list|add|remove> <path> [label|slot]"
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	newKeyfilePath := flags.String("new-keyfile", "", "")
+	kdfFlags := AddKdfFlags(flags)
+	if ParseFlags(flags, args) != nil || flags.NArg() < 2 {
+		return ShowSubcommandUsage("keyslots", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	args = flags.Args()
+	action := args[0]
+	file := args[1]
+	if _, err := os.Stat(file); err != nil {
+		return ExitWithError(err, "Couldn't open journal file!")
+	}
+	switch action {
+	case "list":
+		if len(args) != 2 { return ShowSubcommandUsage("keyslots", usage) }
+		h, err := ReadJournalHeader(file)
+		if err != nil { return ExitWithError(err, "Couldn't read journal header!") }
+		for i, slot := range h.KeySlots {
+			Out(i, "  ", KeySlotDescription(&slot, false)); Nl()
+		}
+		return 0
+	case "add", "remove":
+		if len(args) > 3 { return ShowSubcommandUsage("keyslots", usage) }
+		keyfile, err := LoadKeyfile(*keyfilePath)
+		if err != nil { return ExitWithError(err, "Couldn't read keyfile!") }
+		newKeyfile, err := LoadKeyfile(*newKeyfilePath)
+		if err != nil { return ExitWithError(err, "Couldn't read keyfile!") }
+		PrintVersion()
+		passwd, err := ReadKey("Please enter one of the current passwords.", keyfile)
+		if err != nil { return ExitWithError(err, "Couldn't get password from commandline safely.") }
+		j, err := OpenJournalFile(file, passwd)
+		if err != nil { return ExitWithError(err, "Couldn't open journal file!") }
+		defer j.Close()
+		if action == "add" {
+			label := ""
+			if len(args) == 3 { label = args[2] }
+			kdf, err := kdfFlags.Params(j.Header.Kdf)
+			if err != nil { return ExitWithError(err, "Invalid kdf options!") }
+			newPasswd, err := ReadNewKey(newKeyfile)
+			if err != nil { return ExitWithError(err, "Couldn't get password from commandline safely.") }
+			i, err := j.AddKeySlot(newPasswd, label, kdf)
+			if err != nil { return ExitWithError(err, "Couldn't add key slot!") }
+			err = j.Write()
+			if err != nil { return ExitWithError(err, "Couldn't write journal file!") }
+			Out(fmt.Sprintf("Added key slot %v.", i)); Nl()
+		} else {
+			if len(args) != 3 { return ShowSubcommandUsage("keyslots", usage) }
+			i, err := strconv.Atoi(args[2])
+			if err != nil { return ExitWithError(err, "Invalid key slot!") }
+			answer := MultiChoiceOrCommand(
+				[][2]string{{"yes", ""}, {"no", ""}},
+				[]string{},
+				fmt.Sprintf("Do you really want to remove key slot %v?", i), "")
+			if answer != 0 { return 1 }
+			err = j.RemoveKeySlot(i)
+			if err != nil { return ExitWithError(err, "Couldn't remove key slot!") }
+			err = j.Write()
+			if err != nil { return ExitWithError(err, "Couldn't write journal file!") }
+			Out(fmt.Sprintf("Removed key slot %v.", i)); Nl()
+		}
+		return 0
+	}
+	return ShowSubcommandUsage("keyslots", usage)
+}
+
+func CmdSealing(args []string) int {
+	usage := "[--keyfile <path>] " + PasswordOptionsUsage + " <enable|disable|status> <path>"
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	if ParseFlags(flags, args) != nil || flags.NArg() != 2 {
+		return ShowSubcommandUsage("sealing", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	action := flags.Arg(0)
+	file := flags.Arg(1)
+	if _, err := os.Stat(file); err != nil {
+		return ExitWithError(err, "Couldn't open journal file!")
+	}
+	switch action {
+	case "status":
+		h, err := ReadJournalHeader(file)
+		if err != nil { return ExitWithError(err, "Couldn't read journal header!") }
+		if h.PublicKey != nil {
+			Out("Sealed entries are enabled."); Nl()
+		} else {
+			Out("Sealed entries are disabled."); Nl()
+		}
+		return 0
+	case "enable", "disable":
+		keyfile, err := LoadKeyfile(*keyfilePath)
+		if err != nil { return ExitWithError(err, "Couldn't read keyfile!") }
+		PrintVersion()
+		passwd, err := ReadKey("Please enter your encryption key.", keyfile)
+		if err != nil { return ExitWithError(err, "Couldn't get password from commandline safely.") }
+		j, err := OpenJournalFile(file, passwd)
+		if err != nil { return ExitWithError(err, "Couldn't open journal file!") }
+		defer j.Close()
+		if action == "enable" {
+			err = j.EnableSealing()
+			if err != nil { return ExitWithError(err, "Couldn't enable sealed entries!") }
+		} else {
+			err = j.DisableSealing()
+			if err != nil { return ExitWithError(err, "Couldn't disable sealed entries!") }
+		}
+		err = j.Write()
+		if err != nil { return ExitWithError(err, "Couldn't write journal file!") }
+		Out(fmt.Sprintf("Sealed entries are %vd.", action)); Nl()
+		return 0
+	}
+	return ShowSubcommandUsage("sealing", usage)
+}
+
+func CmdAppend(args []string) int {
+	usage := "<path>"
+	flags := NewFlagSet()
+	if ParseFlags(flags, args) != nil || flags.NArg() != 1 {
+		return ShowSubcommandUsage("append", usage)
+	}
+	j, err := OpenJournalFileWriteOnly(flags.Arg(0))
+	if err != nil { return ExitWithError(err, "Couldn't open journal file!") }
+	defer j.Close()
+	text, err := ReadEntryFromStdin()
+	if err != nil { return ExitWithError(err, "Couldn't read entry from stdin!") }
+	if strings.TrimSpace(string(text)) == "" {
+		return ExitWithError(nil, "The entry is empty!")
+	}
+	e, err := j.NewEntry(string(text))
+	if err != nil { return ExitWithError(err, "Couldn't encrypt entry!") }
+	err = j.AddEntry(e)
+	if err != nil { return ExitWithError(err, "Couldn't add entry!") }
+	err = j.Write()
+	if err != nil { return ExitWithError(err, "Couldn't write journal file!") }
+	return 0
+}
+
+func CmdIndex(args []string) int {
+	usage := "[--keyfile <path>] " + PasswordOptionsUsage + " <enable|disable|status> <path>"
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	if ParseFlags(flags, args) != nil || flags.NArg() != 2 {
+		return ShowSubcommandUsage("index", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	action := flags.Arg(0)
+	file := flags.Arg(1)
+	if action != "enable" && action != "disable" && action != "status" {
+		return ShowSubcommandUsage("index", usage)
+	}
+	if _, err := os.Stat(file); err != nil {
+		return ExitWithError(err, "Couldn't open journal file!")
+	}
+	keyfile, err := LoadKeyfile(*keyfilePath)
+	if err != nil { return ExitWithError(err, "Couldn't read keyfile!") }
+	PrintVersion()
+	passwd, err := ReadKey("Please enter your encryption key.", keyfile)
+	if err != nil { return ExitWithError(err, "Couldn't get password from commandline safely.") }
+	j, err := OpenJournalFile(file, passwd)
+	if err != nil { return ExitWithError(err, "Couldn't open journal file!") }
+	defer j.Close()
+	switch action {
+	case "status":
+		if j.SearchIndexEnabled() {
+			Out("The search index is enabled."); Nl()
+		} else {
+			Out("The search index is disabled."); Nl()
+		}
+		return 0
+	case "enable":
+		err = j.EnableSearchIndex(func(done int, total int) {
+			Out("\r", AS_ERASE_LINE, fmt.Sprintf("[Indexing ... %v/%v]", done, total))
+		})
+		Out("\r", AS_ERASE_LINE)
+		if err != nil { return ExitWithError(err, "Couldn't enable the search index!") }
+	case "disable":
+		err = j.DisableSearchIndex()
+		if err != nil { return ExitWithError(err, "Couldn't disable the search index!") }
+	}
+	err = j.Write()
+	if err != nil { return ExitWithError(err, "Couldn't write journal file!") }
+	Out(fmt.Sprintf("The search index is %vd.", action)); Nl()
+	return 0
+}
+
+func CmdSearch(args []string) int {
+	usage := "[--keyfile <path>] " + PasswordOptionsUsage + " [--json] [--regex|--word] <path> <query>"
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	asJson := flags.Bool("json", false, "")
+	regex := flags.Bool("regex", false, "")
+	word := flags.Bool("word", false, "")
+	Output = os.Stderr
+	if ParseFlags(flags, args) != nil || flags.NArg() != 2 || (*regex && *word) {
+		return ShowSubcommandUsage("search", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	mode := SearchSubstring
+	if *regex { mode = SearchRegex }
+	if *word { mode = SearchWord }
+	_, err := CompileSearchQuery(flags.Arg(1), mode)
+	if err != nil { return ExitWithError(err, "Invalid search query!") }
+	j, code := UnlockJournal(flags.Arg(0), *keyfilePath, true)
+	if code >= 0 { return code }
+	defer j.Close()
+	hits, err := j.Search(flags.Arg(1), mode, nil)
+	if err != nil { return ExitWithError(err, "Couldn't search the journal!") }
+	if *asJson {
+		results := []EntryJson{}
+		for _, h := range hits {
+			ej := NewEntryJson(h.Timestamp, nil)
+			ej.Matches = h.Count
+			for _, sn := range h.Snippets {
+				ej.Snippets = append(ej.Snippets, sn.Before + sn.Match + sn.After)
+			}
+			results = append(results, ej)
+		}
+		WriteJson(results)
+	} else {
+		highlight := term.IsTerminal(int(os.Stdout.Fd()))
+		for _, h := range hits {
+			fmt.Println(h.Timestamp, "", time.UnixMicro(int64(h.Timestamp)).Format(EntryTimeFormat))
+			for _, sn := range h.Snippets {
+				if highlight {
+					fmt.Println("    " + sn.Before + Am(AC_SET_INVERTED) + sn.Match + Am(AC_RESET_INVERTED) + sn.After)
+				} else {
+					fmt.Println("    " + sn.Before + sn.Match + sn.After)
+				}
+			}
+		}
+	}
+	if len(hits) == 0 { return ExitNotFound }
+	return ExitOk
+}
+
+func CmdAgent(args []string) int {
+	usage := "[--timeout <duration>] [--foreground] [--keyfile <path>] " + PasswordOptionsUsage + " <start|add|list|lock|stop> [path...]"
+	flags := NewFlagSet()
+	timeout := flags.Duration("timeout", DefaultAgentTimeout, "")
+	foreground := flags.Bool("foreground", false, "")
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	if ParseFlags(flags, args) != nil || flags.NArg() < 1 {
+		return ShowSubcommandUsage("agent", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	action := flags.Arg(0)
+	files := flags.Args()[1:]
+	socket := AgentSocket()
+	if action == "start" {
+		// the passwords are read before the agent is
+		// detached from the terminal
+		js := []*JournalFile{}
+		for _, file := range files {
+			j, code := UnlockJournalWithPassword(file, *keyfilePath, true)
+			if code >= 0 { return code }
+			defer j.Close()
+			js = append(js, j)
+		}
+		if *foreground {
+			agent := NewAgent(*timeout)
+			for _, j := range js {
+				err := agent.AddJournal(j)
+				if err != nil { return ExitWithError(err, "Couldn't add the journal to the agent!") }
+			}
+			Out("Starting agent at ", socket, " ..."); Nl()
+			err := agent.Serve(socket)
+			if err != nil { return ExitWithError(err, "Couldn't start agent!") }
+			return 0
+		}
+		agent, pid, err := StartAgentProcess(socket, *timeout)
+		if err != nil { return ExitWithError(err, "Couldn't start agent!") }
+		defer agent.Close()
+		for _, j := range js {
+			err = agent.Add(j)
+			if err != nil { return ExitWithError(err, "Couldn't add the journal to the agent!") }
+		}
+		Out("Started agent at ", socket, " (PID ", pid, ")."); Nl()
+		return 0
+	}
+	if (action == "add") != (len(files) > 0) {
+		return ShowSubcommandUsage("agent", usage)
+	}
+	agent, err := ConnectAgent(socket)
+	if err != nil { return ExitWithError(err, "Couldn't connect to the agent!") }
+	defer agent.Close()
+	switch action {
+	case "add":
+		for _, file := range files {
+			j, code := UnlockJournalWithPassword(file, *keyfilePath, true)
+			if code >= 0 { return code }
+			err = agent.Add(j)
+			j.Close()
+			if err != nil { return ExitWithError(err, "Couldn't add the journal to the agent!") }
+			Out("Added ", file, " to the agent."); Nl()
+		}
+		return 0
+	case "list":
+		js, err := agent.List()
+		if err != nil { return ExitWithError(err, "Couldn't list the journals of the agent!") }
+		if len(js) == 0 { Out("The agent has no unlocked journals."); Nl() }
+		for _, aj := range js {
+			Out(aj.Path); Nl()
+		}
+		return 0
+	case "lock":
+		err = agent.Lock()
+		if err != nil { return ExitWithError(err, "Couldn't lock the agent!") }
+		Out("The agent is locked."); Nl()
+		return 0
+	case "stop":
+		err = agent.Stop()
+		if err != nil { return ExitWithError(err, "Couldn't stop the agent!") }
+		Out("The agent was stopped."); Nl()
+		return 0
+	}
+	return ShowSubcommandUsage("agent", usage)
+}
+
+// scripting
+
+type EntryJson struct {
+	Timestamp uint64 `json:"timestamp"`
+	Date string `json:"date"` // RFC 3339
+	Title string `json:"title,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+	Mood uint8 `json:"mood,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+	Deleted string `json:"deleted,omitempty"` // RFC 3339, only entries in the trash
+	Text *string `json:"text,omitempty"` // not in listings
+	Matches int `json:"matches,omitempty"` // only search results
+	Snippets []string `json:"snippets,omitempty"` // only search results
+}
+
+func NewEntryJson(ts uint64, p *EntryPayload) EntryJson {
+	// p may be nil
+	ej := EntryJson{Timestamp: ts, Date: time.UnixMicro(int64(ts)).Format(time.RFC3339)}
+	if p != nil {
+		ej.Title, ej.Tags, ej.Mood, ej.Fields = p.Title, p.Tags, p.Mood, p.Fields
+		if p.Trashed != 0 { ej.Deleted = time.UnixMicro(int64(p.Trashed)).Format(time.RFC3339) }
+	}
+	return ej
+}
+
+func WriteJson(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+func UnlockJournal(file string, keyfilePath string, readOnly bool) (*JournalFile, int) {
+	// Opens an existing journal using the agent, if it has the key,
+	// otherwise reads the password, returns a code to exit or -1 if no error.
+	// Commands that don't change the journal open it read-only, without the lock.
+	if _, err := os.Stat(file); err != nil {
+		return nil, ExitWithError(err, "Couldn't open journal file!")
+	}
+	if agent, err := ConnectAgent(AgentSocket()); err == nil {
+		j, err := OpenJournalFileWithAgent(file, agent, readOnly)
+		if err == nil { return j, -1 }
+		agent.Close()
+		if err == JournalLocked { return nil, ExitWithError(err, "Couldn't open journal file!") }
+	}
+	return UnlockJournalWithPassword(file, keyfilePath, readOnly)
+}
+
+func UnlockJournalWithPassword(file string, keyfilePath string, readOnly bool) (*JournalFile, int) {
+	// Reads the password and opens an existing journal,
+	// returns a code to exit or -1 if no error
+	if _, err := os.Stat(file); err != nil {
+		return nil, ExitWithError(err, "Couldn't open journal file!")
+	}
+	if !readOnly {
+		// check the lock first, to not ask for the password in vain
+		l, err := LockJournalFile(file)
+		if err != nil { return nil, ExitWithError(err, "Couldn't open journal file!") }
+		l.Unlock()
+	}
+	keyfile, err := LoadKeyfile(keyfilePath)
+	if err != nil { return nil, ExitWithError(err, "Couldn't read keyfile!") }
+	passwd, err := ReadKey("Please enter your encryption key.", keyfile)
+	if err != nil { return nil, ExitWithError(err, "Couldn't get password from commandline safely.") }
+	var j *JournalFile
+	if readOnly {
+		j, err = OpenJournalFileReadOnly(file, passwd)
+	} else {
+		j, err = OpenJournalFile(file, passwd)
+	}
+	if err != nil { return nil, ExitWithError(err, "Couldn't open journal file!") }
+	return j, -1
+}
+
+func ParseEntryTimestamp(j *JournalFile, s string) (uint64, error) {
+	// a timestamp in microseconds, or "latest"
+	if s == "latest" {
+		ts := j.GetLatestEntry()
+		if ts == 0 { return 0, EntryNotFound }
+		return ts, nil
+	}
+	ts, err := strconv.ParseUint(s, 10, 64)
+	if err != nil { return 0, err }
+	if !slices.Contains(j.GetEntries(), ts) { return 0, EntryNotFound }
+	return ts, nil
+}
+
+type stringList []string // repeatable flag
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+const MetadataOptionsUsage = "[--title <title>] [--tag <tag>]... [--mood <1-5>] [--field <key>=<value>]..."
+
+type MetadataFlags struct {
+	title *string
+	tags *stringList
+	mood *uint
+	fields *stringList
+}
+
+func AddMetadataFlags(flags *flag.FlagSet) *MetadataFlags {
+	mf := &MetadataFlags{
+		title: flags.String("title", "", ""),
+		tags: &stringList{},
+		mood: flags.Uint("mood", 0, ""),
+		fields: &stringList{},
+	}
+	flags.Var(mf.tags, "tag", "")
+	flags.Var(mf.fields, "field", "")
+	return mf
+}
+
+func (mf *MetadataFlags) Metadata() (EntryMetadata, error) {
+	m := EntryMetadata{Title: *mf.title, Tags: *mf.tags, Fields: map[string]string{}}
+	if *mf.mood > MaxMood { return m, InvalidMetadata }
+	m.Mood = uint8(*mf.mood)
+	for _, f := range *mf.fields {
+		k, v, found := strings.Cut(f, "=")
+		if !found { return m, InvalidMetadata }
+		m.Fields[strings.ToLower(k)] = v
+	}
+	if !m.Valid() { return m, InvalidMetadata }
+	return m, nil
+}
+
+// Entries could be much larger, but reading them from stdin
+// would buffer everything that is piped into the program.
+const MaxStdinEntrySize = 16 * 1024 * 1024
+
+func ReadEntryFromStdin() ([]byte, error) {
+	// reads until EOF, but not more than MaxStdinEntrySize
+	text, err := io.ReadAll(io.LimitReader(os.Stdin, MaxStdinEntrySize + 1))
+	if err != nil { return nil, err }
+	if len(text) > MaxStdinEntrySize {
+		clear(text)
+		return nil, EntryTooLarge
+	}
+	return text, nil
+}
+
+func CmdNew(args []string) int {
+	usage := "[--keyfile <path>] " + PasswordOptionsUsage + " [--json] [--at <date/time> [--shift]] " + MetadataOptionsUsage + " <path> <--stdin|text...>"
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	asJson := flags.Bool("json", false, "")
+	fromStdin := flags.Bool("stdin", false, "")
+	at := flags.String("at", "", "")
+	shift := flags.Bool("shift", false, "")
+	metaFlags := AddMetadataFlags(flags)
+	Output = os.Stderr
+	if ParseFlags(flags, args) != nil || flags.NArg() < 1 || (flags.NArg() > 1) == *fromStdin {
+		return ShowSubcommandUsage("new", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	m, err := metaFlags.Metadata()
+	if err != nil { return ExitWithError(err, "Invalid metadata!") }
+	t, err := ParseEntryTime(*at, time.Now())
+	if err != nil { return ExitWithError(err, "Couldn't parse --at, use e.g. \"yesterday 21:00\", \"monday 9am\" or \"2024-01-15 21:00\".") }
+	text := strings.Join(flags.Args()[1:], " ")
+	if *fromStdin {
+		b, err := ReadEntryFromStdin()
+		if err != nil { return ExitWithError(err, "Couldn't read entry from stdin!") }
+		text = string(b)
+		clear(b)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" { return ExitWithError(nil, "The entry is empty!") }
+	j, code := UnlockJournal(flags.Arg(0), *keyfilePath, false)
+	if code >= 0 { return code }
+	defer j.Close()
+	ts := uint64(t.UnixMicro())
+	if *shift { ts = j.unusedTimestamp(ts) }
+	if j.GetEntry(ts) != nil { return ExitWithError(EntryIdAlreadyExists, "Couldn't add entry, use --shift to add it at the next free time.") }
+	e, err := j.NewEntryAt(ts, text, m)
+	if err != nil { return ExitWithError(err, "Couldn't encrypt entry!") }
+	err = j.AddEntry(e)
+	if err != nil { return ExitWithError(err, "Couldn't add entry!") }
+	err = j.Write()
+	if err != nil { return ExitWithError(err, "Couldn't write journal file!") }
+	if *asJson {
+		WriteJson(NewEntryJson(e.Timestamp, &EntryPayload{EntryMetadata: m}))
+	} else {
+		fmt.Println(e.Timestamp)
+	}
+	return ExitOk
+}
+
+func CmdList(args []string) int {
+	usage := "[--keyfile <path>] " + PasswordOptionsUsage + " [--json] [--filter <filter>] <path>"
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	asJson := flags.Bool("json", false, "")
+	filterText := flags.String("filter", "", "")
+	Output = os.Stderr
+	if ParseFlags(flags, args) != nil || flags.NArg() != 1 {
+		return ShowSubcommandUsage("list", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	var filter *EntryFilter
+	if *filterText != "" {
+		f, err := ParseEntryFilter(*filterText)
+		if err != nil { return ExitWithError(err, "Invalid filter!") }
+		filter = &f
+	}
+	j, code := UnlockJournal(flags.Arg(0), *keyfilePath, true)
+	if code >= 0 { return code }
+	defer j.Close()
+	// decrypt all entries for the metadata
+	entries := map[uint64]EntryJson{}
+	var mu sync.Mutex
+	err := j.decryptEntries(j.GetEntries(), nil, func(ts uint64, p *EntryPayload) {
+		if filter != nil && !filter.Matches(&p.EntryMetadata) { return }
+		ej := NewEntryJson(ts, p)
+		mu.Lock()
+		entries[ts] = ej
+		mu.Unlock()
+	})
+	if err != nil { return ExitWithError(err, "Couldn't decrypt entries!") }
+	results := []EntryJson{}
+	for _, ts := range slices.Sorted(maps.Keys(entries)) {
+		results = append(results, entries[ts])
+	}
+	if *asJson {
+		WriteJson(results)
+	} else {
+		// tab-separated: timestamp, date, title, tags
+		for _, ej := range results {
+			fmt.Printf("%v\t%v\t%v\t%v\n", ej.Timestamp, ej.Date, ej.Title, strings.Join(ej.Tags, ","))
+		}
+	}
+	return ExitOk
+}
+
+func CmdShow(args []string) int {
+	usage := "[--keyfile <path>] " + PasswordOptionsUsage + " [--json] <path> <timestamp|latest>"
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	asJson := flags.Bool("json", false, "")
+	Output = os.Stderr
+	if ParseFlags(flags, args) != nil || flags.NArg() != 2 {
+		return ShowSubcommandUsage("show", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	j, code := UnlockJournal(flags.Arg(0), *keyfilePath, true)
+	if code >= 0 { return code }
+	defer j.Close()
+	ts, err := ParseEntryTimestamp(j, flags.Arg(1))
+	if err == EntryNotFound {
+		ExitWithError(err, "Couldn't show entry!")
+		return ExitNotFound
+	} else if err != nil {
+		return ShowSubcommandUsage("show", usage)
+	}
+	p, err := j.DecryptPayload(j.GetEntry(ts))
+	if err != nil { return ExitWithError(err, "Entry could not be decrypted!") }
+	if *asJson {
+		ej := NewEntryJson(ts, &p)
+		ej.Text = &p.Text
+		WriteJson(ej)
+	} else {
+		fmt.Println(p.Text)
+	}
+	return ExitOk
+}
+
+func CmdDelete(args []string) int {
+	usage := "[--keyfile <path>] " + PasswordOptionsUsage + " [--json] [--purge] <path> <timestamp>"
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	asJson := flags.Bool("json", false, "")
+	purge := flags.Bool("purge", false, "")
+	Output = os.Stderr
+	if ParseFlags(flags, args) != nil || flags.NArg() != 2 || flags.Arg(1) == "latest" {
+		return ShowSubcommandUsage("delete", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	j, code := UnlockJournal(flags.Arg(0), *keyfilePath, false)
+	if code >= 0 { return code }
+	defer j.Close()
+	ts, err := ParseEntryTimestamp(j, flags.Arg(1))
+	if err == EntryNotFound {
+		ExitWithError(err, "Couldn't delete entry!")
+		return ExitNotFound
+	} else if err != nil {
+		return ShowSubcommandUsage("delete", usage)
+	}
+	err = j.DeleteEntry(ts)
+	if err == nil && *purge { err = j.PurgeEntry(ts) }
+	if err != nil { return ExitWithError(err, "Couldn't delete entry!") }
+	err = j.Write()
+	if err != nil { return ExitWithError(err, "Couldn't write journal file!") }
+	if *asJson { WriteJson(NewEntryJson(ts, nil)) }
+	return ExitOk
+}
+
+func CmdTrash(args []string) int {
+	usage := "[--keyfile <path>] " + PasswordOptionsUsage + " [--json] [--older-than <days>] <list|restore|purge|empty> <path> [timestamp]"
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	asJson := flags.Bool("json", false, "")
+	olderThan := flags.Uint("older-than", 0, "") // days, only for empty
+	Output = os.Stderr
+	if ParseFlags(flags, args) != nil || flags.NArg() < 2 {
+		return ShowSubcommandUsage("trash", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	action := flags.Arg(0)
+	switch action {
+	case "list", "empty":
+		if flags.NArg() != 2 { return ShowSubcommandUsage("trash", usage) }
+	case "restore", "purge":
+		if flags.NArg() != 3 { return ShowSubcommandUsage("trash", usage) }
+	default:
+		return ShowSubcommandUsage("trash", usage)
+	}
+	j, code := UnlockJournal(flags.Arg(1), *keyfilePath, action == "list")
+	if code >= 0 { return code }
+	defer j.Close()
+	switch action {
+	case "list":
+		entries := map[uint64]EntryJson{}
+		var mu sync.Mutex
+		err := j.decryptEntries(j.GetTrash(), nil, func(ts uint64, p *EntryPayload) {
+			ej := NewEntryJson(ts, p)
+			mu.Lock()
+			entries[ts] = ej
+			mu.Unlock()
+		})
+		if err != nil { return ExitWithError(err, "Couldn't decrypt entries!") }
+		results := []EntryJson{}
+		for _, ts := range slices.Sorted(maps.Keys(entries)) {
+			results = append(results, entries[ts])
+		}
+		if *asJson {
+			WriteJson(results)
+		} else {
+			// tab-separated: timestamp, date, date of deletion, title
+			for _, ej := range results {
+				fmt.Printf("%v\t%v\t%v\t%v\n", ej.Timestamp, ej.Date, ej.Deleted, ej.Title)
+			}
+		}
+		return ExitOk
+	case "empty":
+		purged, err := j.EmptyTrash(time.Duration(*olderThan) * 24 * time.Hour)
+		if err == nil { err = j.Write() }
+		if err != nil { return ExitWithError(err, "Couldn't empty the trash!") }
+		if *asJson {
+			results := []EntryJson{}
+			for _, ts := range purged { results = append(results, NewEntryJson(ts, nil)) }
+			WriteJson(results)
+		}
+		return ExitOk
+	}
+	ts, err := strconv.ParseUint(flags.Arg(2), 10, 64)
+	if err != nil { return ShowSubcommandUsage("trash", usage) }
+	if action == "restore" {
+		err = j.RestoreEntry(ts)
+	} else {
+		err = j.PurgeEntry(ts)
+	}
+	if err == EntryNotFound || err == EntryNotTrashed {
+		ExitWithError(err, "Couldn't " + action + " entry!")
+		return ExitNotFound
+	} else if err != nil {
+		return ExitWithError(err, "Couldn't " + action + " entry!")
+	}
+	err = j.Write()
+	if err != nil { return ExitWithError(err, "Couldn't write journal file!") }
+	if *asJson { WriteJson(NewEntryJson(ts, nil)) }
+	return ExitOk
+}
+
+func CmdExport(args []string) int {
+	usage := "[--keyfile <path>] " + PasswordOptionsUsage + " [--format <text|markdown|json>] [--json] [--iso] [--output <path> [--per-entry]] <path>"
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	format := flags.String("format", ExportText, "")
+	asJson := flags.Bool("json", false, "") // same as --format json
+	iso := flags.Bool("iso", false, "")
+	output := flags.String("output", "", "")
+	perEntry := flags.Bool("per-entry", false, "")
+	Output = os.Stderr
+	if ParseFlags(flags, args) != nil || flags.NArg() != 1 || (*perEntry && *output == "") {
+		return ShowSubcommandUsage("export", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	if *asJson { *format = ExportJson }
+	timeFormat := EntryTimeFormat
+	if *iso { timeFormat = time.RFC3339 }
+	x, err := NewExporter(*format, timeFormat)
+	if err != nil { return ExitWithError(err, "Valid formats are " + strings.Join(ExportFormats, ", ") + ".") }
+	j, code := UnlockJournal(flags.Arg(0), *keyfilePath, true)
+	if code >= 0 { return code }
+	defer j.Close()
+	switch {
+	case *perEntry:
+		n, err := x.ExportEntries(j, *output)
+		if err != nil { return ExitWithError(err, "Couldn't export the entries!") }
+		Out(fmt.Sprintf("Exported %v entries to %v.", n, *output)); Nl()
+	case *output != "":
+		err = x.ExportFile(j, *output)
+		if err != nil { return ExitWithError(err, "Couldn't export the entries!") }
+	default:
+		err = x.Export(j, os.Stdout)
+		if err != nil { return ExitWithError(err, "Couldn't export the entries!") }
+	}
+	return ExitOk
+}
+
+func CmdImport(args []string) int {
+	usage := "[--keyfile <path>] " + PasswordOptionsUsage + " [--shift] <jrnl|dayone|markdown> <path> <file|directory>"
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	pwFlags := AddPasswordFlags(flags)
+	shift := flags.Bool("shift", false, "")
+	Output = os.Stderr
+	if ParseFlags(flags, args) != nil || flags.NArg() != 3 || !slices.Contains(ImportFormats, flags.Arg(0)) {
+		return ShowSubcommandUsage("import", usage)
+	}
+	if err := pwFlags.Use(); err != nil { return ExitWithError(err, "Invalid password options!") }
+	entries, skipped, err := ParseImport(flags.Arg(0), flags.Arg(2))
+	if err != nil { return ExitWithError(err, "Couldn't read the entries to import!") }
+	for _, s := range skipped {
+		Out("Skipped ", s); Nl()
+	}
+	j, code := UnlockJournal(flags.Arg(1), *keyfilePath, false)
+	if code >= 0 { return code }
+	defer j.Close()
+	r, err := j.Import(entries, *shift)
+	if err != nil { return ExitWithError(err, "Couldn't import the entries!") }
+	for _, c := range r.Collisions {
+		Out("Skipped ", c.Entry.Source, " (", c.Entry.Time.Format(EntryTimeFormat), "): ", c.Err); Nl()
+	}
+	if len(r.Imported) > 0 {
+		err = j.Write()
+		if err != nil { return ExitWithError(err, "Couldn't write journal file!") }
+	}
+	Out(fmt.Sprintf("Imported %v of %v entries.", len(r.Imported), len(entries))); Nl()
+	if len(r.Collisions) > 0 || len(skipped) > 0 { return ExitError }
+	return ExitOk
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestCliHelpers(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	t.Run("MetadataFlags", func(t *testing.T) {
+		flags := NewFlagSet()
+		mf := AddMetadataFlags(flags)
+		err := flags.Parse([]string{"--tag", "a", "--tag", "b", "--mood", "2", "--field", "Client=ACME", "--title", "T"})
+		if err != nil { t.Fatal(err) }
+		m, err := mf.Metadata()
+		if err != nil { t.Fatal("Could not get metadata; ", err) }
+		if m.Title != "T" || len(m.Tags) != 2 || m.Mood != 2 || m.Fields["client"] != "ACME" {
+			t.Errorf("Unexpected metadata %v", m)
+		}
+		flags = NewFlagSet()
+		mf = AddMetadataFlags(flags)
+		flags.Parse([]string{"--field", "novalue"})
+		if _, err := mf.Metadata(); err != InvalidMetadata { t.Error("Invalid field was accepted!") }
+	})
+	t.Run("ParseFlags", func(t *testing.T) {
+		flags := NewFlagSet()
+		stdin := flags.Bool("stdin", false, "")
+		file := flags.String("password-file", "", "")
+		err := ParseFlags(flags, []string{"a", "--stdin", "b", "--password-file", "f", "--", "--c", "-d"})
+		if err != nil { t.Fatal(err) }
+		if !*stdin || *file != "f" || !slices.Equal(flags.Args(), []string{"a", "b", "--c", "-d"}) {
+			t.Errorf("Unexpected flags %v %q %q", *stdin, *file, flags.Args())
+		}
+		if ParseFlags(NewFlagSet(), []string{"a", "--unknown"}) == nil { t.Error("Unknown flag after a positional argument was accepted!") }
+	})
+	t.Run("FlagsAfterPath", func(t *testing.T) {
+		// journal new <path> --stdin --password-file <file>
+		t.Setenv("JOURNAL_AGENT_SOCK", "/tmp/journal_test_no_agent.sock")
+		defer func() { passwordSource = nil }()
+		os.Remove(JournalTestFile)
+		CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+		pwFile := JournalTestFile + ".password"
+		defer os.Remove(pwFile)
+		os.WriteFile(pwFile, []byte("secureTestP4ssw0rd!\n"), 0600)
+		in, _ := os.CreateTemp("", "journal_test_stdin")
+		defer os.Remove(in.Name())
+		in.WriteString("Written after the path")
+		in.Seek(0, 0)
+		stdin := os.Stdin
+		os.Stdin = in
+		defer func() { os.Stdin = stdin }()
+		if code := CmdNew([]string{JournalTestFile, "--stdin", "--password-file", pwFile}); code != ExitOk {
+			t.Fatal("Expected exit code ", ExitOk, ", got ", code)
+		}
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open test journal; ", err) }
+		defer j.Close()
+		ts, err := ParseEntryTimestamp(j, "latest")
+		if err != nil { t.Fatal("The entry was not added; ", err) }
+		if txt, _ := j.Decrypt(j.GetEntry(ts)); txt != "Written after the path" { t.Errorf("Unexpected text %q", txt) }
+	})
+	t.Run("StdinTooLarge", func(t *testing.T) {
+		in, _ := os.CreateTemp("", "journal_test_stdin")
+		defer os.Remove(in.Name())
+		in.Truncate(MaxStdinEntrySize + 1)
+		stdin := os.Stdin
+		os.Stdin = in
+		defer func() { os.Stdin = stdin }()
+		if _, err := ReadEntryFromStdin(); err != EntryTooLarge { t.Errorf("Expected %v, got %v", EntryTooLarge, err) }
+		in.Truncate(MaxStdinEntrySize)
+		in.Seek(0, 0)
+		if b, err := ReadEntryFromStdin(); err != nil || len(b) != MaxStdinEntrySize { t.Errorf("Could not read %v bytes; %v", len(b), err) }
+	})
+	t.Run("ParseEntryTimestamp", func(t *testing.T) {
+		os.Remove(JournalTestFile)
+		CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open test journal; ", err) }
+		defer j.Close()
+		if _, err := ParseEntryTimestamp(j, "latest"); err != EntryNotFound { t.Error("Found latest entry in empty journal!") }
+		e, _ := j.NewEntry("entry")
+		j.AddEntry(e)
+		if ts, err := ParseEntryTimestamp(j, "latest"); err != nil || ts != e.Timestamp { t.Error("Latest entry was not found; ", err) }
+		if _, err := ParseEntryTimestamp(j, "0"); err != EntryNotFound { t.Error("Reserved entry was found!") }
+		if _, err := ParseEntryTimestamp(j, "x"); err == nil { t.Error("Invalid timestamp was accepted!") }
+	})
+}
@@ -3,16 +3,22 @@ package main
 // Copyright (c) 2026, Julian Müller (ChaoticByte)
 
 import (
+	"bytes"
+	"crypto/hmac"
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
+	"runtime"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/awnumar/memguard"
+	"golang.org/x/crypto/chacha20poly1305"
 )
 
 
@@ -23,22 +29,62 @@ var FilepathIsDirectory = errors.New("The given filepath points to a directory!"
 var JournalClosed = errors.New("Journal already closed, can't access data.")
 var UnknownFileReadErr = errors.New("Unknown file read error")
 var FileModifiedExternally = errors.New("The file was modified by another process since last read/write!")
+var CorruptedJournalFile = errors.New("The journal file is corrupted!")
+var WrongPassword = errors.New("Wrong password!")
+var UnknownEntryKind = errors.New("Unknown entry kind!")
+var EntryTooLarge = errors.New("The entry is too large!")
+var ReservedTimestamp = errors.New("This timestamp is reserved!")
 
 
 // Journal Format Version -> App Version
 // 0 ->     < 1.0.0
 // 1 -> since 1.0.0
-const JournalFormatVersion = uint8(1)
+// 2 -> unreleased (file header)
+// 3 -> unreleased (master key)
+// 4 -> unreleased (entry kinds)
+// 5 -> unreleased (journal id, associated data)
+// 6 -> unreleased (entry payload fields, revisions)
+// 7 -> unreleased (search index)
+// 8 -> unreleased (trash)
+// 9 -> unreleased (tombstones, header mac)
+const JournalFormatVersion = uint8(9)
+
+// Older journal files have to be migrated before they can be opened.
+const MinReadableJournalVersion = uint8(9)
 
 const JournalFileMode = 0o644
 
+// Since version 2, the journal file starts with a magic number,
+// followed by the version, the header length, the header and
+// a crc32 checksum of all bytes before.
+var JournalMagic = [4]byte{'J', 'R', 'N', 'L'}
+
+// version 1
 const JournalPos_Version = 0
 const JournalPos_Entries = 1
 
+// since version 2
+const JournalV2Pos_Magic = 0
+const JournalV2Pos_Version = 4
+const JournalV2Pos_HeaderLen = 5
+const JournalV2Pos_Header = 9
+
 type JournalFile struct {
 	Version uint8
 	Filepath string
+	Header JournalHeader // only used since version 2
+	key *memguard.Enclave // master key
+	keySlot int // the key slot that was used to unlock the master key
+	privateKey *memguard.Enclave // only if sealed entries are enabled
+	writeOnly bool // opened without a password, see OpenJournalFileWriteOnly
+	readOnly bool // opened without the lock, see OpenJournalFileReadOnly
+	lock *JournalLock // see lock.go
+	index *SearchIndex // only if the search index is enabled and unlocked
+	agent *AgentClient // only if the journal was unlocked by the agent, see agent.go
+	sealedPayloads map[uint64]*memguard.Enclave // unwritten entries sealed in write-only mode, see merge.go
 	entries map[uint64]EncryptedEntry
+	base map[uint64]EncryptedEntry // the entries at the last read/write, see merge.go
+	baseHeader []byte // the serialized header at the last read/write
 	needWrite bool
 	closed bool
 	statLastModTime time.Time
@@ -47,8 +93,9 @@ type JournalFile struct {
 func (j *JournalFile) GetEntries() []uint64 {
 	if j.closed { return []uint64{} }
 	es := []uint64{}
-	for ts := range j.entries {
-		if ts != 0 { // filter out reserved entry 0
+	for ts, e := range j.entries {
+		// filter out reserved entries, old revisions and the trash
+		if ts != 0 && (e.Kind == EntryKind_Text || e.Kind == EntryKind_Sealed) {
 			es = append(es, ts)
 		}
 	}
@@ -104,23 +151,28 @@ func (j *JournalFile) GetEntry(ts uint64) *EncryptedEntry {
 
 func (j *JournalFile) AddEntry(e *EncryptedEntry) error {
 	if j.closed { return JournalClosed }
+	if j.readOnly { return JournalReadOnly }
 	if _, exists := j.entries[e.Timestamp]; exists {
 		return EntryIdAlreadyExists
 	}
+	// entries that can't be indexed now (without the password)
+	// are indexed later
+	if e.Kind == EntryKind_Text || e.Kind == EntryKind_Sealed {
+		err := j.indexEntry(e)
+		if err != nil { return err }
+	}
 	j.entries[e.Timestamp] = *e
 	j.needWrite = true
 	return nil
 }
 
-func (j *JournalFile) DeleteEntry(ts uint64) error {
-	if j.closed { return JournalClosed }
-	delete(j.entries, ts)
-	j.needWrite = true
-	return nil
-}
-
 func (j *JournalFile) Write() error {
 	if j.closed { return JournalClosed }
+	if j.readOnly {
+		// changes of the search index are discarded
+		if j.needWrite { return JournalReadOnly }
+		return nil
+	}
 	// check if the file was modified since the last check
 	mod, err := j.CheckIfExternallyModified()
 	if err != nil { 
@@ -131,32 +183,44 @@ func (j *JournalFile) Write() error {
 	if mod {
 		return FileModifiedExternally
 	}
+	err = j.storeSearchIndex()
+	if err != nil { return err }
 	// write to file, if j.need_write
 	if j.needWrite {
-		// write to temporary file first, to prevent corrupted files
-		tmp := fmt.Sprintf("%s.tmp_%v", j.Filepath, time.Now().UnixMicro())
-		fTmp, err := os.OpenFile(tmp, os.O_WRONLY | os.O_CREATE, JournalFileMode)
-		if err != nil { return err }
-		_, err = fTmp.Write([]byte{j.Version})
+		data, err := j.encode()
 		if err != nil { return err }
-		es := []*EncryptedEntry{}
-		for _, v := range j.entries {
-			es = append(es, &v)
-		}
-		entryData := SerializeEntries(es)
-		_, err = fTmp.Write(entryData)
+		err = writeFileAtomic(j.Filepath, data)
 		if err != nil { return err }
-		// move temporary file to real file
-		err = os.Rename(tmp, j.Filepath)
 		j.needWrite = false
+		j.updateBase()
 	}
 	err = j.updateLastModifiedTime()
 	return err
 }
 
+func writeFileAtomic(file string, data []byte) error {
+	// write to temporary file first, to prevent corrupted files
+	tmp := fmt.Sprintf("%s.tmp_%v", file, time.Now().UnixMicro())
+	fTmp, err := os.OpenFile(tmp, os.O_WRONLY | os.O_CREATE, JournalFileMode)
+	if err != nil { return err }
+	_, err = fTmp.Write(data)
+	fTmp.Close()
+	if err != nil { return err }
+	// move temporary file to real file
+	return os.Rename(tmp, file)
+}
+
 func (j *JournalFile) Close() {
 	j.Write()
 	j.closed = true
+	j.key = nil
+	j.privateKey = nil
+	if j.agent != nil { j.agent.Close() }
+	j.agent = nil
+	j.index = nil
+	j.sealedPayloads = nil
+	if j.lock != nil { j.lock.Unlock() }
+	j.lock = nil
 }
 
 func (j *JournalFile) CheckIfExternallyModified() (modified bool, err error) {
@@ -179,42 +243,130 @@ func (j *JournalFile) read() error {
 	f, err := os.OpenFile(j.Filepath, os.O_RDONLY, JournalFileMode)
 	if err != nil { return err }
 	data, err := io.ReadAll(f)
-	j.Version = data[0]
-	// Check if version is supported
-	if j.Version != JournalFormatVersion {
+	f.Close()
+	if err != nil { return err }
+	err = j.decode(data)
+	if err != nil { return err }
+	j.updateBase()
+	err = j.updateLastModifiedTime()
+	return err
+}
+
+func (j *JournalFile) encode() ([]byte, error) {
+	// the header MAC is updated, except in write-only
+	// mode, which can't change the header
+	if !j.writeOnly {
+		mac, err := j.headerMac(j.Header.serializeFields())
+		if err != nil { return nil, err }
+		j.Header.Mac = mac
+	}
+	es := []*EncryptedEntry{}
+	for _, v := range j.entries {
+		es = append(es, &v)
+	}
+	return assembleJournalData(j.Version, &j.Header, es), nil
+}
+
+func (j *JournalFile) decode(data []byte) error {
+	version, err := journalVersion(data)
+	if err != nil { return err }
+	j.Version = version
+	if j.Version > JournalFormatVersion {
 		return UnsupportedJournalVersion
+	} else if j.Version < MinReadableJournalVersion {
+		return JournalNeedsMigration
 	}
+	_, h, es, err := parseJournalData(data)
+	if err != nil { return err }
+	err = h.CheckSupported()
+	if err != nil { return err }
+	if len(h.KeySlots) < 1 || h.Id == [16]byte{} { return CorruptedJournalHeader }
+	j.Header = h
 	// read entries
 	j.entries = map[uint64]EncryptedEntry{}
-	es := DeserializeEntries(data[JournalPos_Entries:])
 	for _, e := range es {
 		j.entries[e.Timestamp] = *e
 	}
-	err = j.updateLastModifiedTime()
-	return err
+	return nil
+}
+
+func ReadJournalHeader(file string) (JournalHeader, error) {
+	// read the (unencrypted) header without opening the journal
+	data, err := os.ReadFile(file)
+	if err != nil { return JournalHeader{}, err }
+	version, h, _, err := parseJournalData(data)
+	if err != nil { return h, err }
+	if version > JournalFormatVersion {
+		return h, UnsupportedJournalVersion
+	} else if version < MinReadableJournalVersion {
+		return h, JournalNeedsMigration
+	}
+	return h, nil
+}
+
+func journalVersion(data []byte) (uint8, error) {
+	if bytes.HasPrefix(data, JournalMagic[:]) {
+		// since version 2
+		if len(data) <= JournalV2Pos_Version { return 0, CorruptedJournalFile }
+		if data[JournalV2Pos_Version] < 2 { return 0, CorruptedJournalFile }
+		return data[JournalV2Pos_Version], nil
+	}
+	if len(data) <= JournalPos_Version { return 0, CorruptedJournalFile }
+	return data[JournalPos_Version], nil
+}
+
+func assembleJournalData(version uint8, h *JournalHeader, es []*EncryptedEntry) []byte {
+	b := []byte{}
+	if version == 1 {
+		b = append(b, version)
+	} else {
+		// since version 2
+		header := h.Serialize()
+		b = append(b, JournalMagic[:]...)
+		b = append(b, version)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(header)))
+		b = append(b, header...)
+		b = binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+	}
+	b = append(b, SerializeEntries(es, version)...)
+	return b
+}
+
+func parseJournalData(data []byte) (version uint8, h JournalHeader, es []*EncryptedEntry, err error) {
+	// Splits the journal file data into the version, header (since version 2) and entries.
+	// The version is not checked, this is up to the caller.
+	version, err = journalVersion(data)
+	if err != nil { return version, h, es, err }
+	entryData := []byte{}
+	if version >= 2 {
+		if len(data) < JournalV2Pos_Header { return version, h, es, CorruptedJournalFile }
+		headerLen := int(binary.BigEndian.Uint32(data[JournalV2Pos_HeaderLen:JournalV2Pos_Header]))
+		headerEnd := JournalV2Pos_Header + headerLen
+		if len(data) < headerEnd + 4 { return version, h, es, CorruptedJournalHeader }
+		checksum := binary.BigEndian.Uint32(data[headerEnd:headerEnd+4])
+		if checksum != crc32.ChecksumIEEE(data[:headerEnd]) {
+			return version, h, es, CorruptedJournalHeader
+		}
+		h, err = DeserializeHeader(data[JournalV2Pos_Header:headerEnd])
+		if err != nil { return version, h, es, err }
+		entryData = data[headerEnd+4:]
+	} else {
+		// version 1, without header
+		entryData = data[JournalPos_Entries:]
+	}
+	es = DeserializeEntries(entryData, version)
+	return version, h, es, nil
 }
 
 
 func OpenJournalFile(file string, password *memguard.Enclave) (*JournalFile, error) {
+	// opens (or creates) the journal and locks it, see lock.go
 	j := JournalFile{}
 	j.Filepath = file
 	// check file
 	fileinfo, err := os.Stat(j.Filepath)
-	if os.IsNotExist(err) {
-		// create reserved entry 0
-		e := &EncryptedEntry{Timestamp: 0}
-		cipherText, salt, noncePfx, err := EncryptText(password, rand.Text(), e.Timestamp)
-		if err != nil { return nil, err }
-		e.EncryptedText = cipherText
-		e.Salt = salt
-		e.NoncePfx = noncePfx
-		// init journal
-		j.Version = JournalFormatVersion
-		j.entries = map[uint64]EncryptedEntry{}
-		err = j.AddEntry(e); if err != nil { return &j, err }
-		j.needWrite = true
-		err = j.Write(); if err != nil { return &j, err }
-	} else {
+	exists := !os.IsNotExist(err)
+	if exists {
 		if err != nil { return &j, err }
 		if fileinfo == nil {
 			return &j, UnknownFileReadErr
@@ -222,58 +374,303 @@ func OpenJournalFile(file string, password *memguard.Enclave) (*JournalFile, err
 			return &j, FilepathIsDirectory
 		}
 	}
-	err = j.read(); if err != nil { return &j, err }
-	// check password by decrypting reserved entry 0
-	_, err = j.GetEntry(0).Decrypt(password)
+	j.lock, err = LockJournalFile(file)
+	if err != nil { return &j, err }
+	if !exists {
+		err = createJournalFile(file, password, DefaultKdfParams)
+	}
+	if err == nil { err = j.open(password) }
+	if err != nil {
+		j.lock.Unlock()
+		j.lock = nil
+	}
 	return &j, err
 }
 
+func OpenJournalFileReadOnly(file string, password *memguard.Enclave) (*JournalFile, error) {
+	// Opens an existing journal without locking it,
+	// e.g. if it is locked by another process.
+	j := JournalFile{}
+	j.Filepath = file
+	j.readOnly = true
+	fileinfo, err := os.Stat(j.Filepath)
+	if err != nil { return &j, err }
+	if fileinfo.IsDir() { return &j, FilepathIsDirectory }
+	return &j, j.open(password)
+}
+
+func (j *JournalFile) open(password *memguard.Enclave) error {
+	err := j.read(); if err != nil { return err }
+	// unwrap the master key using the password
+	j.keySlot, j.key, err = j.unlockKeySlot(password)
+	if err != nil { return err }
+	// check master key by decrypting reserved entry 0
+	e0 := j.GetEntry(0)
+	if e0 == nil { return CorruptedJournalFile }
+	_, err = e0.Decrypt(j.key, j.associatedData(e0))
+	if err != nil { return err }
+	err = j.checkHeaderMac(&j.Header)
+	if err != nil { return err }
+	// unwrap the private key, if sealed entries are enabled
+	err = j.unlockPrivateKey()
+	if err != nil { return err }
+	return j.loadSearchIndex()
+}
+
+func (j *JournalFile) ReadOnly() bool {
+	return j.readOnly
+}
 
-const MaxEntrySize = uint32(4294967295) // (2^32)-1
+func CreateJournalFile(file string, password *memguard.Enclave, kdf KdfParams) error {
+	// creates a new journal file, while holding the lock (see lock.go)
+	l, err := LockJournalFile(file)
+	if err != nil { return err }
+	defer l.Unlock()
+	return createJournalFile(file, password, kdf)
+}
+
+func createJournalFile(file string, password *memguard.Enclave, kdf KdfParams) error {
+	if !kdf.Valid() { return InvalidKdfParams }
+	j := JournalFile{}
+	j.Filepath = file
+	j.Version = JournalFormatVersion
+	j.Header = NewJournalHeader()
+	j.Header.Kdf = kdf
+	j.key = NewMasterKey()
+	slot, err := WrapMasterKey(password, j.key, j.Header.Kdf)
+	if err != nil { return err }
+	j.Header.KeySlots = []KeySlot{slot}
+	j.entries = map[uint64]EncryptedEntry{}
+	// create reserved entry 0
+	e := &EncryptedEntry{Timestamp: 0, Kind: EntryKind_Text}
+	err = j.encryptPayload(e, &EntryPayload{Text: rand.Text()})
+	if err != nil { return err }
+	err = j.AddEntry(e); if err != nil { return err }
+	data, err := j.encode()
+	if err != nil { return err }
+	// never overwrite an existing file
+	f, err := os.OpenFile(j.Filepath, os.O_WRONLY | os.O_CREATE | os.O_EXCL, JournalFileMode)
+	if err != nil { return err }
+	_, err = f.Write(data)
+	f.Close()
+	return err
+}
+
+func (j *JournalFile) NewEntry(text string) (*EncryptedEntry, error) {
+	return j.NewEntryWithMetadata(text, EntryMetadata{})
+}
+
+func (j *JournalFile) NewEntryWithMetadata(text string, m EntryMetadata) (*EncryptedEntry, error) {
+	return j.NewEntryAt(uint64(time.Now().UnixMicro()), text, m)
+}
+
+func (j *JournalFile) NewEntryAt(ts uint64, text string, m EntryMetadata) (*EncryptedEntry, error) {
+	// creates an entry with the given timestamp (in microseconds),
+	// it is not checked whether the timestamp is already in use
+	if j.closed { return nil, JournalClosed }
+	if !m.Valid() { return nil, InvalidMetadata }
+	if ts <= SearchIndexTimestamp { return nil, ReservedTimestamp }
+	p := EntryPayload{Text: text, EntryMetadata: m}
+	if j.writeOnly {
+		e := &EncryptedEntry{Timestamp: ts, Kind: EntryKind_Sealed}
+		data := p.Serialize()
+		err := e.Seal(string(data), j.Header.PublicKey, j.associatedData(e))
+		if err != nil { return e, err }
+		// kept until the entry was written, so it can be
+		// sealed again if it has to be moved by a merge
+		if j.sealedPayloads == nil { j.sealedPayloads = map[uint64]*memguard.Enclave{} }
+		j.sealedPayloads[ts] = memguard.NewEnclave(data)
+		return e, nil
+	}
+	e := &EncryptedEntry{Timestamp: ts, Kind: EntryKind_Text}
+	return e, j.encryptPayload(e, &p)
+}
+
+func (j *JournalFile) Decrypt(e *EncryptedEntry) (string, error) {
+	p, err := j.DecryptPayload(e)
+	return p.Text, err
+}
+
+func (j *JournalFile) DecryptPayload(e *EncryptedEntry) (EntryPayload, error) {
+	if j.closed { return EntryPayload{}, JournalClosed }
+	if j.writeOnly { return EntryPayload{}, JournalWriteOnly }
+	data, err := j.decryptEntry(e)
+	if err != nil { return EntryPayload{}, err }
+	return DeserializePayload([]byte(data))
+}
+
+func (j *JournalFile) decryptEntry(e *EncryptedEntry) (string, error) {
+	// decrypts the entry with the master key or private key, or using the agent
+	switch e.Kind {
+	case EntryKind_Text, EntryKind_Revision, EntryKind_Index, EntryKind_Trashed, EntryKind_Tombstone:
+		if j.agent != nil { return j.agent.decryptEntry(&j.Header, e, j.associatedData(e)) }
+		return e.Decrypt(j.key, j.associatedData(e))
+	case EntryKind_Sealed:
+		if !j.SealingEnabled() { return "", SealingNotEnabled }
+		if j.agent != nil { return j.agent.decryptEntry(&j.Header, e, j.associatedData(e)) }
+		if j.privateKey == nil { return "", SealingNotEnabled }
+		return e.Open(j.privateKey, j.associatedData(e))
+	}
+	return "", UnknownEntryKind
+}
+
+func (j *JournalFile) encryptEntry(e *EncryptedEntry, text string) error {
+	// encrypts the entry with the master key, or using the agent
+	if j.agent != nil { return j.agent.encryptEntry(j.Header.Id, e, text, j.associatedData(e)) }
+	return e.Encrypt(text, j.key, j.associatedData(e))
+}
+
+func (j *JournalFile) decryptEntries(tss []uint64, progress func(done int, total int), fn func(ts uint64, p *EntryPayload)) error {
+	// Decrypts the entries in parallel and calls fn for each of them,
+	// fn is called concurrently. Returns the first error.
+	// progress is called after each decrypted entry, if not nil.
+	var firstErr error
+	var mu sync.Mutex
+	done := 0
+	queue := make(chan *EncryptedEntry)
+	wg := sync.WaitGroup{}
+	for range runtime.NumCPU() {
+		wg.Go(func() {
+			for e := range queue {
+				p, err := j.DecryptPayload(e)
+				if err == nil { fn(e.Timestamp, &p) }
+				p.Text = ""
+				mu.Lock()
+				if err != nil && firstErr == nil { firstErr = err }
+				done++
+				if progress != nil { progress(done, len(tss)) }
+				mu.Unlock()
+			}
+		})
+	}
+	for _, ts := range tss {
+		e := j.GetEntry(ts)
+		if e != nil { queue <- e }
+	}
+	close(queue)
+	wg.Wait()
+	return firstErr
+}
+
+func (j *JournalFile) encryptPayload(e *EncryptedEntry, p *EntryPayload) error {
+	// encrypts the payload with the master key
+	return j.encryptEntry(e, string(p.Serialize()))
+}
+
+func (j *JournalFile) associatedData(e *EncryptedEntry) []byte {
+	return e.AssociatedData(j.Header.Id, j.Version)
+}
+
+func (j *JournalFile) headerMac(data []byte) ([]byte, error) {
+	// computes the header MAC with the master key, or using the agent
+	if j.agent != nil { return j.agent.headerMac(j.Header.Id, data) }
+	return HeaderMac(j.key, data)
+}
+
+func (j *JournalFile) checkHeaderMac(h *JournalHeader) error {
+	if h.Mac == nil { return CorruptedJournalHeader }
+	mac, err := j.headerMac(h.macData)
+	if err != nil { return err }
+	if !hmac.Equal(mac, h.Mac) { return CorruptedJournalHeader }
+	return nil
+}
+
+
+const MaxEntrySize = uint32(4294967295) // (2^32)-1, size of the ciphertext
+
+// Maximum size of the (serialized) content of an entry, the same
+// for all entry kinds, so entries can be converted between them
+const MaxEntryContentSize = uint64(MaxEntrySize) - chacha20poly1305.Overhead - PublicKeyLength
+
+// Entry kinds (since version 4)
+const (
+	EntryKind_Text = uint8(0)   // encrypted with a key derived from the master key
+	EntryKind_Sealed = uint8(1) // sealed to the public key of the journal
+	EntryKind_Revision = uint8(2) // older revision of an entry, encrypted like EntryKind_Text (since version 6)
+	EntryKind_Index = uint8(3)    // the search index, encrypted like EntryKind_Text (since version 7)
+	EntryKind_Trashed = uint8(4)  // deleted entry in the trash, encrypted like EntryKind_Text (since version 8)
+	EntryKind_Tombstone = uint8(5) // permanently deleted entry without content, encrypted like EntryKind_Text (since version 9)
+)
 
 type EncryptedEntry struct {
 	Timestamp uint64  // Unix time in microseconds, works until year 294246
+	Kind uint8
 	Salt [12]byte
 	NoncePfx [16]byte // Nonce = random 16 bytes prefix + 8 byte timestamp
 	EncryptedText []byte
 }
 
-func (e *EncryptedEntry) Decrypt(password *memguard.Enclave) (string, error) {
-	txt, err := DecryptText(password, e.EncryptedText, e.Salt, e.NoncePfx, e.Timestamp)
+func (e *EncryptedEntry) Encrypt(text string, key *memguard.Enclave, ad []byte) error {
+	if uint64(len(text)) > MaxEntryContentSize { return EntryTooLarge }
+	ct, s, n, err := EncryptText(key, text, e.Timestamp, ad)
+	if err != nil { return err }
+	e.EncryptedText = ct
+	e.Salt = s
+	e.NoncePfx = n
+	return nil
+}
+
+func (e *EncryptedEntry) Seal(text string, publicKey []byte, ad []byte) error {
+	if uint64(len(text)) > MaxEntryContentSize { return EntryTooLarge }
+	ct, s, n, err := SealText(publicKey, text, e.Timestamp, ad)
+	if err != nil { return err }
+	e.EncryptedText = ct
+	e.Salt = s
+	e.NoncePfx = n
+	return nil
+}
+
+func (e *EncryptedEntry) Decrypt(key *memguard.Enclave, ad []byte) (string, error) {
+	txt, err := DecryptText(key, e.EncryptedText, e.Salt, e.NoncePfx, e.Timestamp, ad)
 	return txt, err
 }
 
+func (e *EncryptedEntry) Open(privateKey *memguard.Enclave, ad []byte) (string, error) {
+	txt, err := OpenSealedText(privateKey, e.EncryptedText, e.Salt, e.NoncePfx, e.Timestamp, ad)
+	return txt, err
+}
+
+func (e *EncryptedEntry) AssociatedData(journalId [16]byte, version uint8) []byte {
+	// the entry metadata is authenticated since version 5
+	if version < 5 { return nil }
+	ad := []byte{}
+	ad = append(ad, journalId[:]...)
+	ad = append(ad, e.Kind)
+	ad = binary.BigEndian.AppendUint64(ad, e.Timestamp)
+	return ad
+}
+
 func (e *EncryptedEntry) EtLength() uint32 {
 	return uint32(len(e.EncryptedText))
 }
 
-func NewEncryptedEntry(text string, password *memguard.Enclave) (*EncryptedEntry, error) {
+func NewEncryptedEntry(text string, key *memguard.Enclave, journalId [16]byte, version uint8) (*EncryptedEntry, error) {
 	e := EncryptedEntry{}
-	if uint32(len(text)) > MaxEntrySize {
-		text = text[:MaxEntrySize]
-	}
 	e.Timestamp = uint64(time.Now().UnixMicro())
-	ct, s, n, err := EncryptText(password, text, e.Timestamp)
-	if err != nil {
-		return &e, err
-	}
-	e.EncryptedText = ct
-	e.Salt = s
-	e.NoncePfx = n
+	e.Kind = EntryKind_Text
+	err := e.Encrypt(text, key, e.AssociatedData(journalId, version))
 	return &e, err
 }
 
-func SerializeEntries(es []*EncryptedEntry) []byte {
+func NewSealedEntry(text string, publicKey []byte, journalId [16]byte, version uint8) (*EncryptedEntry, error) {
+	e := EncryptedEntry{}
+	e.Timestamp = uint64(time.Now().UnixMicro())
+	e.Kind = EntryKind_Sealed
+	err := e.Seal(text, publicKey, e.AssociatedData(journalId, version))
+	return &e, err
+}
+
+func SerializeEntries(es []*EncryptedEntry, version uint8) []byte {
 	ees := []*encodedEntry{}
 	for _, e := range es {
 		ee := encodeEntry(e)
 		ees = append(ees, ee)
 	}
-	return serializeEncodedEntries(ees)
+	return serializeEncodedEntries(ees, version)
 }
 
-func DeserializeEntries(data []byte) []*EncryptedEntry {
-	ees := deserializeEncodedEntries(data)
+func DeserializeEntries(data []byte, version uint8) []*EncryptedEntry {
+	ees := deserializeEncodedEntries(data, version)
 	es := []*EncryptedEntry{}
 	for _, ee := range ees {
 		e := decodeEntry(ee)
@@ -286,19 +683,26 @@ func DeserializeEntries(data []byte) []*EncryptedEntry {
 
 type encodedEntry struct {
 	// all integers are ordered big-endian
-	Timestamp [8]byte   //  0- 7   uint64
-	Salt [12]byte       //  8-19
-	NoncePfx [16]byte   // 20-35
-	CtLength [4]byte    // 36-39
-	CipherText []byte   // 40-...  utf-8-encoded, encrypted
+	// up to version 3:                  since version 4:
+	Timestamp [8]byte   //  0- 7 uint64   0- 7
+	Kind [1]byte        //  -             8
+	Salt [12]byte       //  8-19          9-20
+	NoncePfx [16]byte   // 20-35         21-36
+	CtLength [4]byte    // 36-39         37-40
+	CipherText []byte   // 40-...        41-...   utf-8-encoded, encrypted
 }
 
-const payloadStart = 40
+func payloadStart(version uint8) int {
+	if version >= 4 { return 41 }
+	return 40
+}
 
 func encodeEntry(e *EncryptedEntry) *encodedEntry {
 	ee := encodedEntry{}
 	// timestamp
 	binary.BigEndian.PutUint64(ee.Timestamp[:], e.Timestamp)
+	// kind
+	ee.Kind = [1]byte{e.Kind}
 	// encrypt
 	ee.CipherText = e.EncryptedText
 	ee.Salt = e.Salt
@@ -312,16 +716,20 @@ func encodeEntry(e *EncryptedEntry) *encodedEntry {
 func decodeEntry(ee *encodedEntry) *EncryptedEntry {
 	e := EncryptedEntry{}
 	e.Timestamp = binary.BigEndian.Uint64(ee.Timestamp[:])
+	e.Kind = ee.Kind[0]
 	e.Salt = ee.Salt
 	e.NoncePfx = ee.NoncePfx
 	e.EncryptedText = ee.CipherText
 	return &e
 }
 
-func serializeEncodedEntries(ees []*encodedEntry) []byte {
+func serializeEncodedEntries(ees []*encodedEntry, version uint8) []byte {
 	b := []byte{}
 	for _, ee := range ees {
 		b = append(b, ee.Timestamp[:]...)
+		if version >= 4 {
+			b = append(b, ee.Kind[:]...)
+		}
 		b = append(b, ee.Salt[:]...)
 		b = append(b, ee.NoncePfx[:]...)
 		b = append(b, ee.CtLength[:]...)
@@ -330,22 +738,28 @@ func serializeEncodedEntries(ees []*encodedEntry) []byte {
 	return b
 }
 
-func deserializeEncodedEntries(data []byte) []*encodedEntry {
+func deserializeEncodedEntries(data []byte, version uint8) []*encodedEntry {
 	ees := []*encodedEntry{}
 	lenD := len(data)
+	ps := payloadStart(version)
+	k := 0 // length of the kind field
+	if version >= 4 { k = 1 }
 	o := 0 // offset
 	for {
-		if lenD < o + payloadStart { break } // no more valid data.
+		if lenD < o + ps { break } // no more valid data.
 		ee := encodedEntry{}
 		ee.Timestamp = [8]byte(data[o+0:o+8])
-		ee.Salt = [12]byte(data[o+8:o+20])
-		ee.NoncePfx = [16]byte(data[o+20:o+36])
-		ee.CtLength = [4]byte(data[o+36:o+payloadStart])
+		if version >= 4 {
+			ee.Kind = [1]byte(data[o+8:o+9])
+		}
+		ee.Salt = [12]byte(data[o+k+8:o+k+20])
+		ee.NoncePfx = [16]byte(data[o+k+20:o+k+36])
+		ee.CtLength = [4]byte(data[o+k+36:o+ps])
 		ctLen := int(binary.BigEndian.Uint32(ee.CtLength[:]))
-		if lenD < o + payloadStart + ctLen { break } // no more valid data.
-		ee.CipherText = data[o+payloadStart:o+payloadStart+ctLen]
+		if lenD < o + ps + ctLen { break } // no more valid data.
+		ee.CipherText = data[o+ps:o+ps+ctLen]
 		ees = append(ees, &ee)
-		o += payloadStart + int(ctLen)
+		o += ps + int(ctLen)
 	}
 	return ees
 }
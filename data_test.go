@@ -3,6 +3,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand"
 	"os"
@@ -16,6 +17,9 @@ import (
 
 const JournalTestFile = "/tmp/journal_test"
 
+// cheap kdf parameters, so that the tests don't take forever
+var testKdfParams = KdfParams{Time: 1, Memory: 8 * 1024, Threads: 1}
+
 func TestDataformat(t *testing.T) {
 	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
 	defer memguard.Purge()
@@ -51,7 +55,7 @@ func TestDataformat(t *testing.T) {
 	entryTexts = append(entryTexts, tb.String())
 	t.Run("CreateAndAddEntries", func(t *testing.T) {
 		for i, txt := range entryTexts {
-			e, err := NewEncryptedEntry(txt, passwd)
+			e, err := j.NewEntry(txt)
 			if err != nil {
 				t.Errorf("Could not create entry %v! %v", i, err)
 			}
@@ -84,7 +88,7 @@ func TestDataformat(t *testing.T) {
 			if e == nil {
 				t.Errorf("Could not get entry %v!", ts)
 			}
-			txt, err := e.Decrypt(passwd)
+			txt, err := j.Decrypt(e)
 			if err != nil {
 				t.Errorf("Could not decrypt entry %v! %v", ts, err)
 			}
@@ -116,4 +120,100 @@ func TestDataformat(t *testing.T) {
 			}
 		}
 	})
+	j.Close()
+}
+
+func TestJournalFormat(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	t.Run("HeaderV2", func(t *testing.T) {
+		os.Remove(JournalTestFile)
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not create test journal; ", err) }
+		created := j.Header.Created
+		j.Close()
+		data, _ := os.ReadFile(JournalTestFile)
+		if !bytes.HasPrefix(data, JournalMagic[:]) || data[JournalV2Pos_Version] != JournalFormatVersion {
+			t.Error("Journal file does not start with magic number and current version!")
+		}
+		j, err = OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open test journal; ", err) }
+		if j.Header.Created != created || j.Header.Kdf != DefaultKdfParams || j.Header.Cipher != CipherXChaCha20Poly1305 {
+			t.Error("Header was not read correctly!")
+		}
+		j.Close()
+	})
+	t.Run("AssociatedData", func(t *testing.T) {
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open test journal; ", err) }
+		defer j.Close()
+		e, err := j.NewEntry("test")
+		if err != nil { t.Fatal(err) }
+		moved := *e
+		moved.Timestamp++
+		if _, err := j.Decrypt(&moved); err == nil { t.Error("Could decrypt entry with a changed timestamp!") }
+		relabeled := *e
+		relabeled.Kind = EntryKind_Sealed
+		if _, err := e.Decrypt(j.key, j.associatedData(&relabeled)); err == nil { t.Error("Could decrypt entry with a changed kind!") }
+		if _, err := e.Decrypt(j.key, e.AssociatedData(j.Header.Id, 4)); err == nil { t.Error("Could decrypt entry without associated data!") }
+		if _, err := e.Decrypt(j.key, e.AssociatedData(j.Header.Id, JournalFormatVersion + 1)); err != nil { t.Error("Entry is bound to the format version; ", err) }
+		j.Header.Id[0] ^= 0xff
+		if _, err := j.Decrypt(e); err == nil { t.Error("Could decrypt entry from another journal!") }
+	})
+	t.Run("HeaderMac", func(t *testing.T) {
+		// a modified header with a valid checksum
+		original, _ := os.ReadFile(JournalTestFile)
+		version, h, es, err := parseJournalData(original)
+		if err != nil { t.Fatal(err) }
+		h.Kdf.Time++
+		os.WriteFile(JournalTestFile, assembleJournalData(version, &h, es), JournalFileMode)
+		_, err = OpenJournalFile(JournalTestFile, passwd)
+		if err != CorruptedJournalHeader {
+			t.Errorf("Expected %v, but got %v", CorruptedJournalHeader, err)
+		}
+		os.WriteFile(JournalTestFile, original, JournalFileMode)
+	})
+	t.Run("CorruptedHeader", func(t *testing.T) {
+		data, _ := os.ReadFile(JournalTestFile)
+		data[JournalV2Pos_Header+1] ^= 0xff
+		os.WriteFile(JournalTestFile, data, JournalFileMode)
+		_, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != CorruptedJournalHeader {
+			t.Errorf("Expected %v, but got %v", CorruptedJournalHeader, err)
+		}
+	})
+	t.Run("ReadV1", func(t *testing.T) {
+		// older versions have to be migrated first
+		writeV1TestJournal(t, JournalTestFile, passwd, "written by version 1")
+		_, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != JournalNeedsMigration {
+			t.Errorf("Expected %v, but got %v", JournalNeedsMigration, err)
+		}
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	passwd1 := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	passwd2 := memguard.NewEnclave([]byte("n3wTestP4ssw0rd?"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	CreateJournalFile(JournalTestFile, passwd1, testKdfParams)
+	j, err := OpenJournalFile(JournalTestFile, passwd1)
+	if err != nil { t.Fatal("Could not create test journal; ", err) }
+	e, _ := j.NewEntry("test")
+	j.AddEntry(e)
+	if _, err := j.FindKeySlot(passwd2); err != WrongPassword { t.Error("Wrong password was accepted!") }
+	if _, err := j.FindKeySlot(passwd1); err != nil { t.Error("Correct password was not accepted!") }
+	err = j.ChangePassword(j.UnlockedKeySlot(), passwd2, testKdfParams)
+	if err != nil { t.Fatal("Could not change password; ", err) }
+	j.Close()
+	_, err = OpenJournalFile(JournalTestFile, passwd1)
+	if err == nil { t.Error("Could open journal with the old password!") }
+	j, err = OpenJournalFile(JournalTestFile, passwd2)
+	if err != nil { t.Fatal("Could not open journal with the new password; ", err) }
+	defer j.Close()
+	txt, err := j.Decrypt(j.GetEntry(e.Timestamp))
+	if err != nil || txt != "test" { t.Error("Could not decrypt entry after changing the password; ", err) }
 }
@@ -0,0 +1,151 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"strings"
+)
+
+/*
+
+A line-based diff, used to compare revisions of an entry.
+
+The common prefix and suffix are skipped, the rest is compared
+using the Myers algorithm in linear space: the middle snake of the
+shortest edit script is searched from both ends (see bisect), then
+both halves are compared recursively. This takes O((N+M)D) time
+and O(N+M) memory, for N and M lines with D differences.
+
+*/
+
+const (
+	DiffOp_Equal = iota
+	DiffOp_Delete
+	DiffOp_Insert
+)
+
+type DiffLine struct {
+	Op int
+	Text string
+}
+
+type differ struct {
+	la, lb []string
+	a, b []int // the lines as ids, to compare them faster
+	d []DiffLine
+}
+
+func DiffLines(a string, b string) []DiffLine {
+	df := differ{la: strings.Split(a, "\n"), lb: strings.Split(b, "\n")}
+	ids := map[string]int{}
+	id := func(l string) int {
+		i, exists := ids[l]
+		if !exists {
+			i = len(ids)
+			ids[l] = i
+		}
+		return i
+	}
+	for _, l := range df.la { df.a = append(df.a, id(l)) }
+	for _, l := range df.lb { df.b = append(df.b, id(l)) }
+	df.diff(0, len(df.a), 0, len(df.b))
+	return df.d
+}
+
+func (df *differ) diff(a0 int, a1 int, b0 int, b1 int) {
+	// compares la[a0:a1] and lb[b0:b1]
+	for a0 < a1 && b0 < b1 && df.a[a0] == df.b[b0] {
+		df.d = append(df.d, DiffLine{DiffOp_Equal, df.la[a0]})
+		a0++; b0++
+	}
+	suffix := 0
+	for a0 < a1 - suffix && b0 < b1 - suffix && df.a[a1-suffix-1] == df.b[b1-suffix-1] {
+		suffix++
+	}
+	a1 -= suffix; b1 -= suffix
+	x, y := -1, -1
+	if a0 < a1 && b0 < b1 { x, y = df.bisect(a0, a1, b0, b1) }
+	if x >= 0 && !(x == a0 && y == b0) && !(x == a1 && y == b1) {
+		df.diff(a0, x, b0, y)
+		df.diff(x, a1, y, b1)
+	} else {
+		for i := a0; i < a1; i++ {
+			df.d = append(df.d, DiffLine{DiffOp_Delete, df.la[i]})
+		}
+		for j := b0; j < b1; j++ {
+			df.d = append(df.d, DiffLine{DiffOp_Insert, df.lb[j]})
+		}
+	}
+	for i := a1; i < a1 + suffix; i++ {
+		df.d = append(df.d, DiffLine{DiffOp_Equal, df.la[i]})
+	}
+}
+
+func (df *differ) bisect(a0 int, a1 int, b0 int, b1 int) (int, int) {
+	// Finds the middle snake of la[a0:a1] and lb[b0:b1] and returns
+	// the point where both halves can be split, or -1, -1 if there
+	// is no common line.
+	n, m := a1 - a0, b1 - b0
+	maxD := (n + m + 1) / 2
+	off := maxD
+	// vf[off+k] / vr[off+k] = furthest x on diagonal k, from the start / end
+	vf := make([]int, 2 * maxD + 2)
+	vr := make([]int, 2 * maxD + 2)
+	for i := range vf {
+		vf[i] = -1
+		vr[i] = -1
+	}
+	vf[off+1] = 0
+	vr[off+1] = 0
+	delta := n - m
+	odd := delta % 2 != 0
+	// diagonals that left the edit graph are skipped
+	kfStart, kfEnd, krStart, krEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		// forward
+		for k := -d + kfStart; k <= d - kfEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && df.a[a0+x] == df.b[b0+y] { x++; y++ }
+			vf[off+k] = x
+			if x > n {
+				kfEnd += 2
+			} else if y > m {
+				kfStart += 2
+			} else if odd {
+				kr := delta - k
+				if off + kr >= 0 && off + kr < len(vr) && vr[off+kr] != -1 && x >= n - vr[off+kr] {
+					return a0 + x, b0 + y
+				}
+			}
+		}
+		// reverse
+		for k := -d + krStart; k <= d - krEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && vr[off+k-1] < vr[off+k+1]) {
+				x = vr[off+k+1]
+			} else {
+				x = vr[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && df.a[a1-x-1] == df.b[b1-y-1] { x++; y++ }
+			vr[off+k] = x
+			if x > n {
+				krEnd += 2
+			} else if y > m {
+				krStart += 2
+			} else if !odd {
+				kf := delta - k
+				if off + kf >= 0 && off + kf < len(vf) && vf[off+kf] != -1 && vf[off+kf] >= n - x {
+					return a0 + vf[off+kf], b0 + vf[off+kf] - kf
+				}
+			}
+		}
+	}
+	return -1, -1
+}
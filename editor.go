@@ -0,0 +1,93 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+/*
+
+Entries can be written using an external editor ($VISUAL or $EDITOR).
+
+The text is passed to the editor in a temporary file, which is
+created in a RAM-backed location (tmpfs) if possible, so that the
+plaintext never touches the disk. Afterwards, the file is overwritten
+and removed.
+
+*/
+
+var NoEditor = errors.New("Neither $VISUAL nor $EDITOR is set!")
+
+const tmpfsMagic = 0x01021994
+const ramfsMagic = 0x858458f6
+
+func ExternalEditor() string {
+	for _, v := range []string{"VISUAL", "EDITOR"} {
+		if e := strings.TrimSpace(os.Getenv(v)); e != "" {
+			return e
+		}
+	}
+	return ""
+}
+
+func SecureTempDir() (dir string, inMemory bool) {
+	// returns a RAM-backed directory for temporary files, if there is one
+	for _, d := range []string{os.Getenv("XDG_RUNTIME_DIR"), "/dev/shm"} {
+		if d == "" { continue }
+		if isInMemory(d) && isWritable(d) {
+			return d, true
+		}
+	}
+	return os.TempDir(), false
+}
+
+func isInMemory(dir string) bool {
+	st := syscall.Statfs_t{}
+	if syscall.Statfs(dir, &st) != nil { return false }
+	return int64(st.Type) == tmpfsMagic || int64(st.Type) == ramfsMagic
+}
+
+func isWritable(dir string) bool {
+	return syscall.Access(dir, 2) == nil // W_OK
+}
+
+func EditInExternalEditor(text string, dir string) (string, error) {
+	// Opens the text in the external editor and returns the edited text.
+	// The temporary file is created in dir, see SecureTempDir().
+	editor := strings.Fields(ExternalEditor())
+	if len(editor) == 0 { return "", NoEditor }
+	f, err := os.CreateTemp(dir, "journal-*.txt") // mode 0600
+	if err != nil { return "", err }
+	defer wipeFile(f.Name())
+	_, err = f.WriteString(text)
+	f.Close()
+	if err != nil { return "", err }
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil { return "", err }
+	data, err := os.ReadFile(f.Name())
+	if err != nil { return "", err }
+	edited := string(data)
+	clear(data)
+	return edited, nil
+}
+
+func wipeFile(path string) {
+	// overwrite the file with zeroes before removing it
+	defer os.Remove(path)
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil { return }
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil { return }
+	f.Write(make([]byte, st.Size()))
+	f.Sync()
+}
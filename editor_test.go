@@ -0,0 +1,22 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestExternalEditor(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "")
+	if _, err := EditInExternalEditor("", dir); err != NoEditor {
+		t.Errorf("Expected %v, but got %v", NoEditor, err)
+	}
+	t.Setenv("EDITOR", "sed -i s/typo/text/")
+	txt, err := EditInExternalEditor("some typo\n", dir)
+	if err != nil || txt != "some text\n" { t.Errorf("Unexpected result %q (%v)", txt, err) }
+	files, _ := os.ReadDir(dir)
+	if len(files) > 0 { t.Error("Temporary file was not removed!") }
+}
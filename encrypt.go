@@ -3,9 +3,18 @@ package main
 // Copyright (c) 2026, Julian Müller (ChaoticByte)
 
 import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
 
 	"github.com/awnumar/memguard"
 	"golang.org/x/crypto/argon2"
@@ -19,49 +28,90 @@ The cipher used for encryption is XChaCha20-Poly1305, which is an AEAD algorithm
 XChaCha20 is a ChaCha20 streaming cipher with a 24 byte nonce length.
 Poly1305 is the message authentication part (checks if the decrypted data is correct).
 
-No 'associated data' is written or read.
+Since journal format version 5, the metadata of an entry (journal ID, entry
+kind and timestamp) is passed as associated data, so ciphertexts can't be
+moved between entries or journals without being detected. The same goes for
+the wrapped private key, which is bound to the journal ID and public key.
+Up to version 4, no associated data was written or read. The format version
+isn't part of the associated data, so later format changes don't require
+re-encrypting all entries.
 
-The 32 byte key for encryption is derived using Argon2ID. A 12-byte random salt is used.
+Since version 9, the journal header (kdf parameters, flags, key slots, key
+pair, ...) is authenticated using HMAC-SHA256 with a key derived from the
+master key (see HeaderMac). The key slots themselves are wrapped without
+associated data, binding them to the header would require the passwords
+of all key slots to change it.
+
+Since journal format version 3, the keys are organized as follows:
+
+  password   -- Argon2id ------> key encryption key
+  key encryption key ----------> unwraps the master key, which is stored in the journal header
+  master key -- HKDF-SHA256 ---> entry key (one per entry, using a 12-byte random salt)
+
+This way, the expensive key derivation only runs once when the journal is opened.
+
+Optionally, a journal can have an X25519 key pair, so that new entries can be
+sealed to the public key without knowing the password:
+
+  ephemeral private key + journal public key -- X25519 --> shared secret
+  shared secret -- HKDF-SHA256 --> entry key
+
+The ephemeral public key is prepended to the ciphertext. The private key of the
+journal is stored in the header, encrypted with a key derived from the master key.
 
 */
 
 const ErrMsgInvalidNonceLen = "Assembled nonce has an invalid length!"
+const ErrMsgCiphertextTooShort = "Ciphertext is too short!"
+
+const MasterKeyLength = 32
 
-func EncryptText(password *memguard.Enclave, cleartext string, time uint64) ([]byte, [12]byte, [16]byte, error) {
+func EncryptText(key *memguard.Enclave, cleartext string, time uint64, ad []byte) ([]byte, [12]byte, [16]byte, error) {
 	// create random salt
 	salt := [12]byte{}
 	_, err := rand.Read(salt[:])
 	if err != nil { return []byte{}, salt, [16]byte{}, err }
-	// derive key
-	lb, err := password.Open()
+	// derive entry key
+	lb, err := key.Open()
 	defer lb.Destroy()
 	if err != nil { return []byte{}, salt, [16]byte{}, err }
-	key := derive_key(lb.Bytes(), salt)
+	entryKey, err := derive_entry_key(lb.Bytes(), salt)
+	lb.Destroy()
+	if err != nil { return []byte{}, salt, [16]byte{}, err }
+	ct, noncePfx, err := aeadSeal(entryKey, cleartext, time, ad)
+	return ct, salt, noncePfx, err
+}
+
+func DecryptText(key *memguard.Enclave, ciphertext []byte, salt [12]byte, noncePfx [16]byte, time uint64, ad []byte) (string, error) {
+	// derive entry key
+	lb, err := key.Open()
+	defer lb.Destroy()
+	if err != nil { return "", err }
+	entryKey, err := derive_entry_key(lb.Bytes(), salt)
 	lb.Destroy()
+	if err != nil { return "", err }
+	return aeadOpen(entryKey, ciphertext, noncePfx, time, ad)
+}
+
+func aeadSeal(key [32]byte, cleartext string, time uint64, ad []byte) ([]byte, [16]byte, error) {
 	// assemble nonce
 	noncePfx := [16]byte{}
-	_, err = rand.Read(noncePfx[:])
-	if err != nil { return []byte{}, salt, noncePfx, err }
+	_, err := rand.Read(noncePfx[:])
+	if err != nil { return []byte{}, noncePfx, err }
 	nonce := []byte{}
 	nonce = append(nonce, noncePfx[:]...)
 	nonce = binary.BigEndian.AppendUint64(nonce, time)
 	// create aead cipher
 	aead, err := chacha20poly1305.NewX(key[:])
 	key = [32]byte{} // remove key from memory
-	if err != nil { return []byte{}, salt, noncePfx, err }
+	if err != nil { return []byte{}, noncePfx, err }
 	// encrypt
 	src := []byte(cleartext)
-	dst := aead.Seal(nil, nonce, src, nil)
-	return dst, salt, noncePfx, err
+	dst := aead.Seal(nil, nonce, src, ad)
+	return dst, noncePfx, err
 }
 
-func DecryptText(password *memguard.Enclave, ciphertext []byte, salt [12]byte, noncePfx [16]byte, time uint64) (string, error) {
-	// derive key
-	lb, err := password.Open()
-	defer lb.Destroy()
-	if err != nil { return "", err }
-	key := derive_key(lb.Bytes(), salt)
-	lb.Destroy()
+func aeadOpen(key [32]byte, ciphertext []byte, noncePfx [16]byte, time uint64, ad []byte) (string, error) {
 	// assemble nonce
 	nonce := []byte{}
 	nonce = append(nonce, noncePfx[:]...)
@@ -72,19 +122,297 @@ func DecryptText(password *memguard.Enclave, ciphertext []byte, salt [12]byte, n
 	key = [32]byte{} // remove key from memory
 	if err != nil { return "", err }
 	// decrypt
-	dst, err := aead.Open(nil, nonce[:], ciphertext, nil)
+	dst, err := aead.Open(nil, nonce[:], ciphertext, ad)
 	if err != nil { return "", err }
 	result := string(dst)
 	return result, err
 }
 
+// master key
+
+type KeySlot struct {
+	Salt [16]byte  // salt for the key encryption key
+	Nonce [24]byte
+	WrappedKey []byte // encrypted master key
+	Label string      // optional, not encrypted
+	Kdf KdfParams     // parameters used to derive the key encryption key
+}
+
+func NewMasterKey() *memguard.Enclave {
+	return memguard.NewEnclaveRandom(MasterKeyLength)
+}
+
+func WrapMasterKey(password *memguard.Enclave, masterKey *memguard.Enclave, params KdfParams) (KeySlot, error) {
+	slot := KeySlot{Kdf: params}
+	_, err := rand.Read(slot.Salt[:])
+	if err != nil { return slot, err }
+	_, err = rand.Read(slot.Nonce[:])
+	if err != nil { return slot, err }
+	// derive key encryption key
+	pw, err := password.Open()
+	defer pw.Destroy()
+	if err != nil { return slot, err }
+	kek := derive_key(pw.Bytes(), slot.Salt[:], params)
+	pw.Destroy()
+	aead, err := chacha20poly1305.NewX(kek[:])
+	kek = [32]byte{} // remove key from memory
+	if err != nil { return slot, err }
+	// wrap master key
+	mk, err := masterKey.Open()
+	defer mk.Destroy()
+	if err != nil { return slot, err }
+	slot.WrappedKey = aead.Seal(nil, slot.Nonce[:], mk.Bytes(), nil)
+	return slot, nil
+}
+
+func UnwrapMasterKey(password *memguard.Enclave, slot KeySlot) (*memguard.Enclave, error) {
+	// derive key encryption key
+	pw, err := password.Open()
+	defer pw.Destroy()
+	if err != nil { return nil, err }
+	kek := derive_key(pw.Bytes(), slot.Salt[:], slot.Kdf)
+	pw.Destroy()
+	aead, err := chacha20poly1305.NewX(kek[:])
+	kek = [32]byte{} // remove key from memory
+	if err != nil { return nil, err }
+	// unwrap master key
+	mk, err := aead.Open(nil, slot.Nonce[:], slot.WrappedKey, nil)
+	if err != nil { return nil, err }
+	return memguard.NewEnclave(mk), nil // this also wipes mk
+}
+
+// header authentication
+
+const HeaderMacLength = sha256.Size
+
+func HeaderMac(masterKey *memguard.Enclave, data []byte) ([]byte, error) {
+	key, err := derive_subkey(masterKey, hkdf_info_header_mac)
+	if err != nil { return nil, err }
+	mac := hmac.New(sha256.New, key[:])
+	key = [32]byte{} // remove key from memory
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// public key encryption
+
+const PublicKeyLength = 32
+
+func NewKeyPair() ([]byte, *memguard.Enclave, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil { return nil, nil, err }
+	return priv.PublicKey().Bytes(), memguard.NewEnclave(priv.Bytes()), nil
+}
+
+func WrapPrivateKey(masterKey *memguard.Enclave, privateKey *memguard.Enclave, ad []byte) ([]byte, error) {
+	// returns nonce + encrypted private key
+	key, err := derive_subkey(masterKey, hkdf_info_private_key)
+	if err != nil { return nil, err }
+	aead, err := chacha20poly1305.NewX(key[:])
+	key = [32]byte{} // remove key from memory
+	if err != nil { return nil, err }
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	_, err = rand.Read(nonce)
+	if err != nil { return nil, err }
+	priv, err := privateKey.Open()
+	defer priv.Destroy()
+	if err != nil { return nil, err }
+	return aead.Seal(nonce, nonce, priv.Bytes(), ad), nil
+}
+
+func UnwrapPrivateKey(masterKey *memguard.Enclave, wrapped []byte, ad []byte) (*memguard.Enclave, error) {
+	if len(wrapped) < chacha20poly1305.NonceSizeX { return nil, errors.New(ErrMsgInvalidNonceLen) }
+	key, err := derive_subkey(masterKey, hkdf_info_private_key)
+	if err != nil { return nil, err }
+	aead, err := chacha20poly1305.NewX(key[:])
+	key = [32]byte{} // remove key from memory
+	if err != nil { return nil, err }
+	nonce := wrapped[:chacha20poly1305.NonceSizeX]
+	priv, err := aead.Open(nil, nonce, wrapped[chacha20poly1305.NonceSizeX:], ad)
+	if err != nil { return nil, err }
+	return memguard.NewEnclave(priv), nil // this also wipes priv
+}
+
+func PublicKeyMatches(privateKey *memguard.Enclave, publicKey []byte) bool {
+	lb, err := privateKey.Open()
+	defer lb.Destroy()
+	if err != nil { return false }
+	priv, err := ecdh.X25519().NewPrivateKey(lb.Bytes())
+	lb.Destroy()
+	if err != nil { return false }
+	return bytes.Equal(priv.PublicKey().Bytes(), publicKey)
+}
+
+func SealText(publicKey []byte, cleartext string, time uint64, ad []byte) ([]byte, [12]byte, [16]byte, error) {
+	// create random salt
+	salt := [12]byte{}
+	_, err := rand.Read(salt[:])
+	if err != nil { return []byte{}, salt, [16]byte{}, err }
+	pub, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil { return []byte{}, salt, [16]byte{}, err }
+	// ephemeral key pair
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil { return []byte{}, salt, [16]byte{}, err }
+	shared, err := eph.ECDH(pub)
+	if err != nil { return []byte{}, salt, [16]byte{}, err }
+	ephPub := eph.PublicKey().Bytes()
+	entryKey, err := derive_sealed_entry_key(shared, salt, ephPub, publicKey)
+	clear(shared)
+	if err != nil { return []byte{}, salt, [16]byte{}, err }
+	ct, noncePfx, err := aeadSeal(entryKey, cleartext, time, ad)
+	if err != nil { return []byte{}, salt, noncePfx, err }
+	return append(ephPub, ct...), salt, noncePfx, nil
+}
+
+func OpenSealedText(privateKey *memguard.Enclave, ciphertext []byte, salt [12]byte, noncePfx [16]byte, time uint64, ad []byte) (string, error) {
+	if len(ciphertext) < PublicKeyLength { return "", errors.New(ErrMsgCiphertextTooShort) }
+	ephPub, err := ecdh.X25519().NewPublicKey(ciphertext[:PublicKeyLength])
+	if err != nil { return "", err }
+	lb, err := privateKey.Open()
+	defer lb.Destroy()
+	if err != nil { return "", err }
+	priv, err := ecdh.X25519().NewPrivateKey(lb.Bytes())
+	lb.Destroy()
+	if err != nil { return "", err }
+	shared, err := priv.ECDH(ephPub)
+	if err != nil { return "", err }
+	entryKey, err := derive_sealed_entry_key(shared, salt, ephPub.Bytes(), priv.PublicKey().Bytes())
+	clear(shared)
+	if err != nil { return "", err }
+	return aeadOpen(entryKey, ciphertext[PublicKeyLength:], noncePfx, time, ad)
+}
+
 // key derivation
 
 const a2_time = 6
 const a2_mem = 128*1024
 const a2_thr = 4
 
-func derive_key(password []byte, salt [12]byte) [32]byte {
+type KdfParams struct {
+	Time uint32
+	Memory uint32 // in KiB
+	Threads uint8
+}
+
+var DefaultKdfParams = KdfParams{a2_time, a2_mem, a2_thr}
+
+// The parameters are read from the header before it can be authenticated
+// (see HeaderMac), so they are limited to prevent a corrupted or modified
+// header from exhausting the memory or running Argon2id (almost) forever.
+const MaxKdfTime = 1000
+const MaxKdfMemory = 4 * 1024 * 1024 // in KiB, 4 GiB
+const MaxKdfThreads = 64
+const MaxKdfWork = MaxKdfTime * a2_mem // passes * memory, e.g. 1000 passes with 128 MiB
+
+var InvalidKdfParams = errors.New("Invalid key derivation parameters!")
+
+func (p KdfParams) String() string {
+	return fmt.Sprintf("Argon2id, %v passes, %v MiB, %v threads", p.Time, p.Memory / 1024, p.Threads)
+}
+
+func (p KdfParams) Valid() bool {
+	return p.Time >= 1 && p.Threads >= 1 && p.Memory >= 8 * uint32(p.Threads) &&
+		p.Time <= MaxKdfTime && p.Threads <= MaxKdfThreads && p.Memory <= MaxKdfMemory &&
+		uint64(p.Time) * uint64(p.Memory) <= MaxKdfWork
+}
+
+// Picks the number of passes, so that deriving a key with the given memory
+// and threads takes about as long as the target duration on this machine.
+func CalibrateKdfParams(target time.Duration, memory uint32, threads uint8) KdfParams {
+	p := KdfParams{Time: 1, Memory: memory, Threads: threads}
+	salt := make([]byte, 16)
+	start := time.Now()
+	derive_key([]byte("calibration"), salt, p)
+	perPass := time.Since(start)
+	if perPass > 0 {
+		p.Time = uint32(min(max(target / perPass, 1), MaxKdfTime, time.Duration(MaxKdfWork / max(memory, 1))))
+	}
+	return p
+}
+
+func derive_key(password []byte, salt []byte, p KdfParams) [32]byte {
 	return [32]byte(
-		argon2.IDKey(password, salt[:], a2_time, a2_mem, a2_thr, 32))
+		argon2.IDKey(password, salt, p.Time, p.Memory, p.Threads, 32))
+}
+
+const hkdf_info_entry = "journal entry key"
+const hkdf_info_sealed_entry = "journal sealed entry key"
+const hkdf_info_private_key = "journal private key"
+const hkdf_info_header_mac = "journal header mac"
+
+func derive_entry_key(masterKey []byte, salt [12]byte) ([32]byte, error) {
+	k, err := hkdf.Key(sha256.New, masterKey, salt[:], hkdf_info_entry, 32)
+	if err != nil { return [32]byte{}, err }
+	key := [32]byte(k)
+	clear(k)
+	return key, nil
+}
+
+// keyfiles
+
+// A keyfile can be used instead of, or together with a password.
+// Before the key encryption key is derived, the SHA-256 hash of the
+// keyfile is combined with the password:
+//
+//   keyfile only:        SHA-256("journal keyfile" || SHA-256(keyfile))
+//   password + keyfile:  SHA-256("journal password+keyfile" || SHA-256(password) || SHA-256(keyfile))
+
+var EmptyKeyfile = errors.New("The keyfile is empty!")
+var NoPasswordOrKeyfile = errors.New("Neither a password nor a keyfile was given!")
+
+func ReadKeyfile(path string) (*memguard.Enclave, error) {
+	// returns the hash of the keyfile
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil { return nil, err }
+	if n == 0 { return nil, EmptyKeyfile }
+	return memguard.NewEnclave(h.Sum(nil)), nil
+}
+
+func CompositeKey(password *memguard.Enclave, keyfile *memguard.Enclave) (*memguard.Enclave, error) {
+	// password and keyfile may be nil, but not both
+	if keyfile == nil {
+		if password == nil { return nil, NoPasswordOrKeyfile }
+		return password, nil
+	}
+	h := sha256.New()
+	if password == nil {
+		h.Write([]byte("journal keyfile"))
+	} else {
+		h.Write([]byte("journal password+keyfile"))
+		pw, err := password.Open()
+		if err != nil { return nil, err }
+		pwHash := sha256.Sum256(pw.Bytes())
+		pw.Destroy()
+		h.Write(pwHash[:])
+		clear(pwHash[:])
+	}
+	kf, err := keyfile.Open()
+	if err != nil { return nil, err }
+	h.Write(kf.Bytes())
+	kf.Destroy()
+	return memguard.NewEnclave(h.Sum(nil)), nil
+}
+
+func derive_sealed_entry_key(shared []byte, salt [12]byte, ephemeralPub []byte, pub []byte) ([32]byte, error) {
+	info := hkdf_info_sealed_entry + string(ephemeralPub) + string(pub)
+	k, err := hkdf.Key(sha256.New, shared, salt[:], info, 32)
+	if err != nil { return [32]byte{}, err }
+	key := [32]byte(k)
+	clear(k)
+	return key, nil
+}
+
+func derive_subkey(masterKey *memguard.Enclave, info string) ([32]byte, error) {
+	lb, err := masterKey.Open()
+	defer lb.Destroy()
+	if err != nil { return [32]byte{}, err }
+	k, err := hkdf.Key(sha256.New, lb.Bytes(), nil, info, 32)
+	if err != nil { return [32]byte{}, err }
+	key := [32]byte(k)
+	clear(k)
+	return key, nil
 }
@@ -5,6 +5,7 @@ package main
 import (
 	crand "crypto/rand"
 	"math/rand"
+	"os"
 	"slices"
 	"testing"
 	"time"
@@ -21,24 +22,24 @@ func TestKeyDerivation(t *testing.T) {
 	salt2 := [12]byte{}
 	crand.Read(salt2[:])
 	//
-	key1 := derive_key(password1, salt1)
-	key2 := derive_key(password2, salt2)
+	key1 := derive_key(password1, salt1[:], DefaultKdfParams)
+	key2 := derive_key(password2, salt2[:], DefaultKdfParams)
 	//
 	if key1 == key2 { t.Error("derived key1 == key2!") }
 	//
-	key1_salt2 := derive_key(password1, salt2)
-	key2_salt1 := derive_key(password2, salt1)
+	key1_salt2 := derive_key(password1, salt2[:], DefaultKdfParams)
+	key2_salt1 := derive_key(password2, salt1[:], DefaultKdfParams)
 	if key1 == key1_salt2 { t.Error("derived key1 == (key1 with wrong salt)!") }
 	if key2 == key2_salt1 { t.Error("derived key2 == (key2 with wrong salt)!") }
 	//
-	rekey1 := derive_key(password1, salt1)
-	rekey2 := derive_key(password2, salt2)
+	rekey1 := derive_key(password1, salt1[:], DefaultKdfParams)
+	rekey2 := derive_key(password2, salt2[:], DefaultKdfParams)
 	if rekey1 != key1 { t.Error("kdf is non-deterministic! derived key1 != re-key1!") }
 	if rekey2 != key2 { t.Error("kdf is non-deterministic! derived key2 != re-key2!") }
 }
 
-func trialWithTamperedInput(t *testing.T, what string, password *memguard.Enclave, ciphertext []byte, salt [12]byte, noncePfx [16]byte, time uint64, original string) {
-	clt_t, err := DecryptText(password, ciphertext, salt, noncePfx, time)
+func trialWithTamperedInput(t *testing.T, what string, key *memguard.Enclave, ciphertext []byte, salt [12]byte, noncePfx [16]byte, time uint64, ad []byte, original string) {
+	clt_t, err := DecryptText(key, ciphertext, salt, noncePfx, time, ad)
 	if err == nil || err.Error() != "chacha20poly1305: message authentication failed" {
 		t.Errorf("Could decrypt with tampered %v; message authentication not functioning properly!", what)
 	} else if clt_t == original {
@@ -47,19 +48,19 @@ func trialWithTamperedInput(t *testing.T, what string, password *memguard.Enclav
 }
 
 func TestCrypto(t *testing.T) {
-	password1 := memguard.NewEnclave([]byte("test"))
-	password2_bytes := make([]byte, 20)
-	crand.Read(password2_bytes)
-	password2 := memguard.NewEnclave(password2_bytes)
+	key1 := NewMasterKey()
+	key2 := NewMasterKey()
 	t1 := uint64(time.Now().UnixMicro())
 	time.Sleep(time.Duration(1.0 + rand.Float64()) * time.Second)
 	t2 := uint64(time.Now().UnixMicro())
+	ad1 := []byte("associated data 1")
+	ad2 := []byte("associated data 2")
 	cleartext := "Lorem ipsum dolor sit amet, consetetur sadipscing elitr, sed diam nonumy eirmod tempor invidunt ut labore et dolore magna aliquyam erat, sed diam voluptua. At vero eos et accusam et justo duo dolores et ea rebum. Stet clita kasd gubergren, no sea takimata sanctus est Lorem ipsum dolor sit amet. Lorem ipsum dolor sit amet, consetetur sadipscing elitr, sed diam nonumy eirmod tempor invidunt ut labore et dolore magna aliquyam erat, sed diam voluptua. At vero eos et accusam et justo duo dolores et ea rebum. Stet clita kasd gubergren, no sea takimata sanctus est Lorem ipsum dolor sit amet."
 	//
-	cit1, salt1, noncePfx1, err1 := EncryptText(password1, cleartext, t1)
-	if err1 != nil { t.Fatalf("Could not encrypt with password1, err: %v", err1) }
-	cit2, salt2, noncePfx2, err2 := EncryptText(password2, cleartext, t2)
-	if err2 != nil { t.Fatalf("Could not encrypt with password2, err: %v", err2) }
+	cit1, salt1, noncePfx1, err1 := EncryptText(key1, cleartext, t1, ad1)
+	if err1 != nil { t.Fatalf("Could not encrypt with key1, err: %v", err1) }
+	cit2, salt2, noncePfx2, err2 := EncryptText(key2, cleartext, t2, ad2)
+	if err2 != nil { t.Fatalf("Could not encrypt with key2, err: %v", err2) }
 	//
 	if salt1 == salt2 {
 		t.Error("salt1 and salt2 are the same!")
@@ -74,25 +75,28 @@ func TestCrypto(t *testing.T) {
 		t.Error("ciphertext2 == cleartext!")
 	}
 	//
-	clt_decrypted1, err := DecryptText(password1, cit1, salt1, noncePfx1, t1)
+	clt_decrypted1, err := DecryptText(key1, cit1, salt1, noncePfx1, t1, ad1)
 	if err != nil {
-		t.Error("Could not decrypt ciphertext1 using password1!")
+		t.Error("Could not decrypt ciphertext1 using key1!")
 	}
 	if clt_decrypted1 != cleartext {
 		t.Error("Decrypted ciphertext1 does not equal original ciphertext!")
 	}
-	clt_decrypted2, err := DecryptText(password2, cit2, salt2, noncePfx2, t2)
+	clt_decrypted2, err := DecryptText(key2, cit2, salt2, noncePfx2, t2, ad2)
 	if err != nil {
-		t.Error("Could not decrypt ciphertext2 using password1!")
+		t.Error("Could not decrypt ciphertext2 using key2!")
 	}
 	if clt_decrypted2 != cleartext {
 		t.Error("Decrypted ciphertext2 does not equal original ciphertext!")
 	}
 	//
-	trialWithTamperedInput(t, "wrong password", password2, cit1, salt1, noncePfx1, t1, cleartext)
-	trialWithTamperedInput(t, "wrong password", password1, cit2, salt2, noncePfx2, t2, cleartext)
-	trialWithTamperedInput(t, "tampered nonce prefix", password1, cit1, salt1, noncePfx2, t1, cleartext)
-	trialWithTamperedInput(t, "tampered nonce prefix", password2, cit2, salt2, noncePfx1, t2, cleartext)
+	trialWithTamperedInput(t, "wrong key", key2, cit1, salt1, noncePfx1, t1, ad1, cleartext)
+	trialWithTamperedInput(t, "wrong key", key1, cit2, salt2, noncePfx2, t2, ad2, cleartext)
+	trialWithTamperedInput(t, "tampered salt", key1, cit1, salt2, noncePfx1, t1, ad1, cleartext)
+	trialWithTamperedInput(t, "tampered nonce prefix", key1, cit1, salt1, noncePfx2, t1, ad1, cleartext)
+	trialWithTamperedInput(t, "tampered nonce prefix", key2, cit2, salt2, noncePfx1, t2, ad2, cleartext)
+	trialWithTamperedInput(t, "tampered associated data", key1, cit1, salt1, noncePfx1, t1, ad2, cleartext)
+	trialWithTamperedInput(t, "missing associated data", key1, cit1, salt1, noncePfx1, t1, nil, cleartext)
 	//
 	cit1_tampered := make([]byte, len(cit1))
 	copy(cit1_tampered, cit1)
@@ -101,5 +105,52 @@ func TestCrypto(t *testing.T) {
 	} else {
 		cit1_tampered[3] -= 1
 	}
-	trialWithTamperedInput(t, "tampered ciphertext", password1, cit1_tampered, salt1, noncePfx1, t1, cleartext)
+	trialWithTamperedInput(t, "tampered ciphertext", key1, cit1_tampered, salt1, noncePfx1, t1, ad1, cleartext)
+}
+
+func TestMasterKey(t *testing.T) {
+	password1 := memguard.NewEnclave([]byte("test"))
+	password2 := memguard.NewEnclave([]byte("test2"))
+	key := NewMasterKey()
+	slot, err := WrapMasterKey(password1, key, DefaultKdfParams)
+	if err != nil { t.Fatalf("Could not wrap master key, err: %v", err) }
+	unwrapped, err := UnwrapMasterKey(password1, slot)
+	if err != nil { t.Fatalf("Could not unwrap master key, err: %v", err) }
+	k1, _ := key.Open()
+	k2, _ := unwrapped.Open()
+	if !k1.EqualTo(k2.Bytes()) { t.Error("Unwrapped master key does not match the original key!") }
+	k1.Destroy(); k2.Destroy()
+	_, err = UnwrapMasterKey(password2, slot)
+	if err == nil { t.Error("Could unwrap master key with wrong password!") }
+}
+
+func TestCompositeKey(t *testing.T) {
+	keyfilePath := "/tmp/journal_test_keyfile"
+	defer os.Remove(keyfilePath)
+	os.WriteFile(keyfilePath, []byte{}, 0o600)
+	_, err := ReadKeyfile(keyfilePath)
+	if err != EmptyKeyfile { t.Errorf("Expected %v, but got %v", EmptyKeyfile, err) }
+	kfData := make([]byte, 64)
+	crand.Read(kfData)
+	os.WriteFile(keyfilePath, kfData, 0o600)
+	keyfile, err := ReadKeyfile(keyfilePath)
+	if err != nil { t.Fatalf("Could not read keyfile, err: %v", err) }
+	password := memguard.NewEnclave([]byte("test"))
+	//
+	read := func(e *memguard.Enclave) []byte {
+		lb, err := e.Open()
+		if err != nil { t.Fatal(err) }
+		defer lb.Destroy()
+		return slices.Clone(lb.Bytes())
+	}
+	keyPw, _ := CompositeKey(password, nil)
+	keyKf, _ := CompositeKey(nil, keyfile)
+	keyBoth, _ := CompositeKey(password, keyfile)
+	keyBoth2, _ := CompositeKey(password, keyfile)
+	if !slices.Equal(read(keyPw), []byte("test")) { t.Error("Password-only key should be the password itself!") }
+	if slices.Equal(read(keyKf), read(keyBoth)) { t.Error("keyfile-only key == password+keyfile key!") }
+	if slices.Equal(read(keyKf), kfData) { t.Error("keyfile-only key == keyfile contents!") }
+	if !slices.Equal(read(keyBoth), read(keyBoth2)) { t.Error("Composite key is non-deterministic!") }
+	_, err = CompositeKey(nil, nil)
+	if err != NoPasswordOrKeyfile { t.Errorf("Expected %v, but got %v", NoPasswordOrKeyfile, err) }
 }
@@ -0,0 +1,122 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+
+New entries can be written at another date and time than now.
+The following formats are accepted (case-insensitive):
+
+  now
+  2024-01-15T21:00:00+01:00             ISO 8601 / RFC 3339
+  2024-01-15 21:00, 2024-01-15T21:00    local time
+  [day] [at] [time of day]
+     day          today, yesterday, monday ... sunday (the last one,
+                  also "last monday"), 3 days ago, 2 weeks ago, 2024-01-15
+     time of day  21:00, 21:00:30, 9pm, 9:30 pm
+  3 hours ago, 20 minutes ago
+
+If the day is omitted, it is today. If the time of day is omitted,
+the current time of day is used.
+
+Entry timestamps are unsigned, so the time must be after 1970-01-01.
+
+*/
+
+var InvalidEntryTime = errors.New("Invalid date or time!")
+var EntryTimeBeforeEpoch = errors.New("The date must be after 1970-01-01!")
+
+var entryTimeAgo = regexp.MustCompile(`^(\d+|an?) (minute|hour|day|week)s? ago(?: (.*))?$`)
+var entryTimeClock = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(?::(\d{2}))? ?(am|pm)?$`)
+
+func ParseEntryTime(input string, now time.Time) (time.Time, error) {
+	t, err := parseEntryTime(input, now)
+	if err != nil { return t, err }
+	if !t.After(time.Unix(0, 0)) { return time.Time{}, EntryTimeBeforeEpoch }
+	return t, nil
+}
+
+func parseEntryTime(input string, now time.Time) (time.Time, error) {
+	s := strings.ToLower(strings.Join(strings.Fields(input), " "))
+	if s == "" || s == "now" { return now, nil }
+	// ISO 8601
+	if t, err := time.Parse(time.RFC3339, s); err == nil { return t, nil }
+	if t, err := time.Parse(time.RFC3339, strings.ToUpper(s)); err == nil { return t, nil }
+	for _, f := range []string{"2006-01-02t15:04:05", "2006-01-02t15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(f, s, now.Location()); err == nil { return t, nil }
+	}
+	day := now
+	rest := s
+	if m := entryTimeAgo.FindStringSubmatch(s); m != nil {
+		n := 1
+		if m[1] != "a" && m[1] != "an" { n, _ = strconv.Atoi(m[1]) }
+		switch m[2] {
+		case "minute", "hour":
+			if m[3] != "" { return time.Time{}, InvalidEntryTime }
+			unit := time.Minute
+			if m[2] == "hour" { unit = time.Hour }
+			return now.Add(-time.Duration(n) * unit), nil
+		case "day":
+			day = now.AddDate(0, 0, -n)
+		case "week":
+			day = now.AddDate(0, 0, -7 * n)
+		}
+		rest = m[3]
+	} else {
+		first, after, _ := strings.Cut(s, " ")
+		if first == "last" {
+			first, after, _ = strings.Cut(after, " ")
+			if _, ok := parseWeekday(first); !ok { return time.Time{}, InvalidEntryTime }
+		}
+		if wd, ok := parseWeekday(first); ok {
+			// the last one before today
+			diff := (int(now.Weekday()) - int(wd) + 7) % 7
+			if diff == 0 { diff = 7 }
+			day, rest = now.AddDate(0, 0, -diff), after
+		} else if first == "today" {
+			rest = after
+		} else if first == "yesterday" {
+			day, rest = now.AddDate(0, 0, -1), after
+		} else if d, err := time.ParseInLocation("2006-01-02", first, now.Location()); err == nil {
+			day, rest = time.Date(d.Year(), d.Month(), d.Day(), now.Hour(), now.Minute(), now.Second(), now.Nanosecond(), now.Location()), after
+		}
+	}
+	rest = strings.TrimPrefix(rest, "at ")
+	if rest == "" { return day, nil }
+	h, m, sec, ok := parseTimeOfDay(rest)
+	if !ok { return time.Time{}, InvalidEntryTime }
+	return time.Date(day.Year(), day.Month(), day.Day(), h, m, sec, 0, now.Location()), nil
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := strings.ToLower(wd.String())
+		if s == name || s == name[:3] { return wd, true }
+	}
+	return 0, false
+}
+
+func parseTimeOfDay(s string) (int, int, int, bool) {
+	m := entryTimeClock.FindStringSubmatch(s)
+	// either minutes or am/pm are required, "21" alone is not a time
+	if m == nil || (m[2] == "" && m[4] == "") { return 0, 0, 0, false }
+	h, _ := strconv.Atoi(m[1])
+	min, sec := 0, 0
+	if m[2] != "" { min, _ = strconv.Atoi(m[2]) }
+	if m[3] != "" { sec, _ = strconv.Atoi(m[3]) }
+	if m[4] != "" {
+		if h < 1 || h > 12 { return 0, 0, 0, false }
+		if h == 12 { h = 0 }
+		if m[4] == "pm" { h += 12 }
+	}
+	if h > 23 || min > 59 || sec > 59 { return 0, 0, 0, false }
+	return h, min, sec, true
+}
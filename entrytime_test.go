@@ -0,0 +1,50 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEntryTime(t *testing.T) {
+	loc := time.FixedZone("test", 3600)
+	now := time.Date(2026, 10, 15, 14, 30, 15, 0, loc) // a thursday
+	date := func(y int, m time.Month, d int, h int, min int, s int) time.Time {
+		return time.Date(y, m, d, h, min, s, 0, loc)
+	}
+	valid := map[string]time.Time{
+		"": now,
+		"now": now,
+		"2024-01-15T21:00:00Z": time.Date(2024, 1, 15, 21, 0, 0, 0, time.UTC),
+		"2024-01-15 21:00": date(2024, 1, 15, 21, 0, 0),
+		"2024-01-15t21:00:30": date(2024, 1, 15, 21, 0, 30),
+		"2024-01-15": date(2024, 1, 15, 14, 30, 15),
+		"2024-01-15 at 9pm": date(2024, 1, 15, 21, 0, 0),
+		"yesterday": date(2026, 10, 14, 14, 30, 15),
+		"Yesterday 21:00": date(2026, 10, 14, 21, 0, 0),
+		"today 8:05 am": date(2026, 10, 15, 8, 5, 0),
+		"12am": date(2026, 10, 15, 0, 0, 0),
+		"at 12:30pm": date(2026, 10, 15, 12, 30, 0),
+		"monday 9am": date(2026, 10, 12, 9, 0, 0),
+		"last thu": date(2026, 10, 8, 14, 30, 15),
+		"3 days ago 18:00": date(2026, 10, 12, 18, 0, 0),
+		"a week ago": date(2026, 10, 8, 14, 30, 15),
+		"2 hours ago": date(2026, 10, 15, 12, 30, 15),
+		"20 minutes ago": date(2026, 10, 15, 14, 10, 15),
+	}
+	for input, expected := range valid {
+		got, err := ParseEntryTime(input, now)
+		if err != nil {
+			t.Errorf("%q: %v", input, err)
+		} else if !got.Equal(expected) {
+			t.Errorf("%q: expected %v, got %v", input, expected, got)
+		}
+	}
+	for _, input := range []string{"tomorrowish", "21", "25:00", "13pm", "last week", "2 hours ago 9:00", "2024-13-01", "monday 9:61"} {
+		if _, err := ParseEntryTime(input, now); err != InvalidEntryTime { t.Errorf("%q was accepted!", input) }
+	}
+	for _, input := range []string{"1969-12-31 23:00", "1970-01-01T00:00:00Z", "1900-01-01T12:00:00+01:00", "3000 weeks ago"} {
+		if _, err := ParseEntryTime(input, now); err != EntryTimeBeforeEpoch { t.Errorf("%q was accepted!", input) }
+	}
+}
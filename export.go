@@ -0,0 +1,171 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*
+
+Export
+
+All entries are decrypted and written in one of the following
+formats, oldest first:
+
+  text       the date, title and metadata, followed by the text
+  markdown   like text, with the date and title as a heading
+  json       see EntryJson, an array for a single file
+
+Either into a single file (or stdout), or into one file per entry,
+organized by year and month (local time) like the user interface:
+
+  <dir>/2026/10/2026-10-17_14-03-59.123456.md
+
+The exported files contain the plaintext, so they are only
+readable by the owner.
+
+*/
+
+const (
+	ExportText = "text"
+	ExportMarkdown = "markdown"
+	ExportJson = "json"
+)
+
+var ExportFormats = []string{ExportText, ExportMarkdown, ExportJson}
+
+const ExportFileTimeFormat = "2006-01-02_15-04-05.000000"
+const ExportFileMode = 0o600
+
+var InvalidExportFormat = errors.New("Invalid export format!")
+
+type Exporter struct {
+	Format string
+	TimeFormat string // e.g. EntryTimeFormat or time.RFC3339
+}
+
+func NewExporter(format string, timeFormat string) (*Exporter, error) {
+	if !slices.Contains(ExportFormats, format) { return nil, InvalidExportFormat }
+	return &Exporter{format, timeFormat}, nil
+}
+
+func (x *Exporter) fileExtension() string {
+	switch x.Format {
+	case ExportMarkdown:
+		return ".md"
+	case ExportJson:
+		return ".json"
+	}
+	return ".txt"
+}
+
+func (x *Exporter) WriteEntry(w io.Writer, ts uint64, p *EntryPayload, heading string) error {
+	// writes a single entry, heading is the markdown heading prefix
+	if x.Format == ExportJson {
+		ej := NewEntryJson(ts, p)
+		ej.Text = &p.Text
+		b := bytes.Buffer{}
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		err := enc.Encode(ej)
+		if err != nil { return err }
+		_, err = w.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+		return err
+	}
+	date := time.UnixMicro(int64(ts)).Format(x.TimeFormat)
+	lines := []string{}
+	if x.Format == ExportMarkdown {
+		title := heading + " " + date
+		if p.Title != "" { title += " – " + p.Title }
+		lines = append(lines, title, "")
+	} else {
+		lines = append(lines, date)
+		if p.Title != "" { lines = append(lines, p.Title) }
+	}
+	meta := []string{}
+	if len(p.Tags) > 0 { meta = append(meta, "tags: " + strings.Join(p.Tags, " ")) }
+	if p.Mood > 0 { meta = append(meta, fmt.Sprintf("mood: %v", p.Mood)) }
+	for _, k := range slices.Sorted(maps.Keys(p.Fields)) {
+		meta = append(meta, k + ": " + p.Fields[k])
+	}
+	if x.Format == ExportMarkdown && len(meta) > 0 {
+		// line breaks
+		lines = append(lines, strings.Join(meta, "  \n"), "")
+	} else {
+		lines = append(lines, meta...)
+		lines = append(lines, "")
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n") + "\n" + p.Text + "\n")
+	return err
+}
+
+func (x *Exporter) Export(j *JournalFile, w io.Writer) error {
+	// writes all entries into w
+	tss := j.GetEntries()
+	slices.Sort(tss)
+	sep, end := "\n", ""
+	if x.Format == ExportJson {
+		sep, end = ",", "]\n"
+		if _, err := io.WriteString(w, "["); err != nil { return err }
+	}
+	for i, ts := range tss {
+		p, err := j.DecryptPayload(j.GetEntry(ts))
+		if err != nil { return fmt.Errorf("Entry %v could not be decrypted: %w", ts, err) }
+		if i > 0 {
+			if _, err = io.WriteString(w, sep); err != nil { return err }
+		}
+		err = x.WriteEntry(w, ts, &p, "##")
+		p.Text = ""
+		if err != nil { return err }
+	}
+	_, err := io.WriteString(w, end)
+	return err
+}
+
+func (x *Exporter) ExportFile(j *JournalFile, file string) error {
+	f, err := os.OpenFile(file, os.O_WRONLY | os.O_CREATE | os.O_TRUNC, ExportFileMode)
+	if err != nil { return err }
+	err = x.Export(j, f)
+	if err != nil { f.Close(); return err }
+	return f.Close()
+}
+
+func (x *Exporter) EntryPath(dir string, ts uint64) string {
+	t := time.UnixMicro(int64(ts)).Local()
+	return filepath.Join(dir, fmt.Sprintf("%04d", t.Year()), fmt.Sprintf("%02d", int(t.Month())),
+		t.Format(ExportFileTimeFormat) + x.fileExtension())
+}
+
+func (x *Exporter) ExportEntries(j *JournalFile, dir string) (int, error) {
+	// writes one file per entry, existing files are overwritten,
+	// returns the number of written files
+	tss := j.GetEntries()
+	slices.Sort(tss)
+	for i, ts := range tss {
+		p, err := j.DecryptPayload(j.GetEntry(ts))
+		if err != nil { return i, fmt.Errorf("Entry %v could not be decrypted: %w", ts, err) }
+		file := x.EntryPath(dir, ts)
+		err = os.MkdirAll(filepath.Dir(file), 0700)
+		if err != nil { return i, err }
+		f, err := os.OpenFile(file, os.O_WRONLY | os.O_CREATE | os.O_TRUNC, ExportFileMode)
+		if err != nil { return i, err }
+		err = x.WriteEntry(f, ts, &p, "#")
+		p.Text = ""
+		if err == nil && x.Format == ExportJson { _, err = io.WriteString(f, "\n") }
+		if err != nil { f.Close(); return i, err }
+		err = f.Close()
+		if err != nil { return i, err }
+	}
+	return len(tss), nil
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestExport(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+	j, err := OpenJournalFile(JournalTestFile, passwd)
+	if err != nil { t.Fatal("Could not open test journal; ", err) }
+	defer j.Close()
+	e1, _ := j.NewEntryWithMetadata("first entry", EntryMetadata{Title: "One", Tags: []string{"a", "b"}, Mood: 3, Fields: map[string]string{"k": "v"}})
+	j.AddEntry(e1)
+	time.Sleep(time.Millisecond)
+	e2, _ := j.NewEntry("second <entry>")
+	j.AddEntry(e2)
+	if _, err := NewExporter("xml", EntryTimeFormat); err != InvalidExportFormat { t.Error("Invalid format was accepted!") }
+	t.Run("Text", func(t *testing.T) {
+		x, _ := NewExporter(ExportText, time.RFC3339)
+		b := bytes.Buffer{}
+		if err := x.Export(j, &b); err != nil { t.Fatal("Could not export; ", err) }
+		d1 := time.UnixMicro(int64(e1.Timestamp)).Format(time.RFC3339)
+		d2 := time.UnixMicro(int64(e2.Timestamp)).Format(time.RFC3339)
+		expected := d1 + "\nOne\ntags: a b\nmood: 3\nk: v\n\nfirst entry\n\n" + d2 + "\n\nsecond <entry>\n"
+		if b.String() != expected { t.Errorf("Unexpected export:\n%v", b.String()) }
+	})
+	t.Run("Markdown", func(t *testing.T) {
+		x, _ := NewExporter(ExportMarkdown, EntryTimeFormat)
+		b := bytes.Buffer{}
+		if err := x.Export(j, &b); err != nil { t.Fatal("Could not export; ", err) }
+		d1 := time.UnixMicro(int64(e1.Timestamp)).Format(EntryTimeFormat)
+		if !strings.HasPrefix(b.String(), "## " + d1 + " – One\n\ntags: a b  \nmood: 3  \nk: v\n\nfirst entry\n\n## ") {
+			t.Errorf("Unexpected export:\n%v", b.String())
+		}
+	})
+	t.Run("Json", func(t *testing.T) {
+		x, _ := NewExporter(ExportJson, EntryTimeFormat)
+		b := bytes.Buffer{}
+		if err := x.Export(j, &b); err != nil { t.Fatal("Could not export; ", err) }
+		ejs := []EntryJson{}
+		if err := json.Unmarshal(b.Bytes(), &ejs); err != nil { t.Fatal("Invalid JSON; ", err) }
+		if len(ejs) != 2 || ejs[0].Timestamp != e1.Timestamp || *ejs[1].Text != "second <entry>" || ejs[0].Fields["k"] != "v" {
+			t.Errorf("Unexpected export %v", ejs)
+		}
+	})
+	t.Run("PerEntry", func(t *testing.T) {
+		dir := t.TempDir()
+		x, _ := NewExporter(ExportMarkdown, EntryTimeFormat)
+		n, err := x.ExportEntries(j, dir)
+		if err != nil || n != 2 { t.Fatal("Could not export entries; ", err) }
+		for _, e := range []*EncryptedEntry{e1, e2} {
+			file := x.EntryPath(dir, e.Timestamp)
+			fi, err := os.Stat(file)
+			if err != nil { t.Fatal("Missing file; ", err) }
+			if fi.Mode().Perm() != ExportFileMode { t.Errorf("Unexpected permissions %v", fi.Mode().Perm()) }
+		}
+		b, _ := os.ReadFile(x.EntryPath(dir, e2.Timestamp))
+		if !strings.HasPrefix(string(b), "# ") || !strings.HasSuffix(string(b), "second <entry>\n") { t.Errorf("Unexpected file:\n%v", string(b)) }
+	})
+}
@@ -0,0 +1,270 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+/*
+
+The journal header exists since journal format version 2.
+
+It consists of a list of fields, each one starting with a
+1-byte field type and a 2-byte field length (big-endian),
+followed by the field value. This way, new fields can be
+added later without breaking the file layout.
+
+Fields with an unknown type are kept as-is.
+
+Since version 9, the header ends with a MAC field, HMAC-SHA256
+over all fields before it, using a key derived from the master key
+(see HeaderMac). The MAC can only be checked once the master key is
+unwrapped, before that, the header is only protected against
+accidental corruption by the crc32 checksum of the journal file.
+
+*/
+
+var CorruptedJournalHeader = errors.New("The journal header is corrupted!")
+var UnsupportedJournalHeader = errors.New("The journal header contains unsupported parameters!")
+
+const (
+	HeaderField_Kdf = uint8(1)     // kdf parameters (see KdfParams)
+	HeaderField_Cipher = uint8(2)  // cipher id
+	HeaderField_Created = uint8(3) // creation time, unix time in microseconds
+	HeaderField_Flags = uint8(4)   // journal-wide flags
+	HeaderField_KeySlot = uint8(5) // wrapped master key (see KeySlot), since version 3, can occur multiple times
+	HeaderField_PublicKey = uint8(6)  // X25519 public key for sealed entries, since version 4, optional
+	HeaderField_PrivateKey = uint8(7) // X25519 private key wrapped with the master key, since version 4, optional
+	HeaderField_Id = uint8(8)         // random journal id, since version 5
+	HeaderField_Mac = uint8(9)        // authenticates the fields before it, since version 9, always the last field
+)
+
+// Every key slot consists of the salt, nonce and wrapped master key,
+// optionally followed by more fields (same format as the header fields)
+const (
+	KeySlotField_Label = uint8(1)
+	KeySlotField_Kdf = uint8(2) // only if different from the kdf parameters in the header
+)
+
+const keySlotFixedLen = 16 + 24 + MasterKeyLength + 16 // salt, nonce, wrapped key incl. poly1305 tag
+
+// Cipher IDs
+const CipherXChaCha20Poly1305 = uint8(1)
+
+// Flags
+// (none yet, all bits are reserved)
+const supportedHeaderFlags = uint32(0)
+
+type JournalHeader struct {
+	Id [16]byte // bound to the entries as associated data
+	Kdf KdfParams
+	Cipher uint8
+	Created uint64
+	Flags uint32
+	KeySlots []KeySlot
+	PublicKey []byte
+	WrappedPrivateKey []byte
+	Mac []byte // see HeaderMac
+	unknownFields []headerField
+	macData []byte // the fields covered by the MAC, as read
+}
+
+type headerField struct {
+	Type uint8
+	Value []byte
+}
+
+func NewJournalHeader() JournalHeader {
+	h := JournalHeader{}
+	h.Kdf = DefaultKdfParams
+	h.Cipher = CipherXChaCha20Poly1305
+	h.Created = uint64(time.Now().UnixMicro())
+	rand.Read(h.Id[:])
+	return h
+}
+
+func (h *JournalHeader) CheckSupported() error {
+	if h.Cipher != CipherXChaCha20Poly1305 { return UnsupportedJournalHeader }
+	if !h.Kdf.Valid() { return UnsupportedJournalHeader }
+	if h.Flags & ^supportedHeaderFlags != 0 { return UnsupportedJournalHeader }
+	for _, slot := range h.KeySlots {
+		if !slot.Kdf.Valid() { return UnsupportedJournalHeader }
+	}
+	return nil
+}
+
+func (h *JournalHeader) Serialize() []byte {
+	b := h.serializeFields()
+	if h.Mac != nil {
+		b = append(b, serializeHeaderFields([]headerField{{HeaderField_Mac, h.Mac}})...)
+	}
+	return b
+}
+
+func (h *JournalHeader) serializeFields() []byte {
+	// all fields, except the MAC
+	fs := []headerField{}
+	// journal id
+	if h.Id != [16]byte{} {
+		fs = append(fs, headerField{HeaderField_Id, h.Id[:]})
+	}
+	// kdf parameters
+	fs = append(fs, headerField{HeaderField_Kdf, serializeKdfParams(h.Kdf)})
+	// cipher
+	fs = append(fs, headerField{HeaderField_Cipher, []byte{h.Cipher}})
+	// creation time
+	fs = append(fs, headerField{HeaderField_Created, binary.BigEndian.AppendUint64(nil, h.Created)})
+	// flags
+	fs = append(fs, headerField{HeaderField_Flags, binary.BigEndian.AppendUint32(nil, h.Flags)})
+	// key slots
+	for _, slot := range h.KeySlots {
+		fs = append(fs, headerField{HeaderField_KeySlot, serializeKeySlot(&slot, h.Kdf)})
+	}
+	// key pair
+	if h.PublicKey != nil {
+		fs = append(fs, headerField{HeaderField_PublicKey, h.PublicKey})
+		fs = append(fs, headerField{HeaderField_PrivateKey, h.WrappedPrivateKey})
+	}
+	// unknown fields
+	fs = append(fs, h.unknownFields...)
+	return serializeHeaderFields(fs)
+}
+
+func DeserializeHeader(data []byte) (JournalHeader, error) {
+	h := JournalHeader{}
+	fs, err := deserializeHeaderFields(data)
+	if err != nil { return h, err }
+	found := map[uint8]bool{}
+	for i, f := range fs {
+		switch f.Type {
+		case HeaderField_Kdf:
+			h.Kdf, err = deserializeKdfParams(f.Value)
+			if err != nil { return h, err }
+		case HeaderField_Cipher:
+			if len(f.Value) != 1 { return h, CorruptedJournalHeader }
+			h.Cipher = f.Value[0]
+		case HeaderField_Created:
+			if len(f.Value) != 8 { return h, CorruptedJournalHeader }
+			h.Created = binary.BigEndian.Uint64(f.Value)
+		case HeaderField_Flags:
+			if len(f.Value) != 4 { return h, CorruptedJournalHeader }
+			h.Flags = binary.BigEndian.Uint32(f.Value)
+		case HeaderField_KeySlot:
+			slot, err := deserializeKeySlot(f.Value)
+			if err != nil { return h, err }
+			h.KeySlots = append(h.KeySlots, slot)
+		case HeaderField_Id:
+			if len(f.Value) != 16 { return h, CorruptedJournalHeader }
+			h.Id = [16]byte(f.Value)
+		case HeaderField_PublicKey:
+			if len(f.Value) != PublicKeyLength { return h, CorruptedJournalHeader }
+			h.PublicKey = f.Value
+		case HeaderField_PrivateKey:
+			h.WrappedPrivateKey = f.Value
+		case HeaderField_Mac:
+			if i != len(fs) - 1 || len(f.Value) != HeaderMacLength { return h, CorruptedJournalHeader }
+			h.Mac = f.Value
+			h.macData = data[:len(data) - 3 - len(f.Value)]
+		default:
+			h.unknownFields = append(h.unknownFields, f)
+		}
+		found[f.Type] = true
+	}
+	// check if all required fields are present
+	for _, t := range []uint8{HeaderField_Kdf, HeaderField_Cipher, HeaderField_Created, HeaderField_Flags} {
+		if !found[t] { return h, CorruptedJournalHeader }
+	}
+	// key slots without their own kdf parameters use the ones from the header
+	for i := range h.KeySlots {
+		if h.KeySlots[i].Kdf == (KdfParams{}) {
+			h.KeySlots[i].Kdf = h.Kdf
+		}
+	}
+	// the key pair is only complete with both halves
+	if found[HeaderField_PublicKey] != found[HeaderField_PrivateKey] { return h, CorruptedJournalHeader }
+	return h, nil
+}
+
+// very internal
+
+func serializeKeySlot(slot *KeySlot, defaultKdf KdfParams) []byte {
+	b := []byte{}
+	b = append(b, slot.Salt[:]...)
+	b = append(b, slot.Nonce[:]...)
+	b = append(b, slot.WrappedKey...)
+	fs := []headerField{}
+	if slot.Label != "" {
+		fs = append(fs, headerField{KeySlotField_Label, []byte(slot.Label)})
+	}
+	if slot.Kdf != defaultKdf {
+		fs = append(fs, headerField{KeySlotField_Kdf, serializeKdfParams(slot.Kdf)})
+	}
+	return append(b, serializeHeaderFields(fs)...)
+}
+
+func deserializeKeySlot(data []byte) (KeySlot, error) {
+	slot := KeySlot{}
+	if len(data) < keySlotFixedLen { return slot, CorruptedJournalHeader }
+	slot.Salt = [16]byte(data[0:16])
+	slot.Nonce = [24]byte(data[16:40])
+	slot.WrappedKey = data[40:keySlotFixedLen]
+	fs, err := deserializeHeaderFields(data[keySlotFixedLen:])
+	if err != nil { return slot, err }
+	for _, f := range fs {
+		switch f.Type {
+		case KeySlotField_Label:
+			slot.Label = string(f.Value)
+		case KeySlotField_Kdf:
+			slot.Kdf, err = deserializeKdfParams(f.Value)
+			if err != nil { return slot, err }
+		}
+	}
+	return slot, nil
+}
+
+func serializeKdfParams(p KdfParams) []byte {
+	b := []byte{}
+	b = binary.BigEndian.AppendUint32(b, p.Time)
+	b = binary.BigEndian.AppendUint32(b, p.Memory)
+	return append(b, p.Threads)
+}
+
+func deserializeKdfParams(data []byte) (KdfParams, error) {
+	p := KdfParams{}
+	if len(data) != 9 { return p, CorruptedJournalHeader }
+	p.Time = binary.BigEndian.Uint32(data[0:4])
+	p.Memory = binary.BigEndian.Uint32(data[4:8])
+	p.Threads = data[8]
+	return p, nil
+}
+
+func serializeHeaderFields(fs []headerField) []byte {
+	b := []byte{}
+	for _, f := range fs {
+		b = append(b, f.Type)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(f.Value)))
+		b = append(b, f.Value...)
+	}
+	return b
+}
+
+func deserializeHeaderFields(data []byte) ([]headerField, error) {
+	fs := []headerField{}
+	lenD := len(data)
+	o := 0 // offset
+	for o < lenD {
+		if lenD < o + 3 { return fs, CorruptedJournalHeader }
+		f := headerField{}
+		f.Type = data[o]
+		vLen := int(binary.BigEndian.Uint16(data[o+1:o+3]))
+		if lenD < o + 3 + vLen { return fs, CorruptedJournalHeader }
+		f.Value = data[o+3:o+3+vLen]
+		fs = append(fs, f)
+		o += 3 + vLen
+	}
+	return fs, nil
+}
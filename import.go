@@ -0,0 +1,359 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+
+Import
+
+Entries can be imported from other journaling tools, keeping their
+original timestamps:
+
+  jrnl       the plain text format of jrnl (journal files and
+             `jrnl --export txt`): "[2024-01-15 09:30] Title. Text"
+             The first sentence becomes the title, @tags become tags.
+  dayone     the JSON file of a Day One export (Journal.json), with
+             its tags, location and starred flag
+  markdown   a directory of Markdown files with a date in their name
+             (e.g. 2024-01-15.md or 2024-01-15_09-30.md) or in a front
+             matter, including the per-entry export (see export.go)
+
+Times without a time zone are interpreted as local time.
+
+If an entry already exists at a timestamp, the imported entry is
+skipped and reported as collision (EntryIdAlreadyExists), or moved
+to the next free microsecond.
+
+*/
+
+const (
+	ImportJrnl = "jrnl"
+	ImportDayOne = "dayone"
+	ImportMarkdown = "markdown"
+)
+
+var ImportFormats = []string{ImportJrnl, ImportDayOne, ImportMarkdown}
+
+var InvalidImportFormat = errors.New("Invalid import format!")
+
+type ImportedEntry struct {
+	Time time.Time
+	Text string
+	EntryMetadata
+	Source string // where the entry comes from, for reports
+}
+
+type ImportCollision struct {
+	Entry ImportedEntry
+	Err error
+}
+
+type ImportResult struct {
+	Imported []uint64
+	Collisions []ImportCollision
+	Skipped []string // files that couldn't be imported
+}
+
+func ParseImport(format string, path string) ([]ImportedEntry, []string, error) {
+	// returns the entries and the skipped files
+	switch format {
+	case ImportJrnl, ImportDayOne:
+		f, err := os.Open(path)
+		if err != nil { return nil, nil, err }
+		defer f.Close()
+		if format == ImportJrnl {
+			es, err := ParseJrnl(f, path)
+			return es, nil, err
+		}
+		es, err := ParseDayOne(f, path)
+		return es, nil, err
+	case ImportMarkdown:
+		return ParseMarkdownDir(path)
+	}
+	return nil, nil, InvalidImportFormat
+}
+
+func (j *JournalFile) Import(entries []ImportedEntry, shift bool) (ImportResult, error) {
+	// Adds the entries, colliding entries are skipped, or moved
+	// to the next free timestamp if shift is true
+	r := ImportResult{}
+	for _, ie := range entries {
+		ts := uint64(ie.Time.UnixMicro())
+		if ie.Time.UnixMicro() <= int64(SearchIndexTimestamp) {
+			r.Collisions = append(r.Collisions, ImportCollision{ie, ReservedTimestamp})
+			continue
+		}
+		if shift { ts = j.unusedTimestamp(ts) }
+		if j.GetEntry(ts) != nil {
+			r.Collisions = append(r.Collisions, ImportCollision{ie, EntryIdAlreadyExists})
+			continue
+		}
+		e, err := j.NewEntryAt(ts, ie.Text, ie.EntryMetadata)
+		if err != nil { return r, fmt.Errorf("%v: %w", ie.Source, err) }
+		err = j.AddEntry(e)
+		if err != nil { return r, fmt.Errorf("%v: %w", ie.Source, err) }
+		r.Imported = append(r.Imported, ts)
+	}
+	return r, nil
+}
+
+func importTag(s string) string {
+	// makes a valid tag or field key, see validMetadataName()
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" \t\r\n,:=", r) { return '-' }
+		return r
+	}, s)
+}
+
+func importTitle(s string) string {
+	return strings.TrimSpace(strings.Join(strings.Fields(s), " "))
+}
+
+// jrnl
+
+var jrnlHeader = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} [0-9: ]+(?:[AaPp][Mm])?)\] ?(.*)$`)
+var jrnlTag = regexp.MustCompile(`(?:^|\s)@([^\s@.,;:!?()\[\]]+)`)
+var jrnlTimeFormats = []string{"2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02 03:04 PM", "2006-01-02 03:04:05 PM"}
+
+func parseJrnlTime(s string) (time.Time, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, f := range jrnlTimeFormats {
+		if t, err := time.ParseInLocation(f, s, time.Local); err == nil { return t, true }
+	}
+	return time.Time{}, false
+}
+
+func ParseJrnl(r io.Reader, source string) ([]ImportedEntry, error) {
+	entries := []ImportedEntry{}
+	var current *ImportedEntry
+	body := []string{}
+	finish := func() {
+		if current == nil { return }
+		current.Text = strings.TrimSpace(current.Text + "\n" + strings.Join(body, "\n"))
+		for _, m := range jrnlTag.FindAllStringSubmatch(current.Title + "\n" + current.Text, -1) {
+			current.Tags = append(current.Tags, importTag(m[1]))
+		}
+		slices.Sort(current.Tags)
+		current.Tags = slices.Compact(current.Tags)
+		if current.Text == "" {
+			// a single sentence is the text, not the title
+			current.Text, current.Title = current.Title, ""
+		}
+		entries = append(entries, *current)
+		body = []string{}
+	}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, int(MaxEntryContentSize))
+	for l := 1; s.Scan(); l++ {
+		line := strings.TrimRight(s.Text(), "\r")
+		m := jrnlHeader.FindStringSubmatch(line)
+		if m != nil {
+			if t, ok := parseJrnlTime(m[1]); ok {
+				finish()
+				title, rest := splitJrnlTitle(m[2])
+				current = &ImportedEntry{Time: t, Text: rest, Source: fmt.Sprintf("%v:%v", source, l)}
+				current.Title = title
+				continue
+			}
+		}
+		if current == nil {
+			if strings.TrimSpace(line) == "" { continue }
+			return nil, fmt.Errorf("%v:%v: This is not a jrnl export!", source, l)
+		}
+		body = append(body, line)
+	}
+	if err := s.Err(); err != nil { return nil, err }
+	finish()
+	return entries, nil
+}
+
+func splitJrnlTitle(line string) (string, string) {
+	// like jrnl, the title is the first sentence
+	line = strings.TrimSpace(line)
+	for i, r := range line {
+		if r != '.' && r != '?' && r != '!' { continue }
+		if i+1 == len(line) || line[i+1] == ' ' {
+			return line[:i+1], strings.TrimSpace(line[i+1:])
+		}
+	}
+	return line, ""
+}
+
+// Day One
+
+type dayOneExport struct {
+	Entries []struct {
+		CreationDate string `json:"creationDate"`
+		Text string `json:"text"`
+		Tags []string `json:"tags"`
+		Starred bool `json:"starred"`
+		Location *struct {
+			PlaceName string `json:"placeName"`
+			Locality string `json:"localityName"`
+			Country string `json:"country"`
+		} `json:"location"`
+	} `json:"entries"`
+}
+
+var dayOneEscaped = regexp.MustCompile(`\\([\\.\-!#*_()\[\]{}+>|~` + "`" + `])`)
+
+func ParseDayOne(r io.Reader, source string) ([]ImportedEntry, error) {
+	export := dayOneExport{}
+	err := json.NewDecoder(r).Decode(&export)
+	if err != nil { return nil, fmt.Errorf("%v: %w", source, err) }
+	entries := []ImportedEntry{}
+	for i, de := range export.Entries {
+		t, err := time.Parse(time.RFC3339, de.CreationDate)
+		if err != nil { return nil, fmt.Errorf("%v, entry %v: %w", source, i+1, err) }
+		ie := ImportedEntry{Time: t, Source: fmt.Sprintf("%v, entry %v", source, i+1)}
+		// Day One escapes markdown characters
+		text := dayOneEscaped.ReplaceAllString(strings.ReplaceAll(de.Text, "\r\n", "\n"), "$1")
+		if first, rest, _ := strings.Cut(text, "\n"); strings.HasPrefix(first, "# ") {
+			ie.Title = importTitle(first[2:])
+			text = rest
+		}
+		ie.Text = strings.TrimSpace(text)
+		for _, tag := range de.Tags {
+			ie.Tags = append(ie.Tags, importTag(tag))
+		}
+		slices.Sort(ie.Tags)
+		ie.Tags = slices.Compact(ie.Tags)
+		ie.Fields = map[string]string{}
+		if de.Starred { ie.Fields["starred"] = "yes" }
+		if l := de.Location; l != nil {
+			place := []string{}
+			for _, p := range []string{l.PlaceName, l.Locality, l.Country} {
+				if p != "" && !slices.Contains(place, p) { place = append(place, p) }
+			}
+			if len(place) > 0 { ie.Fields["location"] = importTitle(strings.Join(place, ", ")) }
+		}
+		entries = append(entries, ie)
+	}
+	return entries, nil
+}
+
+// Markdown
+
+var markdownFileDate = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})(?:[_T ](\d{2})[-:.]?(\d{2})(?:[-:.]?(\d{2})(?:\.(\d{1,6}))?)?)?`)
+
+func parseMarkdownFileDate(name string) (time.Time, bool) {
+	m := markdownFileDate.FindStringSubmatch(name)
+	if m == nil { return time.Time{}, false }
+	t, err := time.ParseInLocation("2006-01-02", m[1], time.Local)
+	if err != nil { return time.Time{}, false }
+	var d time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		if m[2+i] == "" { continue }
+		n, _ := strconv.Atoi(m[2+i])
+		d += time.Duration(n) * unit
+	}
+	if m[5] != "" {
+		us, _ := strconv.Atoi((m[5] + "00000")[:6])
+		d += time.Duration(us) * time.Microsecond
+	}
+	return t.Add(d), true
+}
+
+func parseFrontMatterTime(s string) (time.Time, bool) {
+	s = strings.Trim(strings.TrimSpace(s), `"'`)
+	if t, err := time.Parse(time.RFC3339, s); err == nil { return t, true }
+	for _, f := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(f, s, time.Local); err == nil { return t, true }
+	}
+	return time.Time{}, false
+}
+
+func ParseMarkdownFile(file string, data string) (ImportedEntry, error) {
+	ie := ImportedEntry{Source: file}
+	name := filepath.Base(file)
+	t, hasTime := parseMarkdownFileDate(name)
+	ie.Time = t
+	text := strings.ReplaceAll(data, "\r\n", "\n")
+	ie.Fields = map[string]string{}
+	// front matter
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		if fm, body, found := strings.Cut(rest, "\n---\n"); found {
+			text = body
+			for l := range strings.SplitSeq(fm, "\n") {
+				k, v, found := strings.Cut(l, ":")
+				k = importTag(k)
+				v = strings.TrimSpace(v)
+				if !found || v == "" || strings.HasPrefix(l, " ") { continue }
+				switch k {
+				case "date", "created":
+					if t, ok := parseFrontMatterTime(v); ok { ie.Time, hasTime = t, true }
+				case "title":
+					ie.Title = importTitle(strings.Trim(v, `"'`))
+				case "tags":
+					for _, tag := range strings.FieldsFunc(strings.Trim(v, "[]"), func(r rune) bool { return r == ',' || r == ' ' }) {
+						ie.Tags = append(ie.Tags, importTag(strings.Trim(tag, `"'#`)))
+					}
+				case "mood":
+					if mood, err := strconv.Atoi(v); err == nil && mood >= 1 && mood <= MaxMood { ie.Mood = uint8(mood) }
+				default:
+					if validMetadataName(k) { ie.Fields[k] = importTitle(strings.Trim(v, `"'`)) }
+				}
+			}
+		}
+	}
+	if !hasTime { return ie, errors.New("No date found in the file name or front matter!") }
+	// heading
+	if first, rest, _ := strings.Cut(text, "\n"); strings.HasPrefix(first, "# ") && ie.Title == "" {
+		heading := first[2:]
+		text = rest
+		if _, err := time.Parse(ExportFileTimeFormat, strings.TrimSuffix(name, filepath.Ext(name))); err == nil {
+			// exported by this journal, "# <date> – <title>",
+			// followed by the metadata (see Exporter.WriteEntry)
+			_, heading, _ = strings.Cut(heading, " – ")
+			block, body, _ := strings.Cut(strings.TrimLeft(text, "\n"), "\n\n")
+			if m, err := ParseMetadata(strings.ReplaceAll(block, "  \n", "\n")); err == nil && !m.Empty() {
+				ie.EntryMetadata = m
+				text = body
+			}
+		}
+		ie.Title = importTitle(heading)
+	}
+	ie.Text = strings.TrimSpace(text)
+	slices.Sort(ie.Tags)
+	ie.Tags = slices.Compact(ie.Tags)
+	return ie, nil
+}
+
+func ParseMarkdownDir(dir string) ([]ImportedEntry, []string, error) {
+	// returns the entries and the files that were skipped
+	entries := []ImportedEntry{}
+	skipped := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil { return err }
+		ext := strings.ToLower(filepath.Ext(path))
+		if d.IsDir() || (ext != ".md" && ext != ".markdown") { return nil }
+		data, err := os.ReadFile(path)
+		if err != nil { return err }
+		if uint64(len(data)) > MaxEntryContentSize { return fmt.Errorf("%v: %w", path, EntryTooLarge) }
+		ie, err := ParseMarkdownFile(path, string(data))
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%v: %v", path, err))
+			return nil
+		}
+		entries = append(entries, ie)
+		return nil
+	})
+	return entries, skipped, err
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestImport(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+	j, err := OpenJournalFile(JournalTestFile, passwd)
+	if err != nil { t.Fatal("Could not open test journal; ", err) }
+	defer j.Close()
+	t.Run("Jrnl", func(t *testing.T) {
+		jrnl := "[2024-01-15 09:30] Planning. Talked to @Alice.\nMore text\n\n[2024-01-16 06:05 PM] A walk with @dog\n"
+		es, err := ParseJrnl(strings.NewReader(jrnl), "jrnl.txt")
+		if err != nil { t.Fatal("Could not parse; ", err) }
+		if len(es) != 2 { t.Fatalf("Expected 2 entries, got %v", len(es)) }
+		if es[0].Title != "Planning." || es[0].Text != "Talked to @Alice.\nMore text" || !slices.Equal(es[0].Tags, []string{"alice"}) {
+			t.Errorf("Unexpected entry %+v", es[0])
+		}
+		if es[1].Title != "" || es[1].Text != "A walk with @dog" || es[1].Time != time.Date(2024, 1, 16, 18, 5, 0, 0, time.Local) {
+			t.Errorf("Unexpected entry %+v", es[1])
+		}
+		if _, err := ParseJrnl(strings.NewReader("not jrnl\n"), "x"); err == nil { t.Error("Invalid file was accepted!") }
+	})
+	t.Run("DayOne", func(t *testing.T) {
+		export := `{"entries":[{"creationDate":"2023-05-01T08:00:00Z","text":"# Lake\nIt was sunny\\.","tags":["Summer fun"],"starred":true,"location":{"placeName":"Lakeside"}}]}`
+		es, err := ParseDayOne(strings.NewReader(export), "Journal.json")
+		if err != nil { t.Fatal("Could not parse; ", err) }
+		if len(es) != 1 { t.Fatalf("Expected 1 entry, got %v", len(es)) }
+		e := es[0]
+		if e.Title != "Lake" || e.Text != "It was sunny." || !slices.Equal(e.Tags, []string{"summer-fun"}) || e.Fields["location"] != "Lakeside" || e.Fields["starred"] != "yes" {
+			t.Errorf("Unexpected entry %+v", e)
+		}
+		if !e.Valid() { t.Error("Invalid metadata!") }
+	})
+	t.Run("Markdown", func(t *testing.T) {
+		e, err := ParseMarkdownFile("notes.md", "---\ntitle: \"Front\"\ndate: 2022-03-04 10:11\ntags: [a, b]\nmood: 2\n---\nBody\n")
+		if err != nil { t.Fatal("Could not parse; ", err) }
+		if e.Title != "Front" || e.Text != "Body" || e.Mood != 2 || len(e.Tags) != 2 || e.Time != time.Date(2022, 3, 4, 10, 11, 0, 0, time.Local) {
+			t.Errorf("Unexpected entry %+v", e)
+		}
+		e, err = ParseMarkdownFile("2022-03-05_08-15.md", "# Hello\nBody\n")
+		if err != nil || e.Title != "Hello" || e.Time != time.Date(2022, 3, 5, 8, 15, 0, 0, time.Local) { t.Errorf("Unexpected entry %+v; %v", e, err) }
+		if _, err := ParseMarkdownFile("README.md", "text"); err == nil { t.Error("File without date was accepted!") }
+	})
+	t.Run("ExportRoundTrip", func(t *testing.T) {
+		m := EntryMetadata{Title: "Exported", Tags: []string{"x"}, Mood: 4, Fields: map[string]string{"k": "v"}}
+		e, _ := j.NewEntryAt(uint64(time.Date(2021, 6, 7, 8, 9, 10, 123456000, time.Local).UnixMicro()), "exported text", m)
+		j.AddEntry(e)
+		dir := t.TempDir()
+		x, _ := NewExporter(ExportMarkdown, EntryTimeFormat)
+		if _, err := x.ExportEntries(j, dir); err != nil { t.Fatal(err) }
+		es, skipped, err := ParseMarkdownDir(dir)
+		if err != nil || len(skipped) > 0 || len(es) != 1 { t.Fatalf("Could not parse export (%v); %v", skipped, err) }
+		ie := es[0]
+		if uint64(ie.Time.UnixMicro()) != e.Timestamp || ie.Text != "exported text" || ie.Title != "Exported" || ie.Mood != 4 || ie.Fields["k"] != "v" || !ie.HasTag("x") {
+			t.Errorf("Unexpected entry %+v", ie)
+		}
+		j.DeleteEntry(e.Timestamp)
+	})
+	t.Run("Collisions", func(t *testing.T) {
+		ts := time.Date(2024, 1, 15, 9, 30, 0, 0, time.Local)
+		es := []ImportedEntry{{Time: ts, Text: "one"}, {Time: ts, Text: "two"}, {Time: time.UnixMicro(1), Text: "reserved"}}
+		r, err := j.Import(es, false)
+		if err != nil { t.Fatal("Could not import; ", err) }
+		if len(r.Imported) != 1 || len(r.Collisions) != 2 || r.Collisions[0].Err != EntryIdAlreadyExists || r.Collisions[1].Err != ReservedTimestamp {
+			t.Errorf("Unexpected result %+v", r)
+		}
+		r, err = j.Import(es[:2], true)
+		if err != nil { t.Fatal("Could not import; ", err) }
+		if len(r.Imported) != 2 || len(r.Collisions) != 0 || r.Imported[1] != uint64(ts.UnixMicro()) + 2 {
+			t.Errorf("Unexpected result %+v", r)
+		}
+		txt, _ := j.Decrypt(j.GetEntry(r.Imported[0]))
+		if txt != "one" { t.Error("Unexpected text ", txt) }
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"errors"
+
+	"github.com/awnumar/memguard"
+)
+
+/*
+
+A journal can have multiple key slots (see KeySlot), each one
+wrapping the same master key with a different password.
+The journal can be opened using any of those passwords.
+
+*/
+
+const MaxKeySlots = 8
+
+var TooManyKeySlots = errors.New("The maximum number of key slots is reached!")
+var KeySlotNotFound = errors.New("This key slot does not exist!")
+var KeySlotInUse = errors.New("This key slot was used to open the journal and can't be removed!")
+
+func (j *JournalFile) GetKeySlots() []KeySlot {
+	return j.Header.KeySlots
+}
+
+func (j *JournalFile) UnlockedKeySlot() int {
+	return j.keySlot
+}
+
+func (j *JournalFile) FindKeySlot(password *memguard.Enclave) (int, error) {
+	// returns the first key slot that can be unlocked with this password
+	slot, _, err := j.unlockKeySlot(password)
+	return slot, err
+}
+
+func (j *JournalFile) unlockKeySlot(password *memguard.Enclave) (int, *memguard.Enclave, error) {
+	for i, slot := range j.Header.KeySlots {
+		key, err := UnwrapMasterKey(password, slot)
+		if err == nil {
+			return i, key, nil
+		}
+	}
+	return -1, nil, WrongPassword
+}
+
+func (j *JournalFile) ChangePassword(slot int, newPassword *memguard.Enclave, kdf KdfParams) error {
+	// Wraps the master key with the new password. The entries
+	// don't have to be re-encrypted, as the master key stays the same.
+	if j.closed { return JournalClosed }
+	if j.readOnly { return JournalReadOnly }
+	if j.agent != nil { return MasterKeyUnavailable }
+	if slot < 0 || slot >= len(j.Header.KeySlots) { return KeySlotNotFound }
+	if !kdf.Valid() { return InvalidKdfParams }
+	ks, err := WrapMasterKey(newPassword, j.key, kdf)
+	if err != nil { return err }
+	ks.Label = j.Header.KeySlots[slot].Label
+	j.Header.KeySlots[slot] = ks
+	j.needWrite = true
+	return nil
+}
+
+func (j *JournalFile) AddKeySlot(password *memguard.Enclave, label string, kdf KdfParams) (int, error) {
+	if j.closed { return -1, JournalClosed }
+	if j.readOnly { return -1, JournalReadOnly }
+	if j.agent != nil { return -1, MasterKeyUnavailable }
+	if len(j.Header.KeySlots) >= MaxKeySlots { return -1, TooManyKeySlots }
+	if !kdf.Valid() { return -1, InvalidKdfParams }
+	ks, err := WrapMasterKey(password, j.key, kdf)
+	if err != nil { return -1, err }
+	ks.Label = label
+	j.Header.KeySlots = append(j.Header.KeySlots, ks)
+	j.needWrite = true
+	return len(j.Header.KeySlots) - 1, nil
+}
+
+func (j *JournalFile) RemoveKeySlot(slot int) error {
+	if j.closed { return JournalClosed }
+	if j.readOnly { return JournalReadOnly }
+	if slot < 0 || slot >= len(j.Header.KeySlots) { return KeySlotNotFound }
+	if slot == j.keySlot { return KeySlotInUse }
+	j.Header.KeySlots = append(j.Header.KeySlots[:slot], j.Header.KeySlots[slot+1:]...)
+	if slot < j.keySlot { j.keySlot-- }
+	j.needWrite = true
+	return nil
+}
+
+func KeySlotDescription(slot *KeySlot, inUse bool) string {
+	d := slot.Label
+	if d == "" {
+		d = "(no label)"
+	}
+	d += " [" + slot.Kdf.String() + "]"
+	if inUse {
+		d += " (in use)"
+	}
+	return d
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestKeySlots(t *testing.T) {
+	passwd1 := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	passwd2 := memguard.NewEnclave([]byte("recoveryP4ssw0rd"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	CreateJournalFile(JournalTestFile, passwd1, testKdfParams)
+	j, err := OpenJournalFile(JournalTestFile, passwd1)
+	if err != nil { t.Fatal("Could not create test journal; ", err) }
+	t.Run("AddKeySlot", func(t *testing.T) {
+		i, err := j.AddKeySlot(passwd2, "recovery", testKdfParams)
+		if err != nil || i != 1 { t.Fatalf("Could not add key slot (%v, %v)", i, err) }
+		j.Close()
+		h, err := ReadJournalHeader(JournalTestFile)
+		if err != nil { t.Fatal("Could not read header; ", err) }
+		if len(h.KeySlots) != 2 || h.KeySlots[1].Label != "recovery" {
+			t.Error("Key slots were not written correctly!")
+		}
+	})
+	t.Run("OpenWithEachSlot", func(t *testing.T) {
+		for i, pw := range []*memguard.Enclave{passwd1, passwd2} {
+			j, err = OpenJournalFile(JournalTestFile, pw)
+			if err != nil { t.Fatalf("Could not open journal using key slot %v; %v", i, err) }
+			if j.UnlockedKeySlot() != i { t.Errorf("Expected key slot %v to be used, got %v", i, j.UnlockedKeySlot()) }
+			j.Close()
+		}
+	})
+	t.Run("RemoveKeySlot", func(t *testing.T) {
+		j, err = OpenJournalFile(JournalTestFile, passwd2)
+		if err != nil { t.Fatal("Could not open journal; ", err) }
+		if j.RemoveKeySlot(1) != KeySlotInUse { t.Error("Could remove the key slot that is in use!") }
+		if j.RemoveKeySlot(2) != KeySlotNotFound { t.Error("Could remove a nonexistent key slot!") }
+		err = j.RemoveKeySlot(0)
+		if err != nil { t.Fatal("Could not remove key slot; ", err) }
+		if j.UnlockedKeySlot() != 0 { t.Error("Index of the key slot in use was not updated!") }
+		j.Close()
+		_, err = OpenJournalFile(JournalTestFile, passwd1)
+		if err != WrongPassword { t.Errorf("Expected %v, but got %v", WrongPassword, err) }
+	})
+}
+
+func TestKdfParams(t *testing.T) {
+	passwd1 := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	passwd2 := memguard.NewEnclave([]byte("recoveryP4ssw0rd"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	if CreateJournalFile(JournalTestFile, passwd1, KdfParams{}) != InvalidKdfParams {
+		t.Error("Could create journal with invalid kdf parameters!")
+	}
+	// a header with parameters above the limits is rejected before deriving a key
+	for _, p := range []KdfParams{
+		{Time: MaxKdfTime + 1, Memory: 8 * 1024, Threads: 1},
+		{Time: 1, Memory: MaxKdfMemory + 1, Threads: 1},
+		{Time: 1, Memory: 8 * 1024, Threads: MaxKdfThreads + 1},
+		{Time: 100, Memory: MaxKdfMemory, Threads: 4},
+		{Time: math.MaxUint32, Memory: math.MaxUint32, Threads: math.MaxUint8},
+	} {
+		h := NewJournalHeader()
+		h.Kdf = p
+		if h.CheckSupported() != UnsupportedJournalHeader { t.Errorf("Header with kdf parameters %v was accepted!", p) }
+		h.Kdf = DefaultKdfParams
+		h.KeySlots = []KeySlot{{Kdf: p}}
+		if h.CheckSupported() != UnsupportedJournalHeader { t.Errorf("Key slot with kdf parameters %v was accepted!", p) }
+		if CreateJournalFile(JournalTestFile, passwd1, p) != InvalidKdfParams { t.Errorf("Could create journal with kdf parameters %v!", p) }
+	}
+	err := CreateJournalFile(JournalTestFile, passwd1, testKdfParams)
+	if err != nil { t.Fatal("Could not create test journal; ", err) }
+	if CreateJournalFile(JournalTestFile, passwd1, testKdfParams) == nil {
+		t.Error("Could overwrite an existing journal!")
+	}
+	j, err := OpenJournalFile(JournalTestFile, passwd1)
+	if err != nil { t.Fatal("Could not open test journal; ", err) }
+	if j.Header.Kdf != testKdfParams { t.Error("Kdf parameters were not stored in the header!") }
+	slotKdf := KdfParams{Time: 2, Memory: 16 * 1024, Threads: 2}
+	_, err = j.AddKeySlot(passwd2, "", slotKdf)
+	if err != nil { t.Fatal("Could not add key slot; ", err) }
+	j.Close()
+	h, err := ReadJournalHeader(JournalTestFile)
+	if err != nil { t.Fatal("Could not read header; ", err) }
+	if h.KeySlots[0].Kdf != testKdfParams || h.KeySlots[1].Kdf != slotKdf {
+		t.Error("Kdf parameters of the key slots were not stored correctly!")
+	}
+	j, err = OpenJournalFile(JournalTestFile, passwd2)
+	if err != nil { t.Fatal("Could not open journal using key slot with its own kdf parameters; ", err) }
+	j.Close()
+	p := CalibrateKdfParams(100 * time.Millisecond, 8 * 1024, 1)
+	if !p.Valid() || p.Memory != 8 * 1024 || p.Threads != 1 {
+		t.Errorf("Calibration returned unexpected parameters: %v", p)
+	}
+	p = CalibrateKdfParams(time.Hour, 8 * 1024, 1)
+	if !p.Valid() { t.Errorf("Calibration returned parameters above the limits: %v", p) }
+}
@@ -0,0 +1,101 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+/*
+
+While a journal is open (see OpenJournalFile), the process holds an
+exclusive advisory lock (flock) on a lock file next to the journal
+file, which contains its PID. The journal file itself can't be
+locked, because it is replaced on every write (see writeFileAtomic).
+
+The kernel releases the lock when the process exits, even if it
+crashed, so the lock file is never removed, a stale lock file is
+taken over by the next process. On file systems without flock
+support, the lock is stale if the process with the PID in the lock
+file doesn't exist anymore.
+
+The lock is also taken while a journal file is created (see
+CreateJournalFile) or migrated (see MigrateJournalFile).
+
+If the lock is held by another process, the journal can still be
+opened read-only (see OpenJournalFileReadOnly).
+
+*/
+
+var JournalLocked = errors.New("The journal is opened by another process!")
+var JournalReadOnly = errors.New("The journal was opened read-only!")
+
+const LockFileMode = 0o600
+
+type JournalLock struct {
+	f *os.File
+}
+
+func LockFilePath(file string) string {
+	return file + ".lock"
+}
+
+func LockJournalFile(file string) (*JournalLock, error) {
+	// takes the lock without waiting, returns JournalLocked if
+	// it is held by another process (or another JournalFile)
+	f, err := os.OpenFile(LockFilePath(file), os.O_RDWR | os.O_CREATE, LockFileMode)
+	if err != nil { return nil, err }
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX | syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		f.Close()
+		return nil, JournalLocked
+	} else if err == syscall.ENOLCK || err == syscall.EOPNOTSUPP || err == syscall.EINVAL {
+		// no flock support, fall back to the PID
+		if pid := lockHolder(f); pid > 0 && processExists(pid) {
+			f.Close()
+			return nil, JournalLocked
+		}
+	} else if err != nil {
+		f.Close()
+		return nil, err
+	}
+	err = f.Truncate(0)
+	if err == nil { _, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid()) + "\n"), 0) }
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &JournalLock{f}, nil
+}
+
+func (l *JournalLock) Unlock() {
+	// the lock file is kept, removing it could let two
+	// processes lock different files at the same time
+	l.f.Truncate(0)
+	l.f.Close()
+}
+
+func JournalLockHolder(file string) int {
+	// returns the PID of the process holding the lock, or 0 if unknown
+	f, err := os.Open(LockFilePath(file))
+	if err != nil { return 0 }
+	defer f.Close()
+	return lockHolder(f)
+}
+
+func lockHolder(f *os.File) int {
+	b := make([]byte, 16)
+	n, _ := f.ReadAt(b, 0)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b[:n])))
+	if err != nil { return 0 }
+	return pid
+}
+
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
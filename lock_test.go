@@ -0,0 +1,73 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestLock(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	defer os.Remove(LockFilePath(JournalTestFile))
+	os.Remove(JournalTestFile)
+	CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+	t.Run("Locked", func(t *testing.T) {
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open test journal; ", err) }
+		if pid := JournalLockHolder(JournalTestFile); pid != os.Getpid() { t.Errorf("Unexpected lock holder %v", pid) }
+		if _, err := OpenJournalFile(JournalTestFile, passwd); err != JournalLocked { t.Errorf("Expected %v, but got %v", JournalLocked, err) }
+		if _, err := OpenJournalFileWriteOnly(JournalTestFile); err != JournalLocked { t.Errorf("Expected %v, but got %v", JournalLocked, err) }
+		j.Close()
+		if pid := JournalLockHolder(JournalTestFile); pid != 0 { t.Errorf("The lock file still contains the PID %v", pid) }
+		j, err = OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open journal after it was closed; ", err) }
+		j.Close()
+	})
+	t.Run("CreateMigrate", func(t *testing.T) {
+		l, err := LockJournalFile(JournalTestFile)
+		if err != nil { t.Fatal(err) }
+		if _, err := MigrateJournalFile(JournalTestFile, passwd); err != JournalLocked { t.Errorf("Expected %v, but got %v", JournalLocked, err) }
+		l.Unlock()
+		newFile := JournalTestFile + "_new"
+		defer os.Remove(LockFilePath(newFile))
+		l, err = LockJournalFile(newFile)
+		if err != nil { t.Fatal(err) }
+		if err := CreateJournalFile(newFile, passwd, testKdfParams); err != JournalLocked { t.Errorf("Expected %v, but got %v", JournalLocked, err) }
+		if _, err := OpenJournalFile(newFile, passwd); err != JournalLocked { t.Errorf("Expected %v, but got %v", JournalLocked, err) }
+		if _, err := os.Stat(newFile); !os.IsNotExist(err) { t.Error("The journal was created while it was locked!") }
+		l.Unlock()
+		defer os.Remove(newFile)
+		j, err := OpenJournalFile(newFile, passwd)
+		if err != nil { t.Fatal("Could not create journal; ", err) }
+		if pid := JournalLockHolder(newFile); pid != os.Getpid() { t.Errorf("Unexpected lock holder %v", pid) }
+		j.Close()
+	})
+	t.Run("Stale", func(t *testing.T) {
+		// a lock file left behind by a process that doesn't exist anymore
+		os.WriteFile(LockFilePath(JournalTestFile), []byte("2147483646\n"), LockFileMode)
+		l, err := LockJournalFile(JournalTestFile)
+		if err != nil { t.Fatal("Could not take over a stale lock; ", err) }
+		l.Unlock()
+	})
+	t.Run("ReadOnly", func(t *testing.T) {
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open test journal; ", err) }
+		defer j.Close()
+		e, _ := j.NewEntry("entry")
+		j.AddEntry(e)
+		j.Write()
+		rj, err := OpenJournalFileReadOnly(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open journal read-only; ", err) }
+		defer rj.Close()
+		if txt, err := rj.Decrypt(rj.GetEntry(e.Timestamp)); err != nil || txt != "entry" { t.Errorf("Could not read entry (%v); %v", txt, err) }
+		e2, _ := rj.NewEntry("another entry")
+		if rj.AddEntry(e2) != JournalReadOnly { t.Error("Could add an entry in read-only mode!") }
+		if rj.DeleteEntry(e.Timestamp) != JournalReadOnly { t.Error("Could delete an entry in read-only mode!") }
+		if err := rj.Write(); err != nil { t.Error("Unexpected error; ", err) }
+	})
+}
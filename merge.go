@@ -0,0 +1,287 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/awnumar/memguard"
+)
+
+/*
+
+If the journal file was modified by another process (or synced from
+another device) since it was last read or written, the changes can
+be merged instead of being overwritten (see JournalFile.Merge).
+
+The merge is a three-way merge of the entries, by timestamp. The base
+is the content of the file at the last read/write, "ours" are the
+entries in memory and "theirs" the entries in the modified file:
+
+- if only one side changed an entry (added, edited, moved to or
+  restored from the trash), that change is taken
+- tombstones always win, timestamps of purged entries are never
+  reused, so a tombstone can't be overwritten by a newer entry
+- the search index is rebuilt from the merged entries
+- if both sides changed an entry differently, that's a conflict,
+  which has to be resolved by keeping one or both versions
+
+If both versions are kept, ours stays at the timestamp and theirs
+is added as a new entry at the next free timestamp, together with
+the revisions they added to it. A trashed version is kept as an
+older revision of the entry instead, and a revision stays one of
+the same entry, so they aren't restored as new entries.
+
+Without the password (write-only mode), the other entries can't be
+decrypted, so both versions can only be kept if ours is an entry
+that was sealed since the last write. It is sealed again at the
+next free timestamp instead.
+
+The header is merged as a whole, if both sides changed it
+differently, the journal can't be merged.
+
+*/
+
+var JournalReplaced = errors.New("The journal file was replaced by a different journal!")
+var JournalHeaderConflict = errors.New("The journal header was changed by both processes, the changes can't be merged!")
+var MergeConflictWriteOnly = errors.New("Both versions of this entry can't be kept without the password!")
+
+// conflict resolutions
+const (
+	MergeKeepOurs = iota
+	MergeKeepTheirs
+	MergeKeepBoth // theirs is added as a new entry at the next free timestamp (see above)
+)
+
+type MergeConflict struct {
+	Timestamp uint64
+	Ours *EncryptedEntry   // nil if it doesn't exist
+	Theirs *EncryptedEntry // nil if it doesn't exist
+}
+
+func (j *JournalFile) updateBase() {
+	j.base = maps.Clone(j.entries)
+	j.baseHeader = j.Header.Serialize()
+	// written entries can't conflict anymore
+	maps.DeleteFunc(j.sealedPayloads, func(ts uint64, _ *memguard.Enclave) bool {
+		_, written := j.base[ts]
+		return written
+	})
+}
+
+func sameEntry(a *EncryptedEntry, b *EncryptedEntry) bool {
+	if a == nil || b == nil { return a == b }
+	return a.Kind == b.Kind && a.Salt == b.Salt && a.NoncePfx == b.NoncePfx && bytes.Equal(a.EncryptedText, b.EncryptedText)
+}
+
+func (j *JournalFile) Merge(resolve func(c *MergeConflict) int) error {
+	// Reads the journal file and merges its changes with the unsaved
+	// changes in memory, the result has to be written afterwards.
+	// resolve is called for each conflict and returns MergeKeepOurs,
+	// MergeKeepTheirs or MergeKeepBoth, if nil, both are kept.
+	if j.closed { return JournalClosed }
+	fileinfo, err := os.Stat(j.Filepath)
+	if err != nil { return err }
+	data, err := os.ReadFile(j.Filepath)
+	if err != nil { return err }
+	version, h, es, err := parseJournalData(data)
+	if err != nil { return err }
+	if version != j.Version || h.Id != j.Header.Id { return JournalReplaced }
+	err = h.CheckSupported()
+	if err != nil { return err }
+	// the header MAC can't be checked without the master key
+	if !j.writeOnly {
+		err = j.checkHeaderMac(&h)
+		if err != nil { return err }
+	}
+	// the search index has to be stored to be merged
+	err = j.storeSearchIndex()
+	if err != nil { return err }
+	theirs := map[uint64]EncryptedEntry{}
+	for _, e := range es {
+		theirs[e.Timestamp] = *e
+	}
+	// header
+	header := j.Header
+	theirHeader := h.Serialize()
+	ourHeader := j.Header.Serialize()
+	if bytes.Equal(ourHeader, j.baseHeader) {
+		header = h
+	} else if !bytes.Equal(theirHeader, j.baseHeader) && !bytes.Equal(theirHeader, ourHeader) {
+		return JournalHeaderConflict
+	}
+	// entries
+	get := func(m map[uint64]EncryptedEntry, ts uint64) *EncryptedEntry {
+		e, exists := m[ts]
+		if !exists { return nil }
+		return &e
+	}
+	merged := map[uint64]EncryptedEntry{}
+	conflicts := []*MergeConflict{}
+	tss := slices.Collect(maps.Keys(j.entries))
+	tss = slices.AppendSeq(tss, maps.Keys(theirs))
+	tss = slices.AppendSeq(tss, maps.Keys(j.base))
+	slices.Sort(tss)
+	for _, ts := range slices.Compact(tss) {
+		o, t, b := get(j.entries, ts), get(theirs, ts), get(j.base, ts)
+		switch {
+		case o != nil && o.Kind == EntryKind_Tombstone:
+			merged[ts] = *o
+		case t != nil && t.Kind == EntryKind_Tombstone:
+			merged[ts] = *t
+		case sameEntry(o, t) || sameEntry(t, b):
+			if o != nil { merged[ts] = *o }
+		case sameEntry(o, b):
+			if t != nil { merged[ts] = *t }
+		case ts == SearchIndexTimestamp:
+			// rebuilt below
+			if o != nil { merged[ts] = *o }
+		default:
+			conflicts = append(conflicts, &MergeConflict{ts, o, t})
+		}
+	}
+	// conflicts
+	free := func(ts uint64) uint64 {
+		for {
+			_, inMerged := merged[ts]
+			_, inTheirs := theirs[ts]
+			_, inOurs := j.entries[ts]
+			if !inMerged && !inTheirs && !inOurs { return ts }
+			ts++
+		}
+	}
+	keepBoth := []*MergeConflict{}
+	for _, c := range conflicts {
+		r := MergeKeepBoth
+		if resolve != nil { r = resolve(c) }
+		if c.Ours == nil && r == MergeKeepBoth { r = MergeKeepTheirs }
+		if c.Theirs == nil && r == MergeKeepBoth { r = MergeKeepOurs }
+		switch r {
+		case MergeKeepOurs:
+			if c.Ours != nil { merged[c.Timestamp] = *c.Ours }
+		case MergeKeepTheirs:
+			if c.Theirs != nil { merged[c.Timestamp] = *c.Theirs }
+		case MergeKeepBoth:
+			keepBoth = append(keepBoth, c)
+		}
+	}
+	if j.writeOnly {
+		for _, c := range keepBoth {
+			err = j.moveSealedEntry(merged, c, free(c.Timestamp + 1))
+			if err != nil { return err }
+		}
+	} else {
+		moved := map[uint64]uint64{} // their entries that were copied to a new timestamp
+		// the entries are copied first, so the revisions can follow them
+		for _, revisions := range []bool{false, true} {
+			for _, c := range keepBoth {
+				if (c.Theirs.Kind == EntryKind_Revision) != revisions { continue }
+				merged[c.Timestamp] = *c.Ours
+				ts, err := j.keepTheirs(merged, c, free(c.Timestamp + 1), moved)
+				if err != nil { return err }
+				if ts != 0 { moved[c.Timestamp] = ts }
+			}
+		}
+		// their new revisions of the copied entries follow them
+		for ts, t := range theirs {
+			if t.Kind != EntryKind_Revision || len(moved) == 0 { continue }
+			m, taken := merged[ts]
+			if !taken || !sameEntry(&m, &t) || sameEntry(get(j.base, ts), &t) { continue }
+			p, err := j.DecryptPayload(&t)
+			if err != nil { return err }
+			if to, ok := moved[p.Revises]; ok {
+				p.Revises = to
+				e := &EncryptedEntry{Timestamp: ts, Kind: EntryKind_Revision}
+				err = j.encryptPayload(e, &p)
+				if err != nil { return err }
+				merged[ts] = *e
+			}
+			p.Text = ""
+		}
+	}
+	// take over the merged state
+	if !bytes.Equal(header.Serialize(), ourHeader) {
+		// find the key slot that was used to unlock the journal
+		slot := -1
+		if j.keySlot >= 0 {
+			for i, ks := range header.KeySlots {
+				if ks.Salt == j.Header.KeySlots[j.keySlot].Salt { slot = i }
+			}
+		}
+		j.Header = header
+		j.keySlot = slot
+		if !j.writeOnly && j.agent == nil {
+			j.privateKey = nil
+			err = j.unlockPrivateKey()
+			if err != nil { return err }
+		}
+	}
+	j.entries = merged
+	j.base = theirs
+	j.baseHeader = theirHeader
+	j.statLastModTime = fileinfo.ModTime()
+	j.needWrite = true
+	if !j.writeOnly {
+		if _, enabled := merged[SearchIndexTimestamp]; enabled {
+			j.index = NewSearchIndex()
+			err = j.updateSearchIndex(nil)
+			if err != nil { return err }
+			j.index.changed = true
+		} else {
+			j.index = nil
+		}
+	}
+	return nil
+}
+
+func (j *JournalFile) keepTheirs(merged map[uint64]EncryptedEntry, c *MergeConflict, ts uint64, moved map[uint64]uint64) (uint64, error) {
+	// Adds their version of a conflicting entry at the given free
+	// timestamp, it has to be re-encrypted, as the timestamp is part
+	// of it. Returns the timestamp if it was added as a new entry.
+	p, err := j.DecryptPayload(c.Theirs)
+	if err != nil { return 0, err }
+	e := &EncryptedEntry{Timestamp: ts}
+	switch c.Theirs.Kind {
+	case EntryKind_Text, EntryKind_Sealed:
+		e.Kind = EntryKind_Text
+	case EntryKind_Trashed:
+		// kept as an older revision of the entry, which stays in place
+		e.Kind = EntryKind_Revision
+		p.Trashed = 0
+		p.Revises = c.Timestamp
+	case EntryKind_Revision:
+		e.Kind = EntryKind_Revision
+		if to, ok := moved[p.Revises]; ok { p.Revises = to }
+	default:
+		return 0, UnknownEntryKind
+	}
+	err = j.encryptPayload(e, &p)
+	p.Text = ""
+	if err != nil { return 0, err }
+	merged[ts] = *e
+	if e.Kind == EntryKind_Text { return ts, nil }
+	return 0, nil
+}
+
+func (j *JournalFile) moveSealedEntry(merged map[uint64]EncryptedEntry, c *MergeConflict, ts uint64) error {
+	// In write-only mode, their version stays in place and ours is
+	// sealed again at the given free timestamp, which is only possible
+	// for entries that were sealed since the last write.
+	payload, ok := j.sealedPayloads[c.Timestamp]
+	if !ok || c.Ours.Kind != EntryKind_Sealed { return MergeConflictWriteOnly }
+	lb, err := payload.Open()
+	if err != nil { return err }
+	defer lb.Destroy()
+	e := &EncryptedEntry{Timestamp: ts, Kind: EntryKind_Sealed}
+	err = e.Seal(lb.String(), j.Header.PublicKey, j.associatedData(e))
+	if err != nil { return err }
+	merged[c.Timestamp] = *c.Theirs
+	merged[ts] = *e
+	delete(j.sealedPayloads, c.Timestamp)
+	j.sealedPayloads[ts] = payload
+	return nil
+}
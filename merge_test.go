@@ -0,0 +1,162 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestMerge(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+	// two processes working on the same file
+	j1, err := OpenJournalFile(JournalTestFile, passwd)
+	if err != nil { t.Fatal("Could not open test journal; ", err) }
+	defer j1.Close()
+	// the second process ignores the lock
+	j1.lock.Unlock()
+	j1.lock = nil
+	j2, err := OpenJournalFile(JournalTestFile, passwd)
+	if err != nil { t.Fatal("Could not open test journal; ", err) }
+	defer j2.Close()
+	j1.EnableSearchIndex(nil)
+	e1, _ := j1.NewEntry("first entry")
+	j1.AddEntry(e1)
+	if err := j1.Write(); err != nil { t.Fatal("Could not write journal; ", err) }
+	time.Sleep(time.Millisecond)
+	e2, _ := j2.NewEntry("second entry")
+	j2.AddEntry(e2)
+	t.Run("Adds", func(t *testing.T) {
+		if err := j2.Write(); err != FileModifiedExternally { t.Fatalf("Expected %v, got %v", FileModifiedExternally, err) }
+		err := j2.Merge(func(c *MergeConflict) int {
+			t.Errorf("Unexpected conflict at %v", c.Timestamp)
+			return MergeKeepOurs
+		})
+		if err != nil { t.Fatal("Could not merge; ", err) }
+		if err := j2.Write(); err != nil { t.Fatal("Could not write merged journal; ", err) }
+		es := j2.GetEntries()
+		slices.Sort(es)
+		if !slices.Equal(es, []uint64{e1.Timestamp, e2.Timestamp}) { t.Errorf("Unexpected entries %v", es) }
+		if !j2.SearchIndexEnabled() || !j2.index.Contains(e2.Timestamp) { t.Error("The search index was not merged!") }
+	})
+	t.Run("Deletions", func(t *testing.T) {
+		j1.Merge(nil)
+		j1.DeleteEntry(e1.Timestamp)
+		j1.PurgeEntry(e1.Timestamp)
+		j1.Write()
+		j2.EditMetadata(e2.Timestamp, EntryMetadata{Title: "Two"})
+		if err := j2.Merge(nil); err != nil { t.Fatal("Could not merge; ", err) }
+		j2.Write()
+		if j2.GetEntry(e1.Timestamp).Kind != EntryKind_Tombstone { t.Error("The purged entry was restored!") }
+		p, _ := j2.DecryptPayload(j2.GetEntry(e2.Timestamp))
+		if p.Title != "Two" { t.Error("The edit was lost!") }
+	})
+	t.Run("Conflicts", func(t *testing.T) {
+		j1.Merge(nil)
+		j1.EditEntry(e2.Timestamp, "edited by j1")
+		j1.Write()
+		j2.EditEntry(e2.Timestamp, "edited by j2")
+		conflicts := []uint64{}
+		err := j2.Merge(func(c *MergeConflict) int {
+			conflicts = append(conflicts, c.Timestamp)
+			return MergeKeepBoth
+		})
+		if err != nil { t.Fatal("Could not merge; ", err) }
+		if !slices.Equal(conflicts, []uint64{e2.Timestamp}) { t.Fatalf("Unexpected conflicts %v", conflicts) }
+		es := j2.GetEntries()
+		if len(es) != 2 { t.Fatalf("Expected 2 entries, got %v", es) }
+		if txt, _ := j2.Decrypt(j2.GetEntry(e2.Timestamp)); txt != "edited by j2" { t.Errorf("Our version was not kept, got %q", txt) }
+		copied := es[0]
+		if copied == e2.Timestamp { copied = es[1] }
+		if txt, _ := j2.Decrypt(j2.GetEntry(copied)); txt != "edited by j1" { t.Errorf("Their version was not copied, got %q", txt) }
+		// the revisions follow their entry
+		revs, _ := j2.GetRevisions(e2.Timestamp)
+		if len(revs) != 1 { t.Errorf("Expected 1 revision, got %v", len(revs)) }
+		revs, _ = j2.GetRevisions(copied)
+		if len(revs) != 1 { t.Errorf("Expected 1 revision of the copy, got %v", len(revs)) }
+		if err := j2.Write(); err != nil { t.Fatal("Could not write merged journal; ", err) }
+	})
+	t.Run("TrashedConflict", func(t *testing.T) {
+		j1.Merge(nil)
+		e3, _ := j1.NewEntry("third entry")
+		j1.AddEntry(e3)
+		j1.Write()
+		j2.Merge(nil)
+		j2.Write()
+		j1.Merge(nil)
+		j1.DeleteEntry(e3.Timestamp)
+		j1.Write()
+		j2.EditEntry(e3.Timestamp, "third entry, edited")
+		if err := j2.Merge(nil); err != nil { t.Fatal("Could not merge; ", err) }
+		if e := j2.GetEntry(e3.Timestamp); e == nil || e.Kind != EntryKind_Text { t.Error("The edited entry was not kept!") }
+		if len(j2.GetTrash()) != 0 { t.Error("The trashed version was copied to a new timestamp!") }
+		// the trashed version is kept as an older revision
+		revs, _ := j2.GetRevisions(e3.Timestamp)
+		if len(revs) != 2 { t.Fatalf("Expected 2 revisions, got %v", len(revs)) }
+		for _, ts := range revs {
+			p, err := j2.DecryptPayload(j2.GetEntry(ts))
+			if err != nil || p.Text != "third entry" || p.Trashed != 0 { t.Errorf("Unexpected revision %v; %v", p, err) }
+		}
+		if err := j2.Write(); err != nil { t.Fatal("Could not write merged journal; ", err) }
+	})
+	t.Run("WriteOnly", func(t *testing.T) {
+		j1.Merge(nil)
+		if err := j1.EnableSealing(); err != nil { t.Fatal(err) }
+		j1.Write()
+		j2.lock.Unlock()
+		j2.lock = nil
+		w, err := OpenJournalFileWriteOnly(JournalTestFile)
+		if err != nil { t.Fatal("Could not open the journal write-only; ", err) }
+		defer w.Close()
+		// both processes add an entry at the same time
+		ts := uint64(time.Now().UnixMicro())
+		s, _ := w.NewEntryAt(ts, "sealed entry", EntryMetadata{})
+		w.AddEntry(s)
+		time.Sleep(10 * time.Millisecond) // the modification time may be coarse
+		e, _ := j1.NewEntryAt(ts, "entry at the same time", EntryMetadata{})
+		j1.AddEntry(e)
+		j1.Write()
+		if err := w.Write(); err != FileModifiedExternally { t.Fatalf("Expected %v, got %v", FileModifiedExternally, err) }
+		if err := w.Merge(nil); err != nil { t.Fatal("Could not merge; ", err) }
+		if err := w.Write(); err != nil { t.Fatal("Could not write merged journal; ", err) }
+		j1.Merge(nil)
+		if txt, _ := j1.Decrypt(j1.GetEntry(ts)); txt != "entry at the same time" { t.Errorf("Their entry was not kept, got %q", txt) }
+		moved := j1.GetEntry(ts + 1)
+		if moved == nil || moved.Kind != EntryKind_Sealed { t.Fatal("The sealed entry was not moved!") }
+		if txt, err := j1.Decrypt(moved); err != nil || txt != "sealed entry" { t.Errorf("Could not open the moved entry (%q); %v", txt, err) }
+		// entries that weren't sealed by this process can't be moved
+		ts = uint64(time.Now().UnixMicro())
+		s, _ = w.NewEntryAt(ts, "another sealed entry", EntryMetadata{})
+		w.AddEntry(s)
+		delete(w.sealedPayloads, ts)
+		time.Sleep(10 * time.Millisecond)
+		e, _ = j1.NewEntryAt(ts, "another entry at the same time", EntryMetadata{})
+		j1.AddEntry(e)
+		j1.Write()
+		if err := w.Merge(nil); err != MergeConflictWriteOnly { t.Errorf("Expected %v, got %v", MergeConflictWriteOnly, err) }
+		if err := w.Merge(func(c *MergeConflict) int { return MergeKeepTheirs }); err != nil { t.Error("Could not merge; ", err) }
+	})
+	t.Run("HeaderConflict", func(t *testing.T) {
+		j1.Merge(nil)
+		j1.AddKeySlot(passwd, "j1", testKdfParams)
+		j1.Write()
+		j2.AddKeySlot(passwd, "j2", testKdfParams)
+		if err := j2.Merge(nil); err != JournalHeaderConflict { t.Errorf("Expected %v, got %v", JournalHeaderConflict, err) }
+	})
+	t.Run("Replaced", func(t *testing.T) {
+		// e.g. by a file synchronization tool, which doesn't take the lock
+		other := JournalTestFile + "_other"
+		defer os.Remove(LockFilePath(other))
+		CreateJournalFile(other, passwd, testKdfParams)
+		os.Rename(other, JournalTestFile)
+		if err := j2.Merge(nil); err != JournalReplaced { t.Errorf("Expected %v, got %v", JournalReplaced, err) }
+	})
+}
@@ -0,0 +1,213 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+/*
+
+Entries can have metadata: a title, tags, a mood rating (1 to 5)
+and free key/value fields. The metadata is part of the encrypted
+payload (see payload.go), so it doesn't leak anything.
+
+In the user interface, the metadata is edited as text:
+
+  title: Weekly planning
+  tags: work project-x
+  mood: 4
+  client: ACME
+
+Entries can be filtered by their metadata, a filter consists of
+terms separated by spaces, all of them have to match:
+
+  tag:<tag>            the entry has this tag
+  title:<text>         the title contains the text (case-insensitive)
+  mood:<n>             the mood rating is n (also mood:>=n, mood:<=n)
+  <key>=<value>        the field has this value
+
+*/
+
+var InvalidMetadata = errors.New("Invalid metadata!")
+var InvalidEntryFilter = errors.New("Invalid filter!")
+
+const MaxMood = 5
+
+type EntryMetadata struct {
+	Title string
+	Tags []string
+	Mood uint8 // 0 if not set
+	Fields map[string]string
+}
+
+func validMetadataName(s string) bool {
+	// tags and field keys
+	return s != "" && len(s) <= 0xff && !strings.ContainsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ',' || r == ':' || r == '=' || unicode.IsControl(r)
+	})
+}
+
+func (m *EntryMetadata) Valid() bool {
+	if m.Mood > MaxMood || strings.ContainsAny(m.Title, "\n\r") { return false }
+	for _, t := range m.Tags {
+		if !validMetadataName(t) { return false }
+	}
+	for k, v := range m.Fields {
+		if !validMetadataName(k) || strings.ContainsAny(v, "\n\r") { return false }
+		if k == "title" || k == "tags" || k == "mood" { return false }
+	}
+	return true
+}
+
+func (m *EntryMetadata) Empty() bool {
+	return m.Title == "" && len(m.Tags) == 0 && m.Mood == 0 && len(m.Fields) == 0
+}
+
+func (m *EntryMetadata) HasTag(tag string) bool {
+	return slices.ContainsFunc(m.Tags, func(t string) bool { return strings.EqualFold(t, tag) })
+}
+
+func (m *EntryMetadata) String() string {
+	// the text representation, see ParseMetadata
+	lines := []string{
+		"title: " + m.Title,
+		"tags: " + strings.Join(m.Tags, " "),
+		"mood: ",
+	}
+	if m.Mood > 0 { lines[2] += strconv.Itoa(int(m.Mood)) }
+	for _, k := range slices.Sorted(maps.Keys(m.Fields)) {
+		lines = append(lines, k + ": " + m.Fields[k])
+	}
+	return strings.Join(lines, "\n")
+}
+
+func ParseMetadata(text string) (EntryMetadata, error) {
+	// Parses the text representation of the metadata, one
+	// field per line. Empty lines and lines starting with #
+	// are ignored, fields with an empty value are removed.
+	m := EntryMetadata{Fields: map[string]string{}}
+	for l := range strings.SplitSeq(text, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") { continue }
+		k, v, found := strings.Cut(l, ":")
+		if !found { return m, fmt.Errorf("%w (line %q)", InvalidMetadata, l) }
+		k = strings.ToLower(strings.TrimSpace(k))
+		v = strings.TrimSpace(v)
+		switch k {
+		case "title":
+			m.Title = v
+		case "tags":
+			m.Tags = strings.FieldsFunc(v, func(r rune) bool { return unicode.IsSpace(r) || r == ',' })
+			slices.Sort(m.Tags)
+			m.Tags = slices.Compact(m.Tags)
+		case "mood":
+			if v == "" { continue }
+			mood, err := strconv.Atoi(v)
+			if err != nil || mood < 1 || mood > MaxMood { return m, fmt.Errorf("%w (mood must be 1 to %v)", InvalidMetadata, MaxMood) }
+			m.Mood = uint8(mood)
+		default:
+			if v != "" { m.Fields[k] = v }
+		}
+	}
+	if !m.Valid() { return m, InvalidMetadata }
+	return m, nil
+}
+
+func (j *JournalFile) EditMetadata(ts uint64, m EntryMetadata) error {
+	// Replaces the metadata of the entry. Unlike EditEntry(),
+	// no revision is kept.
+	if j.closed { return JournalClosed }
+	if j.readOnly { return JournalReadOnly }
+	if j.writeOnly { return JournalWriteOnly }
+	if !m.Valid() { return InvalidMetadata }
+	e := j.GetEntry(ts)
+	if e == nil { return EntryNotFound }
+	if ts == 0 || (e.Kind != EntryKind_Text && e.Kind != EntryKind_Sealed) { return EntryNotEditable }
+	p, err := j.DecryptPayload(e)
+	if err != nil { return err }
+	p.EntryMetadata = m
+	// sealed entries are encrypted with the master key from now on
+	e.Kind = EntryKind_Text
+	err = j.encryptPayload(e, &p)
+	p.Text = ""
+	if err != nil { return err }
+	j.entries[ts] = *e
+	j.needWrite = true
+	return nil
+}
+
+// filters
+
+type EntryFilter struct {
+	Tags []string
+	Title string
+	MinMood uint8
+	MaxMood uint8
+	Fields map[string]string
+	text string
+}
+
+func ParseEntryFilter(text string) (EntryFilter, error) {
+	f := EntryFilter{MaxMood: MaxMood, Fields: map[string]string{}, text: text}
+	terms := strings.Fields(text)
+	if len(terms) == 0 { return f, InvalidEntryFilter }
+	for _, t := range terms {
+		if v, ok := strings.CutPrefix(t, "tag:"); ok && v != "" {
+			f.Tags = append(f.Tags, v)
+		} else if v, ok := strings.CutPrefix(t, "title:"); ok && v != "" {
+			f.Title = v
+		} else if v, ok := strings.CutPrefix(t, "mood:"); ok {
+			op := ""
+			if strings.HasPrefix(v, ">=") || strings.HasPrefix(v, "<=") { op, v = v[:2], v[2:] }
+			mood, err := strconv.Atoi(v)
+			if err != nil || mood < 1 || mood > MaxMood { return f, InvalidEntryFilter }
+			f.MinMood = max(f.MinMood, 1) // entries without a mood rating don't match
+			if op != "<=" { f.MinMood = max(f.MinMood, uint8(mood)) }
+			if op != ">=" { f.MaxMood = min(f.MaxMood, uint8(mood)) }
+		} else if k, v, ok := strings.Cut(t, "="); ok && k != "" {
+			f.Fields[strings.ToLower(k)] = v
+		} else {
+			return f, InvalidEntryFilter
+		}
+	}
+	return f, nil
+}
+
+func (f *EntryFilter) String() string {
+	return f.text
+}
+
+func (f *EntryFilter) Matches(m *EntryMetadata) bool {
+	for _, t := range f.Tags {
+		if !m.HasTag(t) { return false }
+	}
+	if f.Title != "" && !strings.Contains(strings.ToLower(m.Title), strings.ToLower(f.Title)) { return false }
+	if f.MinMood > 0 && (m.Mood < f.MinMood || m.Mood > f.MaxMood) { return false }
+	for k, v := range f.Fields {
+		if !strings.EqualFold(m.Fields[k], v) { return false }
+	}
+	return true
+}
+
+func (j *JournalFile) FilterEntries(f EntryFilter, progress func(done int, total int)) ([]uint64, error) {
+	// returns the timestamps of the matching entries (unsorted)
+	if j.closed { return nil, JournalClosed }
+	if j.writeOnly { return nil, JournalWriteOnly }
+	tss := []uint64{}
+	var mu sync.Mutex
+	err := j.decryptEntries(j.GetEntries(), progress, func(ts uint64, p *EntryPayload) {
+		if !f.Matches(&p.EntryMetadata) { return }
+		mu.Lock()
+		tss = append(tss, ts)
+		mu.Unlock()
+	})
+	return tss, err
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestMetadata(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	t.Run("Parse", func(t *testing.T) {
+		m, err := ParseMetadata("# comment\ntitle: Weekly planning\ntags: work, project-x work\nmood: 4\nClient: ACME\nempty:\n")
+		if err != nil { t.Fatal("Could not parse metadata; ", err) }
+		if m.Title != "Weekly planning" || !slices.Equal(m.Tags, []string{"project-x", "work"}) || m.Mood != 4 {
+			t.Errorf("Unexpected metadata %v", m)
+		}
+		if len(m.Fields) != 1 || m.Fields["client"] != "ACME" { t.Errorf("Unexpected fields %v", m.Fields) }
+		m2, err := ParseMetadata(m.String())
+		if err != nil || m2.String() != m.String() { t.Error("Text representation could not be parsed again; ", err) }
+		for _, invalid := range []string{"mood: 6", "mood: bad", "no colon"} {
+			if _, err := ParseMetadata(invalid); err == nil { t.Errorf("Invalid metadata %q was accepted!", invalid) }
+		}
+	})
+	t.Run("Payload", func(t *testing.T) {
+		p := EntryPayload{Text: "text", EntryMetadata: EntryMetadata{
+			Title: "title", Tags: []string{"a", "b"}, Mood: 2, Fields: map[string]string{"k": "v", "x": ""}}}
+		d, err := DeserializePayload(p.Serialize())
+		if err != nil { t.Fatal("Could not deserialize payload; ", err) }
+		if d.Title != "title" || !slices.Equal(d.Tags, p.Tags) || d.Mood != 2 || d.Fields["k"] != "v" || len(d.Fields) != 2 {
+			t.Errorf("Unexpected payload %v", d)
+		}
+	})
+	t.Run("Filter", func(t *testing.T) {
+		m := EntryMetadata{Title: "Weekly Planning", Tags: []string{"Work"}, Mood: 3, Fields: map[string]string{"client": "ACME"}}
+		matches := map[string]bool{
+			"tag:work": true, "tag:work tag:home": false, "title:planning": true,
+			"mood:3": true, "mood:>=4": false, "mood:<=3": true, "mood:>=2 mood:<=3": true,
+			"client=acme": true, "client=other": false,
+		}
+		for text, expected := range matches {
+			f, err := ParseEntryFilter(text)
+			if err != nil { t.Fatalf("Could not parse filter %q; %v", text, err) }
+			if f.Matches(&m) != expected { t.Errorf("Filter %q should match: %v", text, expected) }
+		}
+		f, _ := ParseEntryFilter("mood:<=3")
+		if f.Matches(&EntryMetadata{}) { t.Error("Mood filter matches entry without mood rating!") }
+		for _, invalid := range []string{"", "mood:9", "tag:", "bad"} {
+			if _, err := ParseEntryFilter(invalid); err != InvalidEntryFilter { t.Errorf("Invalid filter %q was accepted!", invalid) }
+		}
+	})
+	CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+	t.Run("Journal", func(t *testing.T) {
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open test journal; ", err) }
+		e1, _ := j.NewEntry("first")
+		j.AddEntry(e1)
+		e2, _ := j.NewEntry("second")
+		j.AddEntry(e2)
+		err = j.EditMetadata(e2.Timestamp, EntryMetadata{Tags: []string{"work"}})
+		if err != nil { t.Fatal("Could not edit metadata; ", err) }
+		if j.EditMetadata(e1.Timestamp, EntryMetadata{Mood: 9}) != InvalidMetadata { t.Error("Invalid metadata was stored!") }
+		if j.EditMetadata(0, EntryMetadata{}) != EntryNotEditable { t.Error("Could edit metadata of entry 0!") }
+		j.Close()
+		j, _ = OpenJournalFile(JournalTestFile, passwd)
+		defer j.Close()
+		revs, _ := j.GetRevisions(e2.Timestamp)
+		if len(revs) != 0 { t.Error("Editing metadata created a revision!") }
+		txt, _ := j.Decrypt(j.GetEntry(e2.Timestamp))
+		if txt != "second" { t.Error("Editing metadata changed the text!") }
+		f, _ := ParseEntryFilter("tag:work")
+		tss, err := j.FilterEntries(f, nil)
+		if err != nil || !slices.Equal(tss, []uint64{e2.Timestamp}) { t.Errorf("Unexpected filtered entries %v (%v)", tss, err) }
+	})
+}
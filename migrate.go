@@ -0,0 +1,307 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/awnumar/memguard"
+)
+
+/*
+
+Migrations upgrade journal files with an older format version
+to the current one (see JournalFormatVersion).
+
+Every migration converts the raw file data from one version to a
+newer one, so migrations are chained until the current version is
+reached. The master key is only unlocked once per chain (see
+migrationKeys), and journals of version 4 or 5 are re-encrypted
+at once (see migrateEntries).
+
+Before the file is upgraded in place, a backup of the original
+file is written next to it. The journal is locked meanwhile (see
+lock.go).
+
+*/
+
+var JournalNeedsMigration = errors.New("The journal file has an older format version and needs to be migrated!")
+var MigrationNotSupported = errors.New("There is no migration for this journal format version!")
+var BackupFileExists = errors.New("A backup file for this journal version already exists!")
+
+type migration func(data []byte, keys *migrationKeys) ([]byte, error)
+
+// migrations[v] migrates a journal file from version v to a newer version
+var migrations = map[uint8]migration{
+	0: migrateV0ToV1,
+	1: migrateV1ToV2,
+	2: migrateV2ToV3,
+	3: migrateV3ToV4,
+	4: migrateEntries,
+	5: migrateEntries,
+	6: migrateV6ToV7,
+	7: migrateV7ToV8,
+	8: migrateV8ToV9,
+}
+
+type migrationKeys struct {
+	password *memguard.Enclave
+	key *memguard.Enclave // master key (since version 3), once unlocked or created
+}
+
+func (m *migrationKeys) masterKey(h JournalHeader) (*memguard.Enclave, error) {
+	if m.key != nil { return m.key, nil }
+	old := JournalFile{Header: h}
+	_, key, err := old.unlockKeySlot(m.password)
+	if err != nil { return nil, err }
+	m.key = key
+	return key, nil
+}
+
+func JournalFileVersion(file string) (uint8, error) {
+	data, err := os.ReadFile(file)
+	if err != nil { return 0, err }
+	return journalVersion(data)
+}
+
+func MigrationBackupPath(file string, version uint8) string {
+	return fmt.Sprintf("%s.v%v.bak", file, version)
+}
+
+func MigrateJournalFile(file string, password *memguard.Enclave) (backup string, err error) {
+	// the file is replaced, so no other process may have it open
+	l, err := LockJournalFile(file)
+	if err != nil { return "", err }
+	defer l.Unlock()
+	data, err := os.ReadFile(file)
+	if err != nil { return "", err }
+	version, err := journalVersion(data)
+	if err != nil { return "", err }
+	if version > JournalFormatVersion { return "", UnsupportedJournalVersion }
+	if version == JournalFormatVersion { return "", nil } // nothing to do
+	// check if all required migrations exist
+	for v := version; v < JournalFormatVersion; v++ {
+		if _, exists := migrations[v]; !exists {
+			return "", MigrationNotSupported
+		}
+	}
+	// migrate
+	migrated := data
+	keys := &migrationKeys{password: password}
+	for v := version; v < JournalFormatVersion; {
+		migrated, err = migrations[v](migrated, keys)
+		if err != nil { return "", err }
+		v, err = journalVersion(migrated)
+		if err != nil { return "", err }
+	}
+	// write backup of the original file (but never overwrite an older backup)
+	backup = MigrationBackupPath(file, version)
+	f, err := os.OpenFile(backup, os.O_WRONLY | os.O_CREATE | os.O_EXCL, JournalFileMode)
+	if os.IsExist(err) { return "", BackupFileExists }
+	if err != nil { return "", err }
+	_, err = f.Write(data)
+	f.Close()
+	if err != nil { return "", err }
+	// replace the original file
+	err = writeFileAtomic(file, migrated)
+	return backup, err
+}
+
+// v0 -> v1
+
+func migrateV0ToV1(data []byte, keys *migrationKeys) ([]byte, error) {
+	// Journals from before 1.0.0 have the same layout as version 1,
+	// but don't necessarily contain the reserved entry 0, which is
+	// used to check the password since version 1.
+	_, _, es, err := parseJournalData(data)
+	if err != nil { return nil, err }
+	for _, e := range es {
+		if e.Timestamp == 0 { return assembleJournalData(1, nil, es), nil }
+	}
+	// check the password using the first entry, if any
+	if len(es) > 0 {
+		_, err = legacyDecryptText(keys.password, es[0].EncryptedText, es[0].Salt, es[0].NoncePfx, es[0].Timestamp)
+		if err != nil { return nil, WrongPassword }
+	}
+	e0 := &EncryptedEntry{Timestamp: 0}
+	e0.EncryptedText, e0.Salt, e0.NoncePfx, err = legacyEncryptText(keys.password, rand.Text(), e0.Timestamp)
+	if err != nil { return nil, err }
+	return assembleJournalData(1, nil, append([]*EncryptedEntry{e0}, es...)), nil
+}
+
+// v1 -> v2
+
+func migrateV1ToV2(data []byte, keys *migrationKeys) ([]byte, error) {
+	// The entries are not changed, only the magic number
+	// and the header have to be added.
+	_, _, es, err := parseJournalData(data)
+	if err != nil { return nil, err }
+	h := NewJournalHeader()
+	return assembleJournalData(2, &h, es), nil
+}
+
+// v2 -> v3
+
+func migrateV2ToV3(data []byte, keys *migrationKeys) ([]byte, error) {
+	// Up to version 2, every entry was encrypted with a key derived from
+	// the password. Since version 3, the entry keys are derived from a
+	// master key, which is wrapped with the password in the header.
+	_, h, es, err := parseJournalData(data)
+	if err != nil { return nil, err }
+	key := NewMasterKey()
+	h.Kdf = DefaultKdfParams
+	slot, err := WrapMasterKey(keys.password, key, h.Kdf)
+	if err != nil { return nil, err }
+	h.KeySlots = []KeySlot{slot}
+	keys.key = key
+	for _, e := range es {
+		txt, err := legacyDecryptText(keys.password, e.EncryptedText, e.Salt, e.NoncePfx, e.Timestamp)
+		if err != nil { return nil, err }
+		e.EncryptedText, e.Salt, e.NoncePfx, err = EncryptText(key, txt, e.Timestamp, nil)
+		txt = ""
+		if err != nil { return nil, err }
+	}
+	return assembleJournalData(3, &h, es), nil
+}
+
+// v3 -> v4
+
+func migrateV3ToV4(data []byte, keys *migrationKeys) ([]byte, error) {
+	// Version 4 adds the entry kind, all existing
+	// entries are encrypted with the master key.
+	_, h, es, err := parseJournalData(data)
+	if err != nil { return nil, err }
+	for _, e := range es {
+		e.Kind = EntryKind_Text
+	}
+	return assembleJournalData(4, &h, es), nil
+}
+
+// v4, v5 -> v6
+
+func migrateEntries(data []byte, keys *migrationKeys) ([]byte, error) {
+	// Re-encrypts all entries (and the private key) for version 6,
+	// once for all versions in between:
+	// - since version 5, the entries and the private key are bound
+	//   to a journal id using associated data
+	// - since version 6, the text is stored in a payload field, so
+	//   other fields can be added to the encrypted content
+	version, h, es, err := parseJournalData(data)
+	if err != nil { return nil, err }
+	key, err := keys.masterKey(h)
+	if err != nil { return nil, err }
+	var priv *memguard.Enclave
+	if h.PublicKey != nil {
+		var ad []byte
+		if version >= 5 { ad = privateKeyAssociatedData(h.Id, h.PublicKey) }
+		priv, err = UnwrapPrivateKey(key, h.WrappedPrivateKey, ad)
+		if err != nil { return nil, CorruptedJournalHeader }
+	}
+	if h.Id == [16]byte{} {
+		_, err = rand.Read(h.Id[:])
+		if err != nil { return nil, err }
+	}
+	convert := func(txt string) string { return txt }
+	if version < 6 {
+		convert = func(txt string) string {
+			p := EntryPayload{Text: txt}
+			return string(p.Serialize())
+		}
+	}
+	err = reencryptEntries(es, h.Id, version, 6, key, priv, h.PublicKey, convert)
+	if err != nil { return nil, err }
+	if priv != nil && version < 5 {
+		h.WrappedPrivateKey, err = WrapPrivateKey(key, priv, privateKeyAssociatedData(h.Id, h.PublicKey))
+		if err != nil { return nil, err }
+	}
+	return assembleJournalData(6, &h, es), nil
+}
+
+// v6 -> v7
+
+func migrateV6ToV7(data []byte, keys *migrationKeys) ([]byte, error) {
+	// Version 7 adds the search index (see EntryKind_Index),
+	// the existing entries don't change.
+	_, h, es, err := parseJournalData(data)
+	if err != nil { return nil, err }
+	return assembleJournalData(7, &h, es), nil
+}
+
+// v7 -> v8
+
+func migrateV7ToV8(data []byte, keys *migrationKeys) ([]byte, error) {
+	// Version 8 adds the trash (see EntryKind_Trashed),
+	// the existing entries don't change.
+	_, h, es, err := parseJournalData(data)
+	if err != nil { return nil, err }
+	return assembleJournalData(8, &h, es), nil
+}
+
+// v8 -> v9
+
+func migrateV8ToV9(data []byte, keys *migrationKeys) ([]byte, error) {
+	// Version 9 adds tombstones for permanently deleted entries
+	// (see EntryKind_Tombstone), the existing entries don't change,
+	// but the header is authenticated since then (see HeaderMac).
+	_, h, es, err := parseJournalData(data)
+	if err != nil { return nil, err }
+	key, err := keys.masterKey(h)
+	if err != nil { return nil, err }
+	h.Mac, err = HeaderMac(key, h.serializeFields())
+	if err != nil { return nil, err }
+	return assembleJournalData(9, &h, es), nil
+}
+
+func reencryptEntries(es []*EncryptedEntry, journalId [16]byte, from uint8, to uint8, key *memguard.Enclave, priv *memguard.Enclave, publicKey []byte, convert func(string) string) error {
+	// Decrypts all entries as version `from` and encrypts them as version `to`,
+	// the decrypted content is converted using the given function.
+	for _, e := range es {
+		switch e.Kind {
+		case EntryKind_Text, EntryKind_Revision, EntryKind_Index, EntryKind_Trashed, EntryKind_Tombstone:
+			txt, err := e.Decrypt(key, e.AssociatedData(journalId, from))
+			if err != nil { return err }
+			err = e.Encrypt(convert(txt), key, e.AssociatedData(journalId, to))
+			txt = ""
+			if err != nil { return err }
+		case EntryKind_Sealed:
+			if priv == nil { return CorruptedJournalFile }
+			txt, err := e.Open(priv, e.AssociatedData(journalId, from))
+			if err != nil { return err }
+			err = e.Seal(convert(txt), publicKey, e.AssociatedData(journalId, to))
+			txt = ""
+			if err != nil { return err }
+		default:
+			return UnknownEntryKind
+		}
+	}
+	return nil
+}
+
+// legacy encryption (up to version 2)
+
+func legacyEncryptText(password *memguard.Enclave, cleartext string, time uint64) ([]byte, [12]byte, [16]byte, error) {
+	salt := [12]byte{}
+	_, err := rand.Read(salt[:])
+	if err != nil { return nil, salt, [16]byte{}, err }
+	// derive key
+	lb, err := password.Open()
+	defer lb.Destroy()
+	if err != nil { return nil, salt, [16]byte{}, err }
+	key := derive_key(lb.Bytes(), salt[:], DefaultKdfParams)
+	lb.Destroy()
+	ct, noncePfx, err := aeadSeal(key, cleartext, time, nil)
+	return ct, salt, noncePfx, err
+}
+
+func legacyDecryptText(password *memguard.Enclave, ciphertext []byte, salt [12]byte, noncePfx [16]byte, time uint64) (string, error) {
+	// derive key
+	lb, err := password.Open()
+	defer lb.Destroy()
+	if err != nil { return "", err }
+	key := derive_key(lb.Bytes(), salt[:], DefaultKdfParams)
+	lb.Destroy()
+	return aeadOpen(key, ciphertext, noncePfx, time, nil)
+}
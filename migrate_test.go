@@ -0,0 +1,162 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/awnumar/memguard"
+)
+
+func writeV1TestJournal(t *testing.T, file string, passwd *memguard.Enclave, texts ...string) []*EncryptedEntry {
+	// assemble a version 1 journal file
+	e0 := &EncryptedEntry{Timestamp: 0}
+	ct, salt, noncePfx, err := legacyEncryptText(passwd, "reserved", e0.Timestamp)
+	if err != nil { t.Fatal(err) }
+	e0.EncryptedText, e0.Salt, e0.NoncePfx = ct, salt, noncePfx
+	return writeLegacyTestJournal(t, file, 1, passwd, []*EncryptedEntry{e0}, texts...)
+}
+
+func writeV0TestJournal(t *testing.T, file string, passwd *memguard.Enclave, texts ...string) []*EncryptedEntry {
+	// assemble a journal file from before 1.0.0, without the reserved entry 0
+	return writeLegacyTestJournal(t, file, 0, passwd, []*EncryptedEntry{}, texts...)
+}
+
+func writeLegacyTestJournal(t *testing.T, file string, version uint8, passwd *memguard.Enclave, es []*EncryptedEntry, texts ...string) []*EncryptedEntry {
+	n := len(es)
+	for i, txt := range texts {
+		e := &EncryptedEntry{Timestamp: uint64(time.Now().UnixMicro()) + uint64(i)}
+		ct, salt, noncePfx, err := legacyEncryptText(passwd, txt, e.Timestamp)
+		if err != nil { t.Fatal(err) }
+		e.EncryptedText, e.Salt, e.NoncePfx = ct, salt, noncePfx
+		es = append(es, e)
+	}
+	data := append([]byte{version}, SerializeEntries(es, version)...)
+	err := os.WriteFile(file, data, JournalFileMode)
+	if err != nil { t.Fatal(err) }
+	return es[n:]
+}
+
+func TestMigration(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	backup := MigrationBackupPath(JournalTestFile, 1)
+	backupV0 := MigrationBackupPath(JournalTestFile, 0)
+	backupV7 := MigrationBackupPath(JournalTestFile, 7)
+	defer os.Remove(JournalTestFile)
+	defer os.Remove(backup)
+	defer os.Remove(backupV0)
+	defer os.Remove(backupV7)
+	os.Remove(backup)
+	os.Remove(backupV0)
+	os.Remove(backupV7)
+	t.Run("MigrateV1", func(t *testing.T) {
+		es := writeV1TestJournal(t, JournalTestFile, passwd, "first entry", "second entry")
+		original, _ := os.ReadFile(JournalTestFile)
+		b, err := MigrateJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not migrate journal; ", err) }
+		if b != backup { t.Errorf("Expected backup at %v, but got %v", backup, b) }
+		backupData, _ := os.ReadFile(backup)
+		if !slices.Equal(original, backupData) {
+			t.Error("Backup does not match the original file!")
+		}
+		v, err := JournalFileVersion(JournalTestFile)
+		if err != nil || v != JournalFormatVersion {
+			t.Errorf("Expected version %v after migration, but got %v (%v)", JournalFormatVersion, v, err)
+		}
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open migrated journal; ", err) }
+		defer j.Close()
+		if len(j.GetEntries()) != len(es) {
+			t.Errorf("Expected %v entries, but got %v", len(es), len(j.GetEntries()))
+		}
+		txt, err := j.Decrypt(j.GetEntry(es[1].Timestamp))
+		if err != nil || txt != "second entry" {
+			t.Error("Could not decrypt migrated entry; ", err)
+		}
+	})
+	t.Run("AlreadyMigrated", func(t *testing.T) {
+		b, err := MigrateJournalFile(JournalTestFile, passwd)
+		if err != nil || b != "" {
+			t.Errorf("Journal in current version should not be migrated (%v, %v)", b, err)
+		}
+	})
+	t.Run("BackupExists", func(t *testing.T) {
+		writeV1TestJournal(t, JournalTestFile, passwd)
+		_, err := MigrateJournalFile(JournalTestFile, passwd)
+		if err != BackupFileExists {
+			t.Errorf("Expected %v, but got %v", BackupFileExists, err)
+		}
+	})
+	t.Run("MigrateV0", func(t *testing.T) {
+		writeV0TestJournal(t, JournalTestFile, passwd, "old entry")
+		_, err := MigrateJournalFile(JournalTestFile, memguard.NewEnclave([]byte("wrongPassword")))
+		if err != WrongPassword { t.Errorf("Expected %v, but got %v", WrongPassword, err) }
+		es := writeV0TestJournal(t, JournalTestFile, passwd, "old entry", "another old entry")
+		b, err := MigrateJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not migrate journal; ", err) }
+		if b != backupV0 { t.Errorf("Expected backup at %v, but got %v", backupV0, b) }
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open migrated journal; ", err) }
+		defer j.Close()
+		if len(j.GetEntries()) != len(es) {
+			t.Errorf("Expected %v entries, but got %v", len(es), len(j.GetEntries()))
+		}
+		txt, err := j.Decrypt(j.GetEntry(es[0].Timestamp))
+		if err != nil || txt != "old entry" {
+			t.Error("Could not decrypt migrated entry; ", err)
+		}
+	})
+	t.Run("MigrateV7", func(t *testing.T) {
+		// a journal with sealed entries, written as version 7
+		os.Remove(JournalTestFile)
+		CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal(err) }
+		j.EnableSealing()
+		e, _ := j.NewEntryWithMetadata("text entry", EntryMetadata{Title: "title"})
+		j.AddEntry(e)
+		j.Close()
+		wj, err := OpenJournalFileWriteOnly(JournalTestFile)
+		if err != nil { t.Fatal(err) }
+		s, _ := wj.NewEntry("sealed entry")
+		wj.AddEntry(s)
+		wj.Close()
+		j, err = OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal(err) }
+		es := []*EncryptedEntry{}
+		for _, e := range j.entries {
+			es = append(es, &e)
+		}
+		data := assembleJournalData(7, &j.Header, es)
+		j.Close()
+		os.WriteFile(JournalTestFile, data, JournalFileMode)
+		// migrate
+		b, err := MigrateJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not migrate journal; ", err) }
+		if b != backupV7 { t.Errorf("Expected backup at %v, but got %v", backupV7, b) }
+		j, err = OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open migrated journal; ", err) }
+		defer j.Close()
+		if p, err := j.DecryptPayload(j.GetEntry(e.Timestamp)); err != nil || p.Text != "text entry" || p.Title != "title" {
+			t.Errorf("Could not decrypt migrated entry (%v); %v", p, err)
+		}
+		if txt, err := j.Decrypt(j.GetEntry(s.Timestamp)); err != nil || txt != "sealed entry" {
+			t.Errorf("Could not open migrated sealed entry (%v); %v", txt, err)
+		}
+	})
+	t.Run("UnsupportedVersion", func(t *testing.T) {
+		os.WriteFile(JournalTestFile, []byte{JournalFormatVersion + 1}, JournalFileMode)
+		_, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != UnsupportedJournalVersion {
+			t.Errorf("Expected %v, but got %v", UnsupportedJournalVersion, err)
+		}
+		_, err = MigrateJournalFile(JournalTestFile, passwd)
+		if err != UnsupportedJournalVersion {
+			t.Errorf("Expected %v, but got %v", UnsupportedJournalVersion, err)
+		}
+	})
+}
@@ -0,0 +1,282 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/awnumar/memguard"
+	"golang.org/x/term"
+)
+
+/*
+
+Password sources
+
+By default, passwords are read from the terminal. Instead, they
+can be read from
+
+  --password-fd <n>        a file descriptor, one password per line
+  --password-file <path>   a file, one password per line
+  --password-env <name>    an environment variable (removed after reading)
+  --pinentry <program>     a pinentry program, e.g. pinentry-gnome3
+
+When a new password is needed (e.g. passwd), it is the next line
+of the file descriptor or file. The environment variable only
+holds one password. Pinentry asks for new passwords twice.
+
+The passwords are read into locked memory (memguard) directly,
+except for environment variables, which are Go strings anyway.
+
+*/
+
+var NoMorePasswords = errors.New("The password source has no more passwords!")
+var EmptyPassword = errors.New("The password is empty!")
+var MultiplePasswordSources = errors.New("Only one password source can be used!")
+var PinentryCancelled = errors.New("Cancelled by pinentry.")
+
+const maxPinentryPasswordLength = 4096
+
+type PasswordSource interface {
+	// returns nil if the password is empty
+	ReadPassword(prompt string, newPassword bool) (*memguard.Enclave, error)
+}
+
+// The password source for ReadKey() and ReadNewKey(),
+// nil if passwords are read from the terminal.
+var passwordSource PasswordSource
+
+func sealBuffer(lb *memguard.LockedBuffer) *memguard.Enclave {
+	// seals the buffer without a trailing carriage return,
+	// returns nil if it is empty
+	size := lb.Size()
+	if size > 0 && lb.Bytes()[size-1] == '\r' { size-- }
+	if size == 0 { lb.Destroy(); return nil }
+	if size == lb.Size() { return lb.Seal() }
+	trimmed := memguard.NewBuffer(size)
+	trimmed.Copy(lb.Bytes()[:size])
+	lb.Destroy()
+	return trimmed.Seal()
+}
+
+// file descriptors and files
+
+type readerPasswordSource struct {
+	r io.Reader
+}
+
+func (s *readerPasswordSource) ReadPassword(prompt string, newPassword bool) (*memguard.Enclave, error) {
+	lb, err := memguard.NewBufferFromReaderUntil(s.r, '\n')
+	if err == io.EOF {
+		if lb.Size() == 0 { return nil, NoMorePasswords }
+	} else if err != nil {
+		lb.Destroy()
+		return nil, err
+	}
+	return sealBuffer(lb), nil
+}
+
+// environment variables
+
+type envPasswordSource struct {
+	name string
+	used bool
+}
+
+func (s *envPasswordSource) ReadPassword(prompt string, newPassword bool) (*memguard.Enclave, error) {
+	if s.used { return nil, NoMorePasswords }
+	s.used = true
+	v, found := os.LookupEnv(s.name)
+	if !found { return nil, fmt.Errorf("The environment variable %v is not set!", s.name) }
+	os.Unsetenv(s.name) // don't pass it to child processes
+	b := []byte(v)
+	v = ""
+	return memguard.NewEnclave(b), nil // wipes b
+}
+
+// pinentry (assuan protocol)
+
+type PinentrySource struct {
+	Program string
+}
+
+type pinentryConn struct {
+	in io.WriteCloser
+	out io.Reader
+}
+
+func pinentryEscape(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func (c *pinentryConn) readByte() (byte, error) {
+	// unbuffered, so the password doesn't end up in a buffer
+	b := []byte{0}
+	for {
+		n, err := c.out.Read(b)
+		if n == 1 { return b[0], nil }
+		if err != nil { return 0, err }
+	}
+}
+
+func (c *pinentryConn) readLine() (string, error) {
+	// for lines that don't contain the password
+	line := []byte{}
+	for {
+		b, err := c.readByte()
+		if err != nil { return "", err }
+		if b == '\n' { return string(line), nil }
+		line = append(line, b)
+	}
+}
+
+func (c *pinentryConn) readData(lb *memguard.LockedBuffer, n int) (int, error) {
+	// decodes a data line into the buffer, returns the new length
+	for {
+		b, err := c.readByte()
+		if err != nil { return n, err }
+		if b == '\n' { return n, nil }
+		if b == '%' {
+			hex := [2]byte{}
+			for i := range hex {
+				hex[i], err = c.readByte()
+				if err != nil { return n, err }
+			}
+			v, err := strconv.ParseUint(string(hex[:]), 16, 8)
+			if err != nil { return n, err }
+			b = byte(v)
+		}
+		if n >= lb.Size() { return n, errors.New("The password is too long!") }
+		lb.Bytes()[n] = b
+		n++
+	}
+}
+
+func (c *pinentryConn) command(cmd string, lb *memguard.LockedBuffer) (int, error) {
+	// Sends the command and reads the response,
+	// data is decoded into lb (if not nil), returns its length
+	_, err := io.WriteString(c.in, cmd + "\n")
+	if err != nil { return 0, err }
+	return c.response(lb)
+}
+
+func (c *pinentryConn) response(lb *memguard.LockedBuffer) (int, error) {
+	// reads until OK or ERR
+	n := 0
+	for {
+		b, err := c.readByte()
+		if err != nil { return n, err }
+		if b == 'D' && lb != nil {
+			if _, err = c.readByte(); err != nil { return n, err } // space
+			n, err = c.readData(lb, n)
+			if err != nil { return n, err }
+			continue
+		}
+		line, err := c.readLine()
+		if err != nil { return n, err }
+		line = string(b) + line
+		if line == "OK" || strings.HasPrefix(line, "OK ") { return n, nil }
+		if strings.HasPrefix(line, "ERR ") {
+			if strings.Contains(strings.ToLower(line), "cancel") { return n, PinentryCancelled }
+			return n, errors.New("pinentry: " + line[4:])
+		}
+		// ignore status lines and comments
+	}
+}
+
+func (s *PinentrySource) ReadPassword(prompt string, newPassword bool) (*memguard.Enclave, error) {
+	cmd := exec.Command(s.Program)
+	in, err := cmd.StdinPipe()
+	if err != nil { return nil, err }
+	out, err := cmd.StdoutPipe()
+	if err != nil { return nil, err }
+	err = cmd.Start()
+	if err != nil { return nil, err }
+	defer cmd.Wait()
+	defer in.Close()
+	c := pinentryConn{in, out}
+	// greeting
+	if _, err = c.response(nil); err != nil { return nil, err }
+	// curses-based pinentry programs need the terminal
+	if tty, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%v", terminalFd())); err == nil && term.IsTerminal(terminalFd()) {
+		c.command("OPTION ttyname=" + pinentryEscape(tty), nil)
+		if t := os.Getenv("TERM"); t != "" { c.command("OPTION ttytype=" + pinentryEscape(t), nil) }
+	}
+	for _, command := range []string{"SETTITLE Journal", "SETDESC " + pinentryEscape(prompt), "SETPROMPT Password:"} {
+		if _, err = c.command(command, nil); err != nil { return nil, err }
+	}
+	if newPassword {
+		// older pinentry programs don't support this
+		c.command("SETREPEAT Repeat:", nil)
+		c.command("SETREPEATERROR " + pinentryEscape("The passwords don't match!"), nil)
+	}
+	lb := memguard.NewBuffer(maxPinentryPasswordLength)
+	n, err := c.command("GETPIN", lb)
+	c.command("BYE", nil)
+	if err != nil { lb.Destroy(); return nil, err }
+	if n == 0 { lb.Destroy(); return nil, nil }
+	pw := memguard.NewBuffer(n)
+	pw.Copy(lb.Bytes()[:n])
+	lb.Destroy()
+	return pw.Seal(), nil
+}
+
+// options
+
+const PasswordOptionsUsage = "[--password-fd <n> | --password-file <path> | --password-env <name> | --pinentry <program>]"
+
+type PasswordFlags struct {
+	flags *flag.FlagSet
+	fd *int
+	file *string
+	env *string
+	pinentry *string
+}
+
+func AddPasswordFlags(flags *flag.FlagSet) *PasswordFlags {
+	return &PasswordFlags{
+		flags: flags,
+		fd: flags.Int("password-fd", -1, ""),
+		file: flags.String("password-file", "", ""),
+		env: flags.String("password-env", "", ""),
+		pinentry: flags.String("pinentry", "", ""),
+	}
+}
+
+func (pf *PasswordFlags) Use() error {
+	// sets the password source according to the given flags
+	set := map[string]bool{}
+	pf.flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var sources []PasswordSource
+	if set["password-fd"] {
+		f := os.NewFile(uintptr(*pf.fd), "password-fd")
+		if *pf.fd < 0 || f == nil { return errors.New("Invalid file descriptor!") }
+		sources = append(sources, &readerPasswordSource{f})
+	}
+	if set["password-file"] {
+		f, err := os.Open(*pf.file)
+		if err != nil { return err }
+		defer f.Close()
+		// the file is read at once, the passwords are kept in locked memory
+		lb, err := memguard.NewBufferFromEntireReader(f)
+		if err != nil { return err }
+		sources = append(sources, &readerPasswordSource{lb.Reader()})
+	}
+	if set["password-env"] {
+		sources = append(sources, &envPasswordSource{name: *pf.env})
+	}
+	if set["pinentry"] {
+		if _, err := exec.LookPath(*pf.pinentry); err != nil { return err }
+		sources = append(sources, &PinentrySource{*pf.pinentry})
+	}
+	if len(sources) > 1 { return MultiplePasswordSources }
+	if len(sources) == 1 { passwordSource = sources[0] }
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awnumar/memguard"
+)
+
+func readPasswordString(t *testing.T, s PasswordSource) string {
+	e, err := s.ReadPassword("Password", false)
+	if err != nil { t.Fatal("Could not read password; ", err) }
+	if e == nil { return "" }
+	lb, err := e.Open()
+	if err != nil { t.Fatal(err) }
+	defer lb.Destroy()
+	return string(lb.Bytes())
+}
+
+func TestPasswordSources(t *testing.T) {
+	defer memguard.Purge()
+	defer func() { passwordSource = nil }()
+	t.Run("Reader", func(t *testing.T) {
+		s := &readerPasswordSource{strings.NewReader("first\r\nsecond\n\nlast")}
+		for _, expected := range []string{"first", "second", "", "last"} {
+			if pw := readPasswordString(t, s); pw != expected { t.Errorf("Expected %q, got %q", expected, pw) }
+		}
+		if _, err := s.ReadPassword("Password", false); err != NoMorePasswords { t.Error("Expected NoMorePasswords, got ", err) }
+	})
+	t.Run("Env", func(t *testing.T) {
+		t.Setenv("JOURNAL_TEST_PASSWORD", "secureTestP4ssw0rd!")
+		s := &envPasswordSource{name: "JOURNAL_TEST_PASSWORD"}
+		if pw := readPasswordString(t, s); pw != "secureTestP4ssw0rd!" { t.Error("Unexpected password ", pw) }
+		if _, found := os.LookupEnv("JOURNAL_TEST_PASSWORD"); found { t.Error("The environment variable wasn't removed!") }
+		if _, err := s.ReadPassword("Password", false); err != NoMorePasswords { t.Error("Expected NoMorePasswords, got ", err) }
+	})
+	t.Run("Pinentry", func(t *testing.T) {
+		program := filepath.Join(t.TempDir(), "pinentry")
+		script := "#!/bin/sh\necho 'OK Pleased to meet you'\n" +
+			"while read -r cmd rest; do\n" +
+			"  case \"$cmd\" in\n" +
+			"    GETPIN) echo 'D p%25w%0A1'; echo 'S PIN_REPEATED'; echo OK;;\n" +
+			"    BYE) echo OK; exit 0;;\n" +
+			"    *) echo OK;;\n" +
+			"  esac\n" +
+			"done\n"
+		if err := os.WriteFile(program, []byte(script), 0700); err != nil { t.Fatal(err) }
+		if pw := readPasswordString(t, &PinentrySource{program}); pw != "p%w\n1" { t.Errorf("Unexpected password %q", pw) }
+		cancel := strings.Replace(script, "echo 'D p%25w%0A1'; echo 'S PIN_REPEATED'; echo OK", "echo 'ERR 83886179 Operation cancelled <Pinentry>'", 1)
+		if err := os.WriteFile(program, []byte(cancel), 0700); err != nil { t.Fatal(err) }
+		if _, err := (&PinentrySource{program}).ReadPassword("Password", true); err != PinentryCancelled { t.Error("Expected PinentryCancelled, got ", err) }
+	})
+	t.Run("Flags", func(t *testing.T) {
+		passwordSource = nil
+		flags := NewFlagSet()
+		pf := AddPasswordFlags(flags)
+		flags.Parse([]string{"--password-env", "A", "--password-fd", "0"})
+		if err := pf.Use(); err != MultiplePasswordSources { t.Error("Expected MultiplePasswordSources, got ", err) }
+		if passwordSource != nil { t.Error("Password source was set anyway!") }
+		flags = NewFlagSet()
+		pf = AddPasswordFlags(flags)
+		flags.Parse([]string{})
+		if err := pf.Use(); err != nil || passwordSource != nil { t.Error("Unexpected password source; ", err) }
+		flags = NewFlagSet()
+		pf = AddPasswordFlags(flags)
+		flags.Parse([]string{"--password-env", "A"})
+		if err := pf.Use(); err != nil { t.Fatal(err) }
+		if _, ok := passwordSource.(*envPasswordSource); !ok { t.Error("Unexpected password source ", passwordSource) }
+		file := filepath.Join(t.TempDir(), "passwords")
+		if err := os.WriteFile(file, []byte("first\nsecond\n"), 0600); err != nil { t.Fatal(err) }
+		flags = NewFlagSet()
+		pf = AddPasswordFlags(flags)
+		flags.Parse([]string{"--password-file", file})
+		if err := pf.Use(); err != nil { t.Fatal(err) }
+		os.Remove(file) // the file was read at once
+		if pw := readPasswordString(t, passwordSource); pw != "first" { t.Errorf("Unexpected password %q", pw) }
+		if pw := readPasswordString(t, passwordSource); pw != "second" { t.Errorf("Unexpected password %q", pw) }
+		if _, err := passwordSource.ReadPassword("Password", false); err != NoMorePasswords { t.Error("Expected NoMorePasswords, got ", err) }
+		passwordSource = nil
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"encoding/binary"
+	"errors"
+	"maps"
+	"slices"
+)
+
+/*
+
+Since journal format version 6, the decrypted content of an entry
+is a list of fields, similar to the header fields (see header.go),
+but with a 4-byte field length. Up to version 5, the decrypted
+content was the entry text.
+
+Fields with an unknown type are kept as-is.
+
+*/
+
+var CorruptedEntryPayload = errors.New("The content of the entry is corrupted!")
+
+const (
+	PayloadField_Text = uint8(1)    // utf-8 encoded text
+	PayloadField_Revises = uint8(2) // timestamp of the entry, only for revisions (see EntryKind_Revision)
+	PayloadField_SearchIndex = uint8(3) // only for the search index (see EntryKind_Index)
+	PayloadField_Title = uint8(4)  // utf-8 encoded title
+	PayloadField_Tag = uint8(5)    // utf-8 encoded tag, repeated for each tag
+	PayloadField_Mood = uint8(6)   // mood rating (1 byte)
+	PayloadField_Field = uint8(7)  // key/value field, repeated, see below
+	PayloadField_Trashed = uint8(8) // time of the deletion, only for trashed entries (see EntryKind_Trashed)
+)
+
+// Key/value field:
+// key length (u16), utf-8 encoded key, utf-8 encoded value
+
+type EntryPayload struct {
+	Text string
+	Revises uint64
+	SearchIndex []byte
+	Trashed uint64
+	EntryMetadata // see metadata.go
+	unknownFields []payloadField
+}
+
+type payloadField struct {
+	Type uint8
+	Value []byte
+}
+
+func (p *EntryPayload) Serialize() []byte {
+	fs := []payloadField{}
+	fs = append(fs, payloadField{PayloadField_Text, []byte(p.Text)})
+	if p.Revises != 0 {
+		fs = append(fs, payloadField{PayloadField_Revises, binary.BigEndian.AppendUint64(nil, p.Revises)})
+	}
+	if p.SearchIndex != nil {
+		fs = append(fs, payloadField{PayloadField_SearchIndex, p.SearchIndex})
+	}
+	if p.Title != "" {
+		fs = append(fs, payloadField{PayloadField_Title, []byte(p.Title)})
+	}
+	for _, t := range p.Tags {
+		fs = append(fs, payloadField{PayloadField_Tag, []byte(t)})
+	}
+	if p.Mood != 0 {
+		fs = append(fs, payloadField{PayloadField_Mood, []byte{p.Mood}})
+	}
+	for _, k := range slices.Sorted(maps.Keys(p.Fields)) {
+		v := binary.BigEndian.AppendUint16(nil, uint16(len(k)))
+		v = append(v, k...)
+		v = append(v, p.Fields[k]...)
+		fs = append(fs, payloadField{PayloadField_Field, v})
+	}
+	if p.Trashed != 0 {
+		fs = append(fs, payloadField{PayloadField_Trashed, binary.BigEndian.AppendUint64(nil, p.Trashed)})
+	}
+	fs = append(fs, p.unknownFields...)
+	b := []byte{}
+	for _, f := range fs {
+		b = append(b, f.Type)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(f.Value)))
+		b = append(b, f.Value...)
+	}
+	return b
+}
+
+func DeserializePayload(data []byte) (EntryPayload, error) {
+	p := EntryPayload{}
+	lenD := len(data)
+	o := 0 // offset
+	for o < lenD {
+		if lenD < o + 5 { return p, CorruptedEntryPayload }
+		t := data[o]
+		vLen := int(binary.BigEndian.Uint32(data[o+1:o+5]))
+		if lenD - (o + 5) < vLen { return p, CorruptedEntryPayload }
+		v := data[o+5:o+5+vLen]
+		switch t {
+		case PayloadField_Text:
+			p.Text = string(v)
+		case PayloadField_Revises:
+			if vLen != 8 { return p, CorruptedEntryPayload }
+			p.Revises = binary.BigEndian.Uint64(v)
+		case PayloadField_SearchIndex:
+			p.SearchIndex = v
+		case PayloadField_Title:
+			p.Title = string(v)
+		case PayloadField_Tag:
+			p.Tags = append(p.Tags, string(v))
+		case PayloadField_Mood:
+			if vLen != 1 { return p, CorruptedEntryPayload }
+			p.Mood = v[0]
+		case PayloadField_Field:
+			if vLen < 2 { return p, CorruptedEntryPayload }
+			kLen := int(binary.BigEndian.Uint16(v[:2]))
+			if vLen - 2 < kLen { return p, CorruptedEntryPayload }
+			if p.Fields == nil { p.Fields = map[string]string{} }
+			p.Fields[string(v[2:2+kLen])] = string(v[2+kLen:])
+		case PayloadField_Trashed:
+			if vLen != 8 { return p, CorruptedEntryPayload }
+			p.Trashed = binary.BigEndian.Uint64(v)
+		default:
+			p.unknownFields = append(p.unknownFields, payloadField{t, v})
+		}
+		o += 5 + vLen
+	}
+	return p, nil
+}
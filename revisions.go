@@ -0,0 +1,75 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+/*
+
+Entries can be edited since journal format version 6.
+
+When an entry is edited, the previous content is stored as a new
+entry of the kind EntryKind_Revision, with the time of the edit as
+its timestamp. The timestamp of the edited entry is part of the
+encrypted payload (see PayloadField_Revises), so it doesn't leak
+which entries were edited. The entry itself keeps its timestamp
+and gets the new content.
+
+*/
+
+var EntryNotEditable = errors.New("This entry can't be edited!")
+
+func (j *JournalFile) EditEntry(ts uint64, text string) error {
+	if j.closed { return JournalClosed }
+	if j.readOnly { return JournalReadOnly }
+	if j.writeOnly { return JournalWriteOnly }
+	e := j.GetEntry(ts)
+	if e == nil { return EntryNotFound }
+	if ts == 0 || (e.Kind != EntryKind_Text && e.Kind != EntryKind_Sealed) { return EntryNotEditable }
+	p, err := j.DecryptPayload(e)
+	if err != nil { return err }
+	if p.Text == text { return nil } // nothing changed
+	// keep the previous content as a revision
+	rp := p
+	rp.Revises = ts
+	r := &EncryptedEntry{Timestamp: j.unusedTimestamp(uint64(time.Now().UnixMicro())), Kind: EntryKind_Revision}
+	err = j.encryptPayload(r, &rp)
+	if err != nil { return err }
+	// sealed entries are encrypted with the master key from now on
+	p.Text = text
+	e.Kind = EntryKind_Text
+	err = j.encryptPayload(e, &p)
+	if err != nil { return err }
+	j.entries[r.Timestamp] = *r
+	j.entries[ts] = *e
+	if j.index != nil { j.index.Add(ts, text) }
+	j.needWrite = true
+	return nil
+}
+
+func (j *JournalFile) GetRevisions(ts uint64) ([]uint64, error) {
+	// returns the timestamps of all older revisions of the entry, oldest first
+	if j.closed { return nil, JournalClosed }
+	revs := []uint64{}
+	for rts, r := range j.entries {
+		if r.Kind != EntryKind_Revision { continue }
+		p, err := j.DecryptPayload(&r)
+		if err != nil { return nil, err }
+		if p.Revises == ts {
+			revs = append(revs, rts)
+		}
+	}
+	slices.Sort(revs)
+	return revs, nil
+}
+
+func (j *JournalFile) unusedTimestamp(ts uint64) uint64 {
+	for {
+		if _, exists := j.entries[ts]; !exists { return ts }
+		ts++
+	}
+}
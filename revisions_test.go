@@ -0,0 +1,106 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestRevisions(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+	j, err := OpenJournalFile(JournalTestFile, passwd)
+	if err != nil { t.Fatal("Could not create test journal; ", err) }
+	e, _ := j.NewEntry("first version")
+	j.AddEntry(e)
+	t.Run("Edit", func(t *testing.T) {
+		err := j.EditEntry(e.Timestamp, "second version")
+		if err != nil { t.Fatal("Could not edit entry; ", err) }
+		err = j.EditEntry(e.Timestamp, "third version")
+		if err != nil { t.Fatal("Could not edit entry; ", err) }
+		if j.EditEntry(0, "") != EntryNotEditable { t.Error("Could edit reserved entry 0!") }
+		if len(j.GetEntries()) != 1 { t.Error("Revisions are listed as entries!") }
+		j.Close()
+	})
+	t.Run("Revisions", func(t *testing.T) {
+		j, err = OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open journal; ", err) }
+		txt, err := j.Decrypt(j.GetEntry(e.Timestamp))
+		if err != nil || txt != "third version" { t.Error("Entry was not edited; ", err) }
+		revs, err := j.GetRevisions(e.Timestamp)
+		if err != nil || len(revs) != 2 { t.Fatalf("Expected 2 revisions, got %v (%v)", len(revs), err) }
+		for i, expected := range []string{"first version", "second version"} {
+			txt, err := j.Decrypt(j.GetEntry(revs[i]))
+			if err != nil || txt != expected { t.Errorf("Expected revision %v to be %q, got %q (%v)", i, expected, txt, err) }
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		err := j.DeleteEntry(e.Timestamp)
+		if err != nil { t.Fatal("Could not delete entry; ", err) }
+		if len(j.entries) != 4 { t.Error("Revisions were not kept in the trash!") }
+		err = j.PurgeEntry(e.Timestamp)
+		if err != nil { t.Fatal("Could not purge entry; ", err) }
+		for ts, e := range j.entries {
+			if ts != 0 && e.Kind != EntryKind_Tombstone { t.Error("Revisions were not deleted together with the entry!") }
+		}
+		j.Close()
+	})
+}
+
+func TestDiffLines(t *testing.T) {
+	d := DiffLines("a\nb\nc", "a\nc\nd")
+	expected := []DiffLine{{DiffOp_Equal, "a"}, {DiffOp_Delete, "b"}, {DiffOp_Equal, "c"}, {DiffOp_Insert, "d"}}
+	if !slices.Equal(d, expected) { t.Errorf("Unexpected diff: %v", d) }
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	// compare with the length of the longest common subsequence
+	lcsLen := func(a []string, b []string) int {
+		l := make([]int, len(b)+1)
+		for i := range a {
+			prev := 0
+			for j := range b {
+				cur := l[j+1]
+				if a[i] == b[j] {
+					l[j+1] = prev + 1
+				} else {
+					l[j+1] = max(l[j+1], l[j])
+				}
+				prev = cur
+			}
+		}
+		return l[len(b)]
+	}
+	rnd := rand.New(rand.NewPCG(1, 2))
+	randomText := func(n int) string {
+		ls := []string{}
+		for range n { ls = append(ls, string(rune('a' + rnd.IntN(4)))) }
+		return strings.Join(ls, "\n")
+	}
+	for range 500 {
+		a, b := randomText(rnd.IntN(30)), randomText(rnd.IntN(30))
+		d := DiffLines(a, b)
+		oldLs, newLs, changes := []string{}, []string{}, 0
+		for _, l := range d {
+			if l.Op != DiffOp_Insert { oldLs = append(oldLs, l.Text) }
+			if l.Op != DiffOp_Delete { newLs = append(newLs, l.Text) }
+			if l.Op != DiffOp_Equal { changes++ }
+		}
+		if strings.Join(oldLs, "\n") != a || strings.Join(newLs, "\n") != b {
+			t.Fatalf("Diff of %q and %q doesn't reproduce the texts: %v", a, b, d)
+		}
+		la, lb := strings.Split(a, "\n"), strings.Split(b, "\n")
+		if changes != len(la) + len(lb) - 2 * lcsLen(la, lb) {
+			t.Fatalf("Diff of %q and %q isn't minimal: %v", a, b, d)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"errors"
+	"os"
+)
+
+/*
+
+Sealed entries (since version 4)
+
+Optionally, a journal can have an X25519 key pair. The public
+key is stored in the header as-is, the private key is wrapped
+with the master key. Entries can then be sealed to the public
+key without knowing the password, e.g. from scripts or cron jobs
+(see OpenJournalFileWriteOnly). Reading them still requires the
+password.
+
+*/
+
+var JournalWriteOnly = errors.New("The journal was opened without a password and is write-only!")
+var SealingNotEnabled = errors.New("Sealed entries are not enabled for this journal!")
+var SealingAlreadyEnabled = errors.New("Sealed entries are already enabled for this journal!")
+
+func OpenJournalFileWriteOnly(file string) (*JournalFile, error) {
+	// Opens an existing journal without a password. New entries
+	// are sealed to the public key, existing entries can't be read.
+	j := JournalFile{}
+	j.Filepath = file
+	j.writeOnly = true
+	fileinfo, err := os.Stat(j.Filepath)
+	if err != nil { return &j, err }
+	if fileinfo.IsDir() { return &j, FilepathIsDirectory }
+	j.lock, err = LockJournalFile(file)
+	if err != nil { return &j, err }
+	err = j.read()
+	if err == nil && j.Header.PublicKey == nil { err = SealingNotEnabled }
+	if err != nil {
+		j.lock.Unlock()
+		j.lock = nil
+	}
+	return &j, err
+}
+
+func (j *JournalFile) WriteOnly() bool {
+	return j.writeOnly
+}
+
+func (j *JournalFile) SealingEnabled() bool {
+	return j.Header.PublicKey != nil
+}
+
+func (j *JournalFile) EnableSealing() error {
+	if j.closed { return JournalClosed }
+	if j.readOnly { return JournalReadOnly }
+	if j.writeOnly { return JournalWriteOnly }
+	if j.SealingEnabled() { return SealingAlreadyEnabled }
+	if j.agent != nil { return MasterKeyUnavailable }
+	pub, priv, err := NewKeyPair()
+	if err != nil { return err }
+	wrapped, err := WrapPrivateKey(j.key, priv, privateKeyAssociatedData(j.Header.Id, pub))
+	if err != nil { return err }
+	j.Header.PublicKey = pub
+	j.Header.WrappedPrivateKey = wrapped
+	j.privateKey = priv
+	j.needWrite = true
+	return nil
+}
+
+func (j *JournalFile) DisableSealing() error {
+	// Re-encrypts all sealed entries with the master key
+	// and removes the key pair from the header.
+	if j.closed { return JournalClosed }
+	if j.readOnly { return JournalReadOnly }
+	if j.writeOnly { return JournalWriteOnly }
+	if !j.SealingEnabled() { return SealingNotEnabled }
+	for ts, e := range j.entries {
+		if e.Kind != EntryKind_Sealed { continue }
+		txt, err := j.decryptEntry(&e)
+		if err != nil { return err }
+		e.Kind = EntryKind_Text
+		err = j.encryptEntry(&e, txt)
+		if err != nil { return err }
+		j.entries[ts] = e
+	}
+	j.Header.PublicKey = nil
+	j.Header.WrappedPrivateKey = nil
+	j.privateKey = nil
+	j.needWrite = true
+	return nil
+}
+
+func (j *JournalFile) unlockPrivateKey() error {
+	if !j.SealingEnabled() { return nil }
+	ad := privateKeyAssociatedData(j.Header.Id, j.Header.PublicKey)
+	priv, err := UnwrapPrivateKey(j.key, j.Header.WrappedPrivateKey, ad)
+	if err != nil { return CorruptedJournalHeader }
+	// the public key must belong to the private key,
+	// otherwise new entries might be sealed to someone else
+	if !PublicKeyMatches(priv, j.Header.PublicKey) { return CorruptedJournalHeader }
+	j.privateKey = priv
+	return nil
+}
+
+func privateKeyAssociatedData(journalId [16]byte, publicKey []byte) []byte {
+	return append(journalId[:], publicKey...)
+}
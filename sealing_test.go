@@ -0,0 +1,76 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestSealedEntries(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+	j, err := OpenJournalFile(JournalTestFile, passwd)
+	if err != nil { t.Fatal("Could not create test journal; ", err) }
+	j.Close()
+	t.Run("NotEnabled", func(t *testing.T) {
+		_, err := OpenJournalFileWriteOnly(JournalTestFile)
+		if err != SealingNotEnabled { t.Errorf("Expected %v, but got %v", SealingNotEnabled, err) }
+	})
+	t.Run("Enable", func(t *testing.T) {
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open journal; ", err) }
+		err = j.EnableSealing()
+		if err != nil { t.Fatal("Could not enable sealed entries; ", err) }
+		if j.EnableSealing() != SealingAlreadyEnabled { t.Error("Could enable sealed entries twice!") }
+		j.Close()
+		h, err := ReadJournalHeader(JournalTestFile)
+		if err != nil { t.Fatal("Could not read header; ", err) }
+		if len(h.PublicKey) != PublicKeyLength || h.WrappedPrivateKey == nil {
+			t.Error("Key pair was not written to the header!")
+		}
+	})
+	var ts uint64
+	t.Run("WriteOnly", func(t *testing.T) {
+		j, err := OpenJournalFileWriteOnly(JournalTestFile)
+		if err != nil { t.Fatal("Could not open journal without password; ", err) }
+		e, err := j.NewEntry("sealed entry")
+		if err != nil { t.Fatal("Could not create sealed entry; ", err) }
+		if e.Kind != EntryKind_Sealed { t.Error("Entry was not sealed!") }
+		ts = e.Timestamp
+		err = j.AddEntry(e)
+		if err != nil { t.Fatal(err) }
+		if _, err := j.Decrypt(e); err != JournalWriteOnly {
+			t.Errorf("Expected %v, but got %v", JournalWriteOnly, err)
+		}
+		if j.DeleteEntry(0) != JournalWriteOnly { t.Error("Could delete an entry in write-only mode!") }
+		j.Close()
+	})
+	t.Run("Read", func(t *testing.T) {
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open journal; ", err) }
+		defer j.Close()
+		txt, err := j.Decrypt(j.GetEntry(ts))
+		if err != nil || txt != "sealed entry" { t.Error("Could not open sealed entry; ", err) }
+	})
+	t.Run("Disable", func(t *testing.T) {
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open journal; ", err) }
+		err = j.DisableSealing()
+		if err != nil { t.Fatal("Could not disable sealed entries; ", err) }
+		j.Close()
+		j, err = OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open journal; ", err) }
+		defer j.Close()
+		e := j.GetEntry(ts)
+		if e.Kind != EntryKind_Text { t.Error("Sealed entry was not re-encrypted!") }
+		txt, err := j.Decrypt(e)
+		if err != nil || txt != "sealed entry" { t.Error("Could not decrypt re-encrypted entry; ", err) }
+		if j.SealingEnabled() { t.Error("Key pair was not removed!") }
+	})
+}
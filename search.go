@@ -0,0 +1,175 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+
+Full-text search
+
+The entries are decrypted in parallel and matched against a
+regular expression, which is compiled from the query:
+
+  SearchSubstring   case-insensitive substring
+  SearchRegex       regular expression (Go syntax)
+  SearchWord        case-insensitive whole word
+
+Revisions are not searched. If the search index is enabled (see
+searchindex.go), only the entries that may match a substring or
+word query are decrypted.
+
+*/
+
+const (
+	SearchSubstring = iota
+	SearchRegex
+	SearchWord
+)
+
+const searchMaxSnippets = 3
+const searchSnippetContext = 40 // characters before and after a match
+
+var InvalidSearchQuery = errors.New("Invalid search query!")
+
+type SearchSnippet struct {
+	Before string
+	Match string
+	After string
+}
+
+type SearchHit struct {
+	Timestamp uint64
+	Count int // number of matches
+	Snippets []SearchSnippet
+}
+
+func CompileSearchQuery(query string, mode int) (*regexp.Regexp, error) {
+	if query == "" { return nil, InvalidSearchQuery }
+	switch mode {
+	case SearchSubstring:
+		return regexp.Compile("(?i)" + regexp.QuoteMeta(query))
+	case SearchRegex:
+		return regexp.Compile(query)
+	case SearchWord:
+		// \b only knows ASCII word characters, the word
+		// boundaries are matched explicitly instead
+		return regexp.Compile(`(?i)(?:^|[^\p{L}\p{N}_])(` + regexp.QuoteMeta(query) + `)(?:[^\p{L}\p{N}_]|$)`)
+	}
+	return nil, InvalidSearchQuery
+}
+
+func ParseSearchQuery(input string) (string, int) {
+	// Parses the query entered in the user interface,
+	// "re:" selects a regular expression, "w:" a whole word
+	if q, ok := strings.CutPrefix(input, "re:"); ok { return q, SearchRegex }
+	if q, ok := strings.CutPrefix(input, "w:"); ok { return q, SearchWord }
+	return input, SearchSubstring
+}
+
+func (j *JournalFile) Search(query string, mode int, progress func(done int, total int)) ([]SearchHit, error) {
+	// Returns the matching entries, newest first.
+	// progress is called after each decrypted entry, if not nil.
+	if j.closed { return nil, JournalClosed }
+	if j.writeOnly { return nil, JournalWriteOnly }
+	re, err := CompileSearchQuery(query, mode)
+	if err != nil { return nil, err }
+	tss := j.GetEntries()
+	if j.index != nil && mode != SearchRegex {
+		err = j.updateSearchIndex(nil)
+		if err != nil { return nil, err }
+		if candidates, ok := j.index.Candidates(query); ok { tss = candidates }
+	}
+	hits := []SearchHit{}
+	var mu sync.Mutex
+	err = j.decryptEntries(tss, progress, func(ts uint64, p *EntryPayload) {
+		hit := searchText(p.Text, re, mode, SearchHit{Timestamp: ts})
+		if hit.Count == 0 { return }
+		mu.Lock()
+		hits = append(hits, hit)
+		mu.Unlock()
+	})
+	if err != nil { return nil, err }
+	slices.SortFunc(hits, func(a SearchHit, b SearchHit) int {
+		if a.Timestamp > b.Timestamp { return -1 }
+		if a.Timestamp < b.Timestamp { return 1 }
+		return 0
+	})
+	return hits, nil
+}
+
+func searchText(text string, re *regexp.Regexp, mode int, hit SearchHit) SearchHit {
+	var matches [][]int
+	if mode == SearchWord {
+		matches = findWords(text, re)
+	} else {
+		matches = re.FindAllStringIndex(text, -1)
+	}
+	for _, m := range matches {
+		if m[0] == m[1] { continue } // ignore empty matches
+		hit.Count++
+		if len(hit.Snippets) < searchMaxSnippets {
+			hit.Snippets = append(hit.Snippets, snippet(text, m[0], m[1]))
+		}
+	}
+	return hit
+}
+
+func findWords(text string, re *regexp.Regexp) [][]int {
+	// Returns the positions of the words (submatch 1) matched by a
+	// SearchWord query. The match includes the characters around the
+	// word, so the search continues right after the word, as the next
+	// word may start after the same character.
+	matches := [][]int{}
+	for pos := 0; pos < len(text); {
+		m := re.FindStringSubmatchIndex(text[pos:])
+		if m == nil { break }
+		if pos > 0 && m[2] == 0 {
+			// ^ matched at pos, which is not the start of the text
+			r, _ := utf8.DecodeLastRuneInString(text[:pos])
+			if isWordRune(r) {
+				_, size := utf8.DecodeRuneInString(text[pos:])
+				pos += size
+				continue
+			}
+		}
+		matches = append(matches, []int{pos + m[2], pos + m[3]})
+		pos += max(m[3], 1)
+	}
+	return matches
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_'
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+func snippet(text string, start int, end int) SearchSnippet {
+	// the match with some context, on a single line
+	before := []rune(text[:start])
+	after := []rune(text[end:])
+	s := SearchSnippet{}
+	if len(before) > searchSnippetContext {
+		s.Before = "…" + string(before[len(before)-searchSnippetContext:])
+	} else {
+		s.Before = string(before)
+	}
+	if len(after) > searchSnippetContext {
+		s.After = string(after[:searchSnippetContext]) + "…"
+	} else {
+		s.After = string(after)
+	}
+	s.Before = strings.TrimLeft(whitespace.ReplaceAllString(s.Before, " "), " ")
+	s.Match = whitespace.ReplaceAllString(text[start:end], " ")
+	s.After = strings.TrimRight(whitespace.ReplaceAllString(s.After, " "), " ")
+	return s
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestSearch(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+	j, err := OpenJournalFile(JournalTestFile, passwd)
+	if err != nil { t.Fatal("Could not create test journal; ", err) }
+	defer j.Close()
+	texts := []string{
+		"We went to the Lake today.\nThe water was cold.",
+		"Lakeside cafe, " + strings.Repeat("very ", 20) + "nice cake.",
+		"Nothing special happened.",
+		"Café au lait, café noir. Über den Wolken, überall.",
+	}
+	tss := []uint64{}
+	for _, txt := range texts {
+		e, err := j.NewEntry(txt)
+		if err != nil { t.Fatal("Could not create entry; ", err) }
+		if err = j.AddEntry(e); err != nil { t.Fatal("Could not add entry; ", err) }
+		tss = append(tss, e.Timestamp)
+	}
+	search := func(query string, mode int) []SearchHit {
+		hits, err := j.Search(query, mode, nil)
+		if err != nil { t.Fatal("Could not search; ", err) }
+		return hits
+	}
+	t.Run("Substring", func(t *testing.T) {
+		hits := search("lake", SearchSubstring)
+		if len(hits) != 2 || hits[0].Timestamp != tss[1] || hits[1].Timestamp != tss[0] {
+			t.Fatalf("Unexpected hits %v", hits)
+		}
+		sn := hits[1].Snippets[0]
+		if sn.Before != "We went to the " || sn.Match != "Lake" || sn.After != " today. The water was cold." {
+			t.Errorf("Unexpected snippet %q", sn)
+		}
+		sn = hits[0].Snippets[0]
+		if !strings.HasSuffix(sn.After, "…") || len([]rune(sn.After)) != searchSnippetContext + 1 {
+			t.Errorf("Snippet was not shortened: %q", sn.After)
+		}
+	})
+	t.Run("Word", func(t *testing.T) {
+		hits := search("lake", SearchWord)
+		if len(hits) != 1 || hits[0].Timestamp != tss[0] { t.Errorf("Unexpected hits %v", hits) }
+		sn := hits[0].Snippets[0]
+		if sn.Before != "We went to the " || sn.Match != "Lake" || sn.After != " today. The water was cold." {
+			t.Errorf("Unexpected snippet %q", sn)
+		}
+		// words with non-ASCII letters
+		hits = search("café", SearchWord)
+		if len(hits) != 1 || hits[0].Timestamp != tss[3] || hits[0].Count != 2 { t.Fatalf("Unexpected hits %v", hits) }
+		if sn := hits[0].Snippets[1]; sn.Before != "Café au lait, " || sn.Match != "café" {
+			t.Errorf("Unexpected snippet %q", sn)
+		}
+		hits = search("über", SearchWord)
+		if len(hits) != 1 || hits[0].Count != 1 || hits[0].Snippets[0].Match != "Über" { t.Errorf("Unexpected hits %v", hits) }
+		if hits = search("caf", SearchWord); len(hits) != 0 { t.Errorf("Unexpected hits %v", hits) }
+		// adjacent words
+		re, _ := CompileSearchQuery("über", SearchWord)
+		if hit := searchText("über über,über _über", re, SearchWord, SearchHit{}); hit.Count != 3 {
+			t.Errorf("Expected 3 matches, but got %v", hit.Count)
+		}
+	})
+	t.Run("Regex", func(t *testing.T) {
+		hits := search(`(very ){3}`, SearchRegex)
+		if len(hits) != 1 || hits[0].Count != 6 || len(hits[0].Snippets) != searchMaxSnippets {
+			t.Errorf("Unexpected hits %v", hits)
+		}
+		if _, err := CompileSearchQuery("(", SearchRegex); err == nil { t.Error("Invalid regex was accepted!") }
+		if _, err := CompileSearchQuery("", SearchSubstring); err != InvalidSearchQuery { t.Error("Empty query was accepted!") }
+	})
+	t.Run("ParseSearchQuery", func(t *testing.T) {
+		q, mode := ParseSearchQuery("re:^a")
+		if q != "^a" || mode != SearchRegex { t.Error("Regex prefix was not parsed!") }
+		q, mode = ParseSearchQuery("w:lake")
+		if q != "lake" || mode != SearchWord { t.Error("Word prefix was not parsed!") }
+	})
+	t.Run("Revisions", func(t *testing.T) {
+		err := j.EditEntry(tss[2], "Something happened.")
+		if err != nil { t.Fatal("Could not edit entry; ", err) }
+		if len(search("nothing", SearchSubstring)) != 0 { t.Error("Revisions were searched!") }
+	})
+}
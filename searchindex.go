@@ -0,0 +1,272 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"encoding/binary"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+/*
+
+The optional search index (since journal format version 7)
+
+The index maps every word (lower case) to the timestamps of the
+entries containing it. It is stored in the payload of the reserved
+entry 1 (EntryKind_Index), encrypted with the master key like every
+other entry, and kept up to date when entries are added, edited or
+deleted. Sealed entries, which are added without the password, are
+indexed the next time the index is used.
+
+The index only narrows down the entries that have to be decrypted,
+the matches are still checked against the decrypted text.
+
+Serialized index:
+
+  number of entries (u32), their timestamps (u64 each),
+  number of words (u32), then for each word:
+  length (u16), word, number of timestamps (u32), timestamps (u64 each)
+
+*/
+
+var SearchIndexAlreadyEnabled = errors.New("The search index is already enabled!")
+var SearchIndexNotEnabled = errors.New("The search index is not enabled!")
+var CorruptedSearchIndex = errors.New("The search index is corrupted!")
+
+const SearchIndexTimestamp = uint64(1) // reserved
+
+var indexWordRegex = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+
+type SearchIndex struct {
+	words map[string][]uint64 // word -> timestamps (sorted)
+	entries map[uint64][]string // timestamp -> words
+	changed bool
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{words: map[string][]uint64{}, entries: map[uint64][]string{}}
+}
+
+func indexWords(text string) []string {
+	// the unique words of the text, in lower case
+	words := []string{}
+	for _, w := range indexWordRegex.FindAllString(strings.ToLower(text), -1) {
+		if len(w) > 0xffff { continue }
+		// the text may be in a locked buffer that is destroyed later
+		words = append(words, strings.Clone(w))
+	}
+	slices.Sort(words)
+	return slices.Compact(words)
+}
+
+func (i *SearchIndex) Add(ts uint64, text string) {
+	i.Remove(ts)
+	words := indexWords(text)
+	i.entries[ts] = words
+	for _, w := range words {
+		tss := i.words[w]
+		pos, _ := slices.BinarySearch(tss, ts)
+		i.words[w] = slices.Insert(tss, pos, ts)
+	}
+	i.changed = true
+}
+
+func (i *SearchIndex) Remove(ts uint64) {
+	words, exists := i.entries[ts]
+	if !exists { return }
+	for _, w := range words {
+		tss := i.words[w]
+		if pos, found := slices.BinarySearch(tss, ts); found {
+			tss = slices.Delete(tss, pos, pos+1)
+		}
+		if len(tss) == 0 {
+			delete(i.words, w)
+		} else {
+			i.words[w] = tss
+		}
+	}
+	delete(i.entries, ts)
+	i.changed = true
+}
+
+func (i *SearchIndex) Contains(ts uint64) bool {
+	_, exists := i.entries[ts]
+	return exists
+}
+
+func (i *SearchIndex) Candidates(query string) ([]uint64, bool) {
+	// Returns the entries that may match the query (as substring or word),
+	// false if the query contains no words, so the index can't be used.
+	tokens := indexWords(query)
+	if len(tokens) == 0 { return nil, false }
+	var candidates []uint64
+	for _, t := range tokens {
+		// the token may be part of a longer word
+		found := []uint64{}
+		for w, tss := range i.words {
+			if strings.Contains(w, t) { found = append(found, tss...) }
+		}
+		slices.Sort(found)
+		found = slices.Compact(found)
+		if candidates == nil {
+			candidates = found
+		} else {
+			candidates = slices.DeleteFunc(candidates, func(ts uint64) bool {
+				_, ok := slices.BinarySearch(found, ts)
+				return !ok
+			})
+		}
+	}
+	return candidates, true
+}
+
+func (i *SearchIndex) Serialize() []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(i.entries)))
+	tss := []uint64{}
+	for ts := range i.entries { tss = append(tss, ts) }
+	slices.Sort(tss)
+	for _, ts := range tss {
+		b = binary.BigEndian.AppendUint64(b, ts)
+	}
+	words := []string{}
+	for w := range i.words { words = append(words, w) }
+	slices.Sort(words)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(words)))
+	for _, w := range words {
+		b = binary.BigEndian.AppendUint16(b, uint16(len(w)))
+		b = append(b, w...)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(i.words[w])))
+		for _, ts := range i.words[w] {
+			b = binary.BigEndian.AppendUint64(b, ts)
+		}
+	}
+	return b
+}
+
+func DeserializeSearchIndex(data []byte) (*SearchIndex, error) {
+	i := NewSearchIndex()
+	o := 0 // offset
+	readUint := func(size int) (uint64, bool) {
+		if len(data) - o < size { return 0, false }
+		v := uint64(0)
+		for _, c := range data[o:o+size] { v = v << 8 | uint64(c) }
+		o += size
+		return v, true
+	}
+	nEntries, ok := readUint(4)
+	if !ok { return nil, CorruptedSearchIndex }
+	for range nEntries {
+		ts, ok := readUint(8)
+		if !ok { return nil, CorruptedSearchIndex }
+		i.entries[ts] = []string{}
+	}
+	nWords, ok := readUint(4)
+	if !ok { return nil, CorruptedSearchIndex }
+	for range nWords {
+		wLen, ok := readUint(2)
+		if !ok || len(data) - o < int(wLen) { return nil, CorruptedSearchIndex }
+		w := string(data[o:o+int(wLen)])
+		o += int(wLen)
+		nTss, ok := readUint(4)
+		if !ok || uint64(len(data) - o) < nTss * 8 { return nil, CorruptedSearchIndex }
+		tss := make([]uint64, 0, nTss)
+		for range nTss {
+			ts, _ := readUint(8)
+			if _, exists := i.entries[ts]; !exists { return nil, CorruptedSearchIndex }
+			i.entries[ts] = append(i.entries[ts], w)
+			tss = append(tss, ts)
+		}
+		if !slices.IsSorted(tss) { return nil, CorruptedSearchIndex }
+		i.words[w] = tss
+	}
+	if o != len(data) { return nil, CorruptedSearchIndex }
+	return i, nil
+}
+
+// journal
+
+func (j *JournalFile) SearchIndexEnabled() bool {
+	if j.index != nil { return true }
+	e := j.GetEntry(SearchIndexTimestamp)
+	return e != nil && e.Kind == EntryKind_Index
+}
+
+func (j *JournalFile) EnableSearchIndex(progress func(done int, total int)) error {
+	if j.closed { return JournalClosed }
+	if j.readOnly { return JournalReadOnly }
+	if j.writeOnly { return JournalWriteOnly }
+	if j.SearchIndexEnabled() { return SearchIndexAlreadyEnabled }
+	j.index = NewSearchIndex()
+	err := j.updateSearchIndex(progress)
+	if err != nil {
+		j.index = nil
+		return err
+	}
+	j.needWrite = true
+	return nil
+}
+
+func (j *JournalFile) DisableSearchIndex() error {
+	if j.closed { return JournalClosed }
+	if j.readOnly { return JournalReadOnly }
+	if j.writeOnly { return JournalWriteOnly }
+	if !j.SearchIndexEnabled() { return SearchIndexNotEnabled }
+	j.index = nil
+	delete(j.entries, SearchIndexTimestamp)
+	j.needWrite = true
+	return nil
+}
+
+func (j *JournalFile) loadSearchIndex() error {
+	// decrypts the index, if enabled
+	e := j.GetEntry(SearchIndexTimestamp)
+	if e == nil { return nil }
+	if e.Kind != EntryKind_Index { return CorruptedJournalFile }
+	p, err := j.DecryptPayload(e)
+	if err != nil { return err }
+	j.index, err = DeserializeSearchIndex(p.SearchIndex)
+	return err
+}
+
+func (j *JournalFile) storeSearchIndex() error {
+	// encrypts the index into the reserved entry, if it was changed
+	if j.index == nil || !j.index.changed { return nil }
+	e := &EncryptedEntry{Timestamp: SearchIndexTimestamp, Kind: EntryKind_Index}
+	err := j.encryptPayload(e, &EntryPayload{SearchIndex: j.index.Serialize()})
+	if err != nil { return err }
+	j.entries[SearchIndexTimestamp] = *e
+	j.index.changed = false
+	j.needWrite = true
+	return nil
+}
+
+func (j *JournalFile) indexEntry(e *EncryptedEntry) error {
+	if j.index == nil { return nil }
+	p, err := j.DecryptPayload(e)
+	if err != nil { return err }
+	j.index.Add(e.Timestamp, p.Text)
+	p.Text = ""
+	return nil
+}
+
+func (j *JournalFile) updateSearchIndex(progress func(done int, total int)) error {
+	// indexes the entries that are missing in the index
+	// and removes entries that don't exist anymore
+	if j.index == nil { return nil }
+	es := j.GetEntries()
+	exists := map[uint64]bool{}
+	for _, ts := range es { exists[ts] = true }
+	for ts := range j.index.entries {
+		if !exists[ts] { j.index.Remove(ts) }
+	}
+	es = slices.DeleteFunc(es, j.index.Contains)
+	for n, ts := range es {
+		err := j.indexEntry(j.GetEntry(ts))
+		if err != nil { return err }
+		if progress != nil { progress(n+1, len(es)) }
+	}
+	return nil
+}
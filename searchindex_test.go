@@ -0,0 +1,99 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestSearchIndex(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	t.Run("Index", func(t *testing.T) {
+		i := NewSearchIndex()
+		i.Add(10, "Über den See, über die Berge")
+		i.Add(20, "Am See")
+		i.Add(30, "Berge")
+		if !slices.Equal(i.words["über"], []uint64{10}) || !slices.Equal(i.words["see"], []uint64{10, 20}) {
+			t.Errorf("Unexpected index %v", i.words)
+		}
+		c, ok := i.Candidates("erg")
+		if !ok || !slices.Equal(c, []uint64{10, 30}) { t.Errorf("Unexpected candidates %v", c) }
+		c, _ = i.Candidates("see berg")
+		if !slices.Equal(c, []uint64{10}) { t.Errorf("Unexpected candidates %v", c) }
+		if _, ok := i.Candidates("?!"); ok { t.Error("Index was used for a query without words!") }
+		i.Remove(10)
+		if _, exists := i.words["über"]; exists || !slices.Equal(i.words["see"], []uint64{20}) {
+			t.Errorf("Entry was not removed from the index %v", i.words)
+		}
+		d, err := DeserializeSearchIndex(i.Serialize())
+		if err != nil { t.Fatal("Could not deserialize index; ", err) }
+		if !slices.Equal(d.words["berge"], []uint64{30}) || len(d.entries) != 2 {
+			t.Error("Deserialized index doesn't match!")
+		}
+		if _, err := DeserializeSearchIndex(i.Serialize()[:10]); err != CorruptedSearchIndex {
+			t.Errorf("Expected %v, but got %v", CorruptedSearchIndex, err)
+		}
+	})
+	CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+	t.Run("Journal", func(t *testing.T) {
+		j, err := OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not open test journal; ", err) }
+		e, _ := j.NewEntry("The secret word is hippopotamus.")
+		j.AddEntry(e)
+		if j.SearchIndexEnabled() { t.Error("Search index should be disabled by default!") }
+		err = j.EnableSearchIndex(nil)
+		if err != nil { t.Fatal("Could not enable search index; ", err) }
+		if j.EnableSearchIndex(nil) != SearchIndexAlreadyEnabled { t.Error("Could enable search index twice!") }
+		e2, _ := j.NewEntry("Another entry.")
+		j.AddEntry(e2)
+		if !j.index.Contains(e2.Timestamp) { t.Error("New entry was not indexed!") }
+		e3, _ := j.NewEntry("Corrupted entry.")
+		e3.EncryptedText[0] ^= 1
+		if j.AddEntry(e3) == nil || j.GetEntry(e3.Timestamp) != nil || j.index.Contains(e3.Timestamp) {
+			t.Error("Could add an entry that can't be indexed!")
+		}
+		if slices.Contains(j.GetEntries(), SearchIndexTimestamp) { t.Error("The search index is listed as an entry!") }
+		err = j.EditEntry(e2.Timestamp, "Another hippo.")
+		if err != nil { t.Fatal("Could not edit entry; ", err) }
+		j.Close()
+		data, _ := os.ReadFile(JournalTestFile)
+		if bytes.Contains(data, []byte("hippo")) { t.Error("The search index was written unencrypted!") }
+		j, err = OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not reopen test journal; ", err) }
+		if j.index == nil || !slices.Equal(j.index.words["hippo"], []uint64{e2.Timestamp}) {
+			t.Fatal("The search index was not loaded!")
+		}
+		hits, err := j.Search("hippo", SearchSubstring, nil)
+		if err != nil || len(hits) != 2 { t.Errorf("Expected 2 hits, got %v (%v)", hits, err) }
+		j.DeleteEntry(e.Timestamp)
+		if j.index.Contains(e.Timestamp) { t.Error("Deleted entry is still indexed!") }
+		j.Close()
+	})
+	t.Run("SealedEntries", func(t *testing.T) {
+		j, _ := OpenJournalFile(JournalTestFile, passwd)
+		j.EnableSealing()
+		j.Close()
+		wj, err := OpenJournalFileWriteOnly(JournalTestFile)
+		if err != nil { t.Fatal("Could not open journal without password; ", err) }
+		e, _ := wj.NewEntry("sealed giraffe")
+		wj.AddEntry(e)
+		wj.Close()
+		j, _ = OpenJournalFile(JournalTestFile, passwd)
+		defer j.Close()
+		hits, err := j.Search("giraffe", SearchWord, nil)
+		if err != nil || len(hits) != 1 { t.Errorf("Sealed entry was not found (%v)", err) }
+		if !j.index.Contains(e.Timestamp) { t.Error("Sealed entry was not indexed!") }
+		err = j.DisableSearchIndex()
+		if err != nil || j.SearchIndexEnabled() || j.GetEntry(SearchIndexTimestamp) != nil {
+			t.Error("Could not disable the search index; ", err)
+		}
+	})
+}
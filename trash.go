@@ -0,0 +1,123 @@
+package main
+
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+/*
+
+Deleted entries are moved to the trash (since journal format version 8).
+
+A deleted entry keeps its timestamp, but becomes an entry of the kind
+EntryKind_Trashed, encrypted with the master key. The time of the
+deletion is part of the encrypted payload (see PayloadField_Trashed),
+so the trash can be purged by age. Trashed entries are not listed,
+searched or exported until they are restored. Older revisions are
+kept until the entry is purged from the trash. Purged entries and
+their revisions are replaced by tombstones (see merge.go).
+
+*/
+
+var EntryNotDeletable = errors.New("This entry can't be deleted!")
+var EntryNotTrashed = errors.New("This entry is not in the trash!")
+
+func (j *JournalFile) DeleteEntry(ts uint64) error {
+	// moves the entry to the trash
+	if j.closed { return JournalClosed }
+	if j.readOnly { return JournalReadOnly }
+	if j.writeOnly { return JournalWriteOnly }
+	e := j.GetEntry(ts)
+	if e == nil { return EntryNotFound }
+	if ts == 0 || (e.Kind != EntryKind_Text && e.Kind != EntryKind_Sealed) { return EntryNotDeletable }
+	p, err := j.DecryptPayload(e)
+	if err != nil { return err }
+	p.Trashed = uint64(time.Now().UnixMicro())
+	e.Kind = EntryKind_Trashed
+	err = j.encryptPayload(e, &p)
+	p.Text = ""
+	if err != nil { return err }
+	j.entries[ts] = *e
+	if j.index != nil { j.index.Remove(ts) }
+	j.needWrite = true
+	return nil
+}
+
+func (j *JournalFile) RestoreEntry(ts uint64) error {
+	// moves the entry out of the trash
+	if j.closed { return JournalClosed }
+	if j.readOnly { return JournalReadOnly }
+	if j.writeOnly { return JournalWriteOnly }
+	e := j.GetEntry(ts)
+	if e == nil { return EntryNotFound }
+	if e.Kind != EntryKind_Trashed { return EntryNotTrashed }
+	p, err := j.DecryptPayload(e)
+	if err != nil { return err }
+	p.Trashed = 0
+	e.Kind = EntryKind_Text
+	err = j.encryptPayload(e, &p)
+	if err != nil { return err }
+	j.entries[ts] = *e
+	if j.index != nil { j.index.Add(ts, p.Text) }
+	p.Text = ""
+	j.needWrite = true
+	return nil
+}
+
+func (j *JournalFile) PurgeEntry(ts uint64) error {
+	// permanently deletes an entry in the trash and its revisions
+	if j.closed { return JournalClosed }
+	if j.readOnly { return JournalReadOnly }
+	if j.writeOnly { return JournalWriteOnly }
+	e := j.GetEntry(ts)
+	if e == nil { return EntryNotFound }
+	if e.Kind != EntryKind_Trashed { return EntryNotTrashed }
+	revs, err := j.GetRevisions(ts)
+	if err != nil { return err }
+	// the tombstones prevent that the entry is restored by a merge
+	for _, t := range append(revs, ts) {
+		tomb := &EncryptedEntry{Timestamp: t, Kind: EntryKind_Tombstone}
+		err = j.encryptPayload(tomb, &EntryPayload{})
+		if err != nil { return err }
+		j.entries[t] = *tomb
+	}
+	j.needWrite = true
+	return nil
+}
+
+func (j *JournalFile) GetTrash() []uint64 {
+	// returns the timestamps of all entries in the trash, oldest first
+	if j.closed { return []uint64{} }
+	es := []uint64{}
+	for ts, e := range j.entries {
+		if e.Kind == EntryKind_Trashed { es = append(es, ts) }
+	}
+	slices.Sort(es)
+	return es
+}
+
+func (j *JournalFile) EmptyTrash(olderThan time.Duration) ([]uint64, error) {
+	// Permanently deletes the entries that were moved to the trash
+	// more than olderThan ago, or all of them if olderThan is 0.
+	// Returns the timestamps of the purged entries.
+	if j.closed { return nil, JournalClosed }
+	if j.readOnly { return nil, JournalReadOnly }
+	if j.writeOnly { return nil, JournalWriteOnly }
+	before := uint64(time.Now().Add(-olderThan).UnixMicro())
+	purged := []uint64{}
+	for _, ts := range j.GetTrash() {
+		if olderThan > 0 {
+			p, err := j.DecryptPayload(j.GetEntry(ts))
+			p.Text = ""
+			if err != nil { return purged, err }
+			if p.Trashed > before { continue }
+		}
+		err := j.PurgeEntry(ts)
+		if err != nil { return purged, err }
+		purged = append(purged, ts)
+	}
+	return purged, nil
+}
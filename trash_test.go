@@ -0,0 +1,61 @@
+// Copyright (c) 2026, Julian Müller (ChaoticByte)
+
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestTrash(t *testing.T) {
+	passwd := memguard.NewEnclave([]byte("secureTestP4ssw0rd!"))
+	defer memguard.Purge()
+	defer os.Remove(JournalTestFile)
+	os.Remove(JournalTestFile)
+	CreateJournalFile(JournalTestFile, passwd, testKdfParams)
+	j, err := OpenJournalFile(JournalTestFile, passwd)
+	if err != nil { t.Fatal("Could not open test journal; ", err) }
+	j.EnableSearchIndex(nil)
+	e1, _ := j.NewEntryWithMetadata("first entry", EntryMetadata{Title: "One"})
+	j.AddEntry(e1)
+	time.Sleep(time.Millisecond)
+	e2, _ := j.NewEntry("second entry")
+	j.AddEntry(e2)
+	t.Run("Delete", func(t *testing.T) {
+		if err := j.DeleteEntry(e1.Timestamp); err != nil { t.Fatal("Could not delete entry; ", err) }
+		if slices.Contains(j.GetEntries(), e1.Timestamp) { t.Error("Deleted entry is still listed!") }
+		if !slices.Equal(j.GetTrash(), []uint64{e1.Timestamp}) { t.Errorf("Unexpected trash %v", j.GetTrash()) }
+		if j.index.Contains(e1.Timestamp) { t.Error("Deleted entry is still indexed!") }
+		if j.DeleteEntry(e1.Timestamp) != EntryNotDeletable { t.Error("Could delete an entry twice!") }
+		if j.RestoreEntry(e2.Timestamp) != EntryNotTrashed { t.Error("Could restore an entry that is not in the trash!") }
+		if j.PurgeEntry(e2.Timestamp) != EntryNotTrashed { t.Error("Could purge an entry that is not in the trash!") }
+		j.Close()
+		j, err = OpenJournalFile(JournalTestFile, passwd)
+		if err != nil { t.Fatal("Could not reopen test journal; ", err) }
+		p, err := j.DecryptPayload(j.GetEntry(e1.Timestamp))
+		if err != nil || p.Text != "first entry" || p.Title != "One" || p.Trashed == 0 { t.Errorf("Unexpected trashed entry %+v (%v)", p, err) }
+	})
+	t.Run("Restore", func(t *testing.T) {
+		if err := j.RestoreEntry(e1.Timestamp); err != nil { t.Fatal("Could not restore entry; ", err) }
+		if !slices.Contains(j.GetEntries(), e1.Timestamp) || len(j.GetTrash()) != 0 { t.Error("Entry was not restored!") }
+		p, err := j.DecryptPayload(j.GetEntry(e1.Timestamp))
+		if err != nil || p.Text != "first entry" || p.Trashed != 0 { t.Errorf("Unexpected restored entry %+v (%v)", p, err) }
+		if !j.index.Contains(e1.Timestamp) { t.Error("Restored entry was not indexed!") }
+	})
+	t.Run("Empty", func(t *testing.T) {
+		j.DeleteEntry(e1.Timestamp)
+		j.DeleteEntry(e2.Timestamp)
+		purged, err := j.EmptyTrash(time.Hour)
+		if err != nil || len(purged) != 0 { t.Errorf("Purged entries that were deleted just now (%v, %v)", purged, err) }
+		purged, err = j.EmptyTrash(0)
+		if err != nil || len(purged) != 2 { t.Errorf("Expected 2 purged entries, got %v (%v)", purged, err) }
+		if j.GetEntry(e1.Timestamp).Kind != EntryKind_Tombstone || len(j.GetTrash()) != 0 { t.Error("The trash was not emptied!") }
+		e, _ := j.NewEntryAt(e1.Timestamp, "new entry", EntryMetadata{})
+		if j.AddEntry(e) != EntryIdAlreadyExists { t.Error("The timestamp of a purged entry was reused!") }
+		j.Close()
+	})
+}
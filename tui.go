@@ -4,8 +4,11 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/signal"
 	"slices"
@@ -26,10 +29,14 @@ Entrypoint() -> mainloop() -> ...
 
 // terminal helpers
 
+// Output of Out(), the scripting subcommands use stderr,
+// so that stdout only contains their results.
+var Output io.Writer = os.Stdout
+
 func Out(stuff ...any) {
 	// write stuff, without spaces between stuff1, stuff2, etc.
 	for _, s := range stuff {
-		fmt.Print(s)
+		fmt.Fprint(Output, s)
 	}
 }
 
@@ -110,6 +117,15 @@ func MultiChoiceOrCommand(choices [][2]string, commands []string, prompt string,
 }
 
 func ReadPass() (*memguard.Enclave, error) {
+	return readPass(false)
+}
+
+func ReadOptionalPass() (*memguard.Enclave, error) {
+	// like ReadPass(), but returns nil if the input is empty
+	return readPass(true)
+}
+
+func readPass(allowEmpty bool) (*memguard.Enclave, error) {
 	// Read a password using term.ReadPassword()
 	// with additional fancy workarounds and shit
 
@@ -118,7 +134,7 @@ func ReadPass() (*memguard.Enclave, error) {
 	Out(AS_SAVE_CUR_POS)
 
 	// Handle SIGINT (+ manual cleanup of term.Readpassword)
-	fd := int(os.Stdout.Fd())
+	fd := terminalFd()
 	s, err := term.GetState(fd); if err != nil { return nil, err }
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
@@ -133,8 +149,8 @@ func ReadPass() (*memguard.Enclave, error) {
 	for {
 		Out(AS_RESTORE_CUR_POS, AS_ERASE_REST_OF_LINE)
 		pw, err := term.ReadPassword(fd); Nl()
-		if err != nil || len(pw) > 0 {
-			encl := memguard.NewEnclave(pw)
+		if err != nil || len(pw) > 0 || allowEmpty {
+			encl := memguard.NewEnclave(pw) // nil if pw is empty
 			pw = nil // empty the plaintext password
 			Nl()
 			return encl, err
@@ -142,6 +158,309 @@ func ReadPass() (*memguard.Enclave, error) {
 	}
 }
 
+func terminalFd() int {
+	// the terminal to read passwords from, usually stdout
+	for _, f := range []*os.File{os.Stdout, os.Stderr, os.Stdin} {
+		if term.IsTerminal(int(f.Fd())) { return int(f.Fd()) }
+	}
+	return int(os.Stdout.Fd())
+}
+
+func ReadNewPass() (*memguard.Enclave, error) {
+	return readNewPass(false)
+}
+
+func readNewPass(allowEmpty bool) (*memguard.Enclave, error) {
+	// Read a new password twice, until both inputs match
+
+	if passwordSource != nil {
+		pw, err := passwordSource.ReadPassword("Please enter the new password.", true)
+		if err == nil && pw == nil && !allowEmpty { return nil, EmptyPassword }
+		return pw, err
+	}
+
+	for {
+		Out("Please enter the new password."); Nl()
+		pw1, err := readPass(allowEmpty)
+		if err != nil { return nil, err }
+		Out("Please repeat the new password."); Nl()
+		pw2, err := readPass(allowEmpty)
+		if err != nil { return nil, err }
+		if pw1 == nil || pw2 == nil {
+			if pw1 == nil && pw2 == nil { return nil, nil }
+		} else {
+			lb1, err := pw1.Open()
+			if err != nil { return nil, err }
+			lb2, err := pw2.Open()
+			if err != nil { lb1.Destroy(); return nil, err }
+			equal := lb1.EqualTo(lb2.Bytes())
+			lb1.Destroy(); lb2.Destroy()
+			if equal { return pw1, nil }
+		}
+		Out(Am(AC_COL_RED_FG), "The passwords don't match!", Am(AC_COL_RESET_FG)); Nnl(2)
+	}
+}
+
+func ReadKey(prompt string, keyfile *memguard.Enclave) (*memguard.Enclave, error) {
+	// Read a password and combine it with the keyfile, if any.
+	// When a keyfile is used, the password is optional.
+
+	if passwordSource != nil {
+		pw, err := passwordSource.ReadPassword(prompt, false)
+		if err != nil { return nil, err }
+		if pw == nil && keyfile == nil { return nil, EmptyPassword }
+		return CompositeKey(pw, keyfile)
+	}
+
+	Out(prompt); Nl()
+	if keyfile == nil { return ReadPass() }
+	Out(Am(AC_SET_DIM), "Leave empty to only use the keyfile.", Am(AC_RESET_DIM)); Nl()
+	pw, err := ReadOptionalPass()
+	if err != nil { return nil, err }
+	return CompositeKey(pw, keyfile)
+}
+
+func ReadNewKey(keyfile *memguard.Enclave) (*memguard.Enclave, error) {
+	// Like ReadKey(), but for a new password
+
+	if keyfile == nil { return ReadNewPass() }
+	Out(Am(AC_SET_DIM), "A keyfile is used, leave empty to only use the keyfile.", Am(AC_RESET_DIM)); Nnl(2)
+	pw, err := readNewPass(true)
+	if err != nil { return nil, err }
+	return CompositeKey(pw, keyfile)
+}
+
+func ReadLines(title string, lines []string, openEditor bool) ([]string, error) {
+	// Read lines until Ctrl+D is hit in an empty line,
+	// the given lines are shown first and can be deleted using dd.
+	// With openEditor, the external editor is opened right away.
+
+	header := func () {
+		Out(Am(AC_COL_GREEN_FG),
+			title,
+			Am(AC_COL_RESET_FG, AC_SET_DIM),
+			"Save it by hitting ", Am(AC_RESET_DIM), "Ctrl+D",
+			Am(AC_SET_DIM), " in an empty line.\n",
+			"You can delete the previous line with ",
+			Am(AC_RESET_DIM), "dd", Am(AC_SET_DIM),
+			" and ", Am(AC_RESET_DIM), "Enter", Am(AC_RESET_DIM), ".")
+		if ExternalEditor() != "" {
+			Out(Am(AC_SET_DIM), "\nType ", Am(AC_RESET_DIM), ":e", Am(AC_SET_DIM),
+				" to continue in your editor (", ExternalEditor(), ").", Am(AC_RESET_DIM))
+		}
+		Nnl(2)
+		for _, l := range lines {
+			Out(l); Nl()
+		}
+	}
+
+	if openEditor {
+		edited, err := EditLinesInExternalEditor(lines)
+		Out(AS_RESET, AS_CUR_HOME)
+		if err != nil {
+			Out(Am(AC_COL_RED_FG), "Couldn't use the external editor: ", err, Am(AC_COL_RESET_FG))
+			Nnl(2)
+		} else {
+			lines = edited
+		}
+	}
+
+	header()
+
+	for {
+		line, err := Readline()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return lines, err
+		}
+		if line == ":e" {
+			edited, err := EditLinesInExternalEditor(lines)
+			if err != nil {
+				Out(Am(AC_COL_RED_FG), "Couldn't use the external editor: ", err, Am(AC_COL_RESET_FG))
+				Nnl(2)
+				continue
+			}
+			lines = edited
+			Out(AS_RESET, AS_CUR_HOME)
+			header()
+		} else if line == "dd" {
+			ll := len(lines)
+			if ll < 1 {
+				lines = []string{}
+			} else {
+				lines = lines[:ll-1]
+			}
+			Out(AS_RESET, AS_CUR_HOME)
+			header()
+		} else {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+func EditText(title string, txt string, openEditor bool) (*memguard.LockedBuffer, bool, error) {
+	// Edit the text using the built-in editor, or ReadLines() if
+	// stdin is not a terminal or openEditor is set.
+	// Returns false if the user cancelled. The text is returned in a
+	// locked buffer, which has to be destroyed after it was encrypted.
+
+	if openEditor || !term.IsTerminal(int(os.Stdin.Fd())) {
+		lines := []string{}
+		if txt != "" { lines = strings.Split(txt, "\n") }
+		lines, err := ReadLines(title + "; ", lines, openEditor)
+		if err != nil { return nil, false, err }
+		return memguard.NewBufferFromBytes([]byte(strings.Join(lines, "\n"))), true, nil
+	}
+
+	initial := []byte(txt)
+	ed := NewEditor(title, initial)
+	defer ed.Destroy()
+	clear(initial)
+	txt = ""
+	for {
+		result, err := ed.Run()
+		if err != nil { return nil, false, err }
+		switch result {
+		case EditorSaved:
+			return ed.TakeText(), true, nil
+		case EditorCancelled:
+			return nil, false, nil
+		case EditorExternal:
+			edited, err := EditLinesInExternalEditor(strings.Split(string(ed.Text()), "\n"))
+			if err == nil {
+				b := []byte(strings.Join(edited, "\n"))
+				ed.SetText(b)
+				clear(b)
+			}
+			edited = nil
+		}
+	}
+}
+
+func ShowMetadata(m *EntryMetadata) {
+	if m.Title != "" { Out(Am(AC_SET_BOLD), m.Title, Am(AC_RESET_BOLD)); Nl() }
+	if len(m.Tags) > 0 {
+		Out(Am(AC_COL_CYAN_FG), "#", strings.Join(m.Tags, " #"), Am(AC_COL_RESET_FG)); Nl()
+	}
+	if m.Mood > 0 {
+		Out(Am(AC_SET_DIM), "mood ", Am(AC_RESET_DIM),
+			strings.Repeat("●", int(m.Mood)), strings.Repeat("○", MaxMood - int(m.Mood))); Nl()
+	}
+	for _, k := range slices.Sorted(maps.Keys(m.Fields)) {
+		Out(Am(AC_SET_DIM), k, ": ", Am(AC_RESET_DIM), m.Fields[k]); Nl()
+	}
+}
+
+func readFilter(current *EntryFilter) (*EntryFilter, []uint64) {
+	// Reads a filter for the listings, returns nil to remove the filter.
+	// The filtered entries are nil, so they are updated.
+	Out(AS_RESET, AS_CUR_HOME)
+	Out(Am(AC_COL_GREEN_FG), "Filter", Am(AC_COL_RESET_FG, AC_SET_DIM),
+		" (leave empty to show all entries)", Am(AC_RESET_DIM)); Nnl(2)
+	Out("tag:<tag>  title:<text>  mood:<n>  mood:>=<n>  mood:<=<n>  <key>=<value>"); Nnl(2)
+	if current != nil { Out(Am(AC_SET_DIM), "current: ", current.String(), Am(AC_RESET_DIM)); Nnl(2) }
+	for {
+		Out(Am(AC_SET_BOLD, AC_COL_BRIGHT_YELLOW_FG), "> ", Am(AC_RESET_BOLD, AC_COL_RESET_FG))
+		input, err := Readline()
+		if err != nil || strings.TrimSpace(input) == "" { return nil, nil }
+		f, err := ParseEntryFilter(input)
+		if err == nil { return &f, nil }
+		Out(Am(AC_COL_RED_FG), err, Am(AC_COL_RESET_FG)); Nl()
+	}
+}
+
+func readEntryTime() (uint64, bool) {
+	// Reads the date and time for a new entry, returns false if cancelled.
+	// If there already is an entry at this time, the next free one can be used.
+	Out(AS_RESET, AS_CUR_HOME)
+	Out(Am(AC_COL_GREEN_FG), "Date and time of the new entry", Am(AC_COL_RESET_FG, AC_SET_DIM),
+		" (leave empty to cancel)", Am(AC_RESET_DIM)); Nnl(2)
+	Out("e.g. yesterday 21:00  monday 9am  3 days ago  2024-01-15 21:00"); Nnl(2)
+	for {
+		Out(Am(AC_SET_BOLD, AC_COL_BRIGHT_YELLOW_FG), "> ", Am(AC_RESET_BOLD, AC_COL_RESET_FG))
+		input, err := Readline()
+		if err != nil || strings.TrimSpace(input) == "" { return 0, false }
+		t, err := ParseEntryTime(input, time.Now())
+		if err != nil {
+			Out(Am(AC_COL_RED_FG), err, Am(AC_COL_RESET_FG)); Nl()
+			continue
+		}
+		ts := uint64(t.UnixMicro())
+		if ts <= SearchIndexTimestamp {
+			Out(Am(AC_COL_RED_FG), ReservedTimestamp, Am(AC_COL_RESET_FG)); Nl()
+			continue
+		}
+		Out(t.Format(EntryTimeFormat)); Nnl(2)
+		if j.GetEntry(ts) != nil {
+			answer := MultiChoiceOrCommand(
+				[][2]string{{"yes", ""}, {"no", ""}},
+				[]string{},
+				"There already exists an entry at this time. Add the new entry right after it?", "")
+			if answer != 0 { return 0, false }
+			ts = j.unusedTimestamp(ts)
+		}
+		return ts, true
+	}
+}
+
+func resolveMergeConflict(c *MergeConflict) int {
+	// asks the user which version of a conflicting entry should be kept
+	describe := func(e *EncryptedEntry) string {
+		if e == nil { return "deleted" }
+		p, err := j.DecryptPayload(e)
+		if err != nil { return "(could not be decrypted)" }
+		first, _, _ := strings.Cut(strings.TrimSpace(p.Text), "\n")
+		if r := []rune(first); len(r) > 60 { first = string(r[:60]) + " ..." }
+		if p.Title != "" { first = p.Title + " – " + first }
+		p = EntryPayload{} // don't keep the plaintext in memory
+		switch e.Kind {
+		case EntryKind_Trashed:
+			first += Am(AC_SET_DIM) + " (in the trash)" + Am(AC_RESET_DIM)
+		case EntryKind_Revision:
+			first += Am(AC_SET_DIM) + " (older revision)" + Am(AC_RESET_DIM)
+		}
+		return first
+	}
+	Out(AS_RESET, AS_CUR_HOME)
+	Out(Am(AC_COL_BRIGHT_GREEN_FG), "This entry was changed here and by another program:", Am(AC_COL_RESET_FG)); Nnl(2)
+	Out(Am(AC_SET_UNDERLINE), time.UnixMicro(int64(c.Timestamp)).Format(EntryTimeFormat), Am(AC_RESET_UNDERLINE)); Nl()
+	choices := [][2]string{{"mine", describe(c.Ours)}, {"theirs", describe(c.Theirs)}}
+	if c.Ours != nil && c.Theirs != nil {
+		both := "Keep both, the other version is added as a new entry"
+		if j.WriteOnly() {
+			both = "Keep both, this version is added as a new entry"
+		} else if c.Theirs.Kind == EntryKind_Trashed || c.Theirs.Kind == EntryKind_Revision {
+			both = "Keep both, the other version is kept as an older revision"
+		}
+		choices = append(choices, [2]string{"both", both})
+	}
+	return MultiChoiceOrCommand(choices, []string{}, "", "")
+}
+
+func EditLinesInExternalEditor(lines []string) ([]string, error) {
+	dir, inMemory := SecureTempDir()
+	if !inMemory {
+		Out(Am(AC_COL_RED_FG), "Warning: ", Am(AC_COL_RESET_FG),
+			"There is no RAM-backed directory for temporary files,\n",
+			"the entry would be written to ", dir, " unencrypted (it is overwritten afterwards).")
+		Nnl(2)
+		answer := MultiChoiceOrCommand(
+			[][2]string{{"yes", ""}, {"no", ""}},
+			[]string{},
+			"Do you want to use the external editor anyway?", "")
+		if answer != 0 { return lines, errors.New("Cancelled.") }
+	}
+	txt := ""
+	if len(lines) > 0 { txt = strings.Join(lines, "\n") + "\n" }
+	txt, err := EditInExternalEditor(txt, dir)
+	if err != nil { return lines, err }
+	edited := strings.Split(strings.TrimRight(txt, "\n"), "\n")
+	txt = ""
+	return edited, nil
+}
+
 //
 
 const (
@@ -150,11 +469,20 @@ const (
 	UiListEntries
 	UiShowEntry
 	UiNewEntry
+	UiEditEntry
+	UiEditMetadata
+	UiRevisions
+	UiSearch
+	UiChangePassword
+	UiKeySlots
+	UiTrash
 )
 
 const EntryTimeFormat = "Monday, 02. January 2006 15:04:05 MST"
 
-func mainloop(passwd *memguard.Enclave) int {
+func mainloop(keyfile *memguard.Enclave, useEditor bool) int {
+	// keyfile is the keyfile used to open the journal, or nil,
+	// useEditor opens new and edited entries in the external editor
 
 	// erase screen and reset screen on exit.
 	Out(AS_ERASE_SCREEN, AS_CUR_HOME)
@@ -178,6 +506,13 @@ func mainloop(passwd *memguard.Enclave) int {
 	selYear := -1
 	selMonth := ""
 	selEntry := uint64(1) // entry 0 is reserved, so use as default.
+	// search results, nil if there was no search yet
+	var searchHits []SearchHit
+	// filter for the listings, filtered is nil if it has to be updated
+	var filter *EntryFilter
+	var filtered []uint64
+	// timestamp of the next new entry, 0 for now
+	newEntryAt := uint64(0)
 
 	getHelp := func () string {
 		// returns the help line for the current mode
@@ -192,6 +527,9 @@ func mainloop(passwd *memguard.Enclave) int {
 			addCmd("Enter", "back")
 		}
 		if mode == UiShowEntry {
+			addCmd("edit", "Edit this entry")
+			addCmd("meta", "Edit title, tags, mood and fields")
+			addCmd("history", "Show older revisions")
 			addCmd("delete", "Delete this entry")
 		}
 		if mode == UiShowEntry {
@@ -201,8 +539,26 @@ func mainloop(passwd *memguard.Enclave) int {
 		if mode == UiListYears || mode == UiListMonths || mode == UiListEntries || mode == UiShowEntry {
 			addCmd("l", "Latest entry")
 			addCmd("n", "New Entry")
+			addCmd("b", "New entry at another date and time")
 			addCmd("q", "Exit the program")
 		}
+		if mode == UiListYears || mode == UiListMonths || mode == UiListEntries || mode == UiShowEntry || mode == UiSearch {
+			addCmd("s", "Search")
+		}
+		if mode == UiListYears || mode == UiListMonths || mode == UiListEntries {
+			addCmd("f", "Filter by tags, title, mood or fields")
+		}
+		if mode == UiListYears {
+			addCmd("passwd", "Change the password")
+			addCmd("keys", "Manage key slots")
+			addCmd("trash", "Show deleted entries")
+		}
+		if mode == UiKeySlots {
+			addCmd("add", "Add a password")
+		}
+		if mode == UiTrash {
+			addCmd("empty", "Delete all entries in the trash permanently")
+		}
 		return strings.Join(cmds, "\n")
 	}
 
@@ -217,9 +573,20 @@ func mainloop(passwd *memguard.Enclave) int {
 			return 1
 		}
 
+		filtered = nil // entries may have changed
 		err := j.Write()
-		if err == FileModifiedExternally {
-			Out("The file was modified by another program since the last read/write.")
+		// merge the changes of the other program, the file may
+		// be modified again in the meantime, so try a few times
+		for i := 0; i < 3 && err == FileModifiedExternally; i++ {
+			Out("[Merging ...] ")
+			err = j.Merge(resolveMergeConflict)
+			Out("\r", AS_ERASE_LINE)
+			if err == nil { err = j.Write() }
+		}
+		if err == FileModifiedExternally || err == JournalReplaced || err == JournalHeaderConflict || err == MergeConflictWriteOnly {
+			Out("The file was modified by another program since the last read/write,")
+			Nl()
+			Out("the changes couldn't be merged: ", err)
 			Nnl(2)
 			Out("[Press Enter when you are ready to overwrite the journal file]")
 			Readline(); Nl()
@@ -255,6 +622,22 @@ func mainloop(passwd *memguard.Enclave) int {
 
 			// collect list of choices based on filtered entries
 			es := j.GetEntries()
+			if filter != nil {
+				if filtered == nil {
+					var err error
+					filtered, err = j.FilterEntries(*filter, func(done int, total int) {
+						Out("\r", AS_ERASE_LINE, fmt.Sprintf("[Filtering ... %v/%v]", done, total))
+					})
+					Out("\r", AS_ERASE_LINE)
+					if err != nil {
+						Out("Couldn't filter the entries!"); Nl()
+						Out(err.Error()); Nnl(2)
+						filter, filtered = nil, nil
+						continue
+					}
+				}
+				es = filtered
+			}
 			if len(es) > 0 {
 				slices.Sort(es)
 				i := 0
@@ -295,9 +678,9 @@ func mainloop(passwd *memguard.Enclave) int {
 			// commands
 			commands := []string{}
 			if mode == UiListYears {
-				commands = []string{"l", "n", "q"}
+				commands = []string{"l", "n", "q", "passwd", "keys", "s", "f", "b", "trash"}
 			} else {
-				commands = []string{"", "l", "n", "q"}
+				commands = []string{"", "l", "n", "q", "s", "f", "b"}
 			}
 
 			// prompt
@@ -320,6 +703,13 @@ func mainloop(passwd *memguard.Enclave) int {
 				case UiListEntries:
 					prompt = "Entries (There are no entries yet)"
 				}
+				if filter != nil { prompt = "No entries match the filter" }
+			}
+			if filter != nil {
+				prompt += Am(AC_COL_RESET_FG, AC_SET_DIM) + " (filter: " + filter.String() + ")" + Am(AC_RESET_DIM)
+			}
+			if j.ReadOnly() {
+				prompt += Am(AC_COL_RESET_FG, AC_SET_DIM) + " (read-only)" + Am(AC_RESET_DIM)
 			}
 
 			sel := MultiChoiceOrCommand(
@@ -342,8 +732,23 @@ func mainloop(passwd *memguard.Enclave) int {
 					}
 				} else if sel == -2 {
 					mode = UiNewEntry
-				} else if sel < -2 {
+				} else if sel == -3 {
 					return 0 // exit
+				} else if sel == -4 {
+					mode = UiChangePassword
+				} else if sel == -5 {
+					mode = UiKeySlots
+				} else if sel == -6 {
+					searchHits = nil
+					mode = UiSearch
+				} else if sel == -7 {
+					filter, filtered = readFilter(filter)
+					mode = UiListYears
+				} else if sel == -8 {
+					var ok bool
+					if newEntryAt, ok = readEntryTime(); ok { mode = UiNewEntry }
+				} else if sel == -9 {
+					mode = UiTrash
 				} else {
 					selYear = years[sel]
 					mode = UiListMonths
@@ -364,8 +769,17 @@ func mainloop(passwd *memguard.Enclave) int {
 				} else if sel == -3 {
 					mode = UiNewEntry
 					continue
-				} else if sel < -3 {
+				} else if sel == -4 {
 					return 0 // exit
+				} else if sel == -5 {
+					searchHits = nil
+					mode = UiSearch
+				} else if sel == -6 {
+					filter, filtered = readFilter(filter)
+					mode = UiListYears
+				} else if sel == -7 {
+					var ok bool
+					if newEntryAt, ok = readEntryTime(); ok { mode = UiNewEntry }
 				} else {
 					if mode == UiListMonths {
 						selMonth = months[sel]
@@ -384,7 +798,7 @@ func mainloop(passwd *memguard.Enclave) int {
 			e := j.GetEntry(selEntry)
 			if e != nil {
 				Out("[Decrypting ...] ")
-				txt, err := e.Decrypt(passwd)
+				p, err := j.DecryptPayload(e)
 				Out("\r", AS_ERASE_LINE)
 				if err != nil {
 					Out("Entry could not be decrypted!"); Nl()
@@ -394,8 +808,10 @@ func mainloop(passwd *memguard.Enclave) int {
 					Out(Am(AC_SET_UNDERLINE),
 						time.UnixMicro(int64(e.Timestamp)).Format(EntryTimeFormat),
 						Am(AC_RESET_UNDERLINE))
-					Nnl(4); Out(txt); Nnl(3)
-					txt = "" // don't keep the plaintext in memory
+					Nnl(2)
+					ShowMetadata(&p.EntryMetadata)
+					Nnl(2); Out(p.Text); Nnl(3)
+					p = EntryPayload{} // don't keep the plaintext in memory
 				}
 			} else {
 				// this will likely never get called
@@ -409,7 +825,7 @@ func mainloop(passwd *memguard.Enclave) int {
 
 			sel := MultiChoiceOrCommand(
 				[][2]string{},
-				[]string{"", "a", "d", "l", "q", "n", "delete"},
+				[]string{"", "a", "d", "l", "q", "n", "delete", "edit", "history", "s", "meta", "b"},
 				"", getHelp())
 
 			switch sel {
@@ -442,15 +858,34 @@ func mainloop(passwd *memguard.Enclave) int {
 				answer := MultiChoiceOrCommand(
 					[][2]string{{"yes", ""}, {"no", ""}},
 					[]string{},
-					"Do you really want to delete this entry?", "")
+					"Do you really want to move this entry to the trash?", "")
 				if answer == 0 {
 					mode = lastMode
-					j.DeleteEntry(selEntry)
+					err := j.DeleteEntry(selEntry)
+					if err != nil {
+						Out("Couldn't delete entry"); Nl()
+						Out(err.Error()); Nnl(2)
+						Out(Am(AC_SET_DIM), "[Press Enter to go back]", Am(AC_RESET_DIM))
+						Readline()
+						continue
+					}
 					statusCode := writeJournalFile()
 					if statusCode >= 0 {
 						return statusCode
 					}
 				}
+			case -8:
+				mode = UiEditEntry
+			case -9:
+				mode = UiRevisions
+			case -10:
+				searchHits = nil
+				mode = UiSearch
+			case -11:
+				mode = UiEditMetadata
+			case -12:
+				var ok bool
+				if newEntryAt, ok = readEntryTime(); ok { mode = UiNewEntry }
 			}
 
 		} else if mode == UiNewEntry {
@@ -465,57 +900,37 @@ func mainloop(passwd *memguard.Enclave) int {
 				mode = lastMode
 			}
 
-			header := func () {
-				Out(Am(AC_COL_GREEN_FG),
-					"Write a new entry; ",
-					Am(AC_COL_RESET_FG, AC_SET_DIM),
-					"Save it by hitting ", Am(AC_RESET_DIM), "Ctrl+D",
-					Am(AC_SET_DIM), " in an empty line.\n",
-					"You can delete the previous line with ",
-					Am(AC_RESET_DIM), "dd", Am(AC_SET_DIM),
-					" and ", Am(AC_RESET_DIM), "Enter", Am(AC_RESET_DIM), ".")
-				Nnl(2)
+			if j.ReadOnly() {
+				handleErr(JournalReadOnly, "Couldn't create new entry")
+				continue
 			}
 
-			header()
+			title := "Write a new entry"
+			ts := newEntryAt
+			newEntryAt = 0
+			if ts > 0 { title += " (" + time.UnixMicro(int64(ts)).Format(EntryTimeFormat) + ")" }
 
-			// read text from stdin (rune by rune)
-			lines := []string{}
-			for {
-				line, err := Readline()
-				if err == io.EOF {
-					break
-				} else if err != nil {
-					handleErr(err, "Couldn't read terminal input")
-				}
-				if line == "dd" {
-					ll := len(lines)
-					if ll < 1 {
-						lines = []string{}
-					} else {
-						lines = lines[:ll-1]
-					}
-					Out(AS_RESET, AS_CUR_HOME)
-					header()
-					for _, l := range lines {
-						Out(l); Nl()
-					}
-				} else {
-					lines = append(lines, line)
-				}
+			lb, saved, err := EditText(title, "", useEditor)
+			if err != nil {
+				handleErr(err, "Couldn't read terminal input")
+				continue
+			}
+			if !saved {
+				mode = lastMode
+				continue
 			}
 
 			// Try to create new EncryptedEntry from the input text
 
-			e, err := NewEncryptedEntry(strings.Trim(strings.Join(lines, "\n"), " \n"), passwd)
+			if ts == 0 { ts = uint64(time.Now().UnixMicro()) }
+			e, err := j.NewEntryAt(ts, strings.Trim(lb.String(), " \n"), EntryMetadata{})
+			// empty input
+			lb.Destroy()
 			if err != nil {
 				handleErr(err, "Error creating new entry")
 				continue
 			}
 
-			// empty input
-			lines = nil
-
 			err = j.AddEntry(e)
 			if err != nil {
 				handleErr(err, "Error adding new entry to journal")
@@ -531,6 +946,504 @@ func mainloop(passwd *memguard.Enclave) int {
 
 			mode = UiShowEntry
 
+		} else if mode == UiEditEntry {
+
+			// Edit an existing entry, the previous text is kept as a revision
+
+			handleErr := func(err error, out ...any) {
+				Out(out...); Nl()
+				Out(err.Error()); Nnl(2)
+				Out(Am(AC_SET_DIM), "[Press Enter to go back]", Am(AC_RESET_DIM))
+				Readline()
+				mode = UiShowEntry
+			}
+
+			if j.ReadOnly() {
+				handleErr(JournalReadOnly, "Couldn't edit entry")
+				continue
+			}
+			e := j.GetEntry(selEntry)
+			if e == nil {
+				handleErr(EntryNotFound, "Couldn't edit entry")
+				continue
+			}
+			txt, err := j.Decrypt(e)
+			if err != nil {
+				handleErr(err, "Entry could not be decrypted!")
+				continue
+			}
+			lb, saved, err := EditText("Edit the entry", txt, useEditor)
+			txt = ""
+			if err != nil {
+				handleErr(err, "Couldn't read terminal input")
+				continue
+			}
+			if !saved {
+				mode = UiShowEntry
+				continue
+			}
+			Out("[Saving ...] ")
+			err = j.EditEntry(selEntry, strings.Trim(lb.String(), " \n"))
+			Out("\r", AS_ERASE_LINE)
+			lb.Destroy()
+			if err != nil {
+				handleErr(err, "Couldn't edit entry")
+				continue
+			}
+
+			// Update journal file
+			statusCode := writeJournalFile()
+			if statusCode >= 0 {
+				return statusCode
+			}
+
+			mode = UiShowEntry
+
+		} else if mode == UiEditMetadata {
+
+			// Edit the metadata of an entry as text
+
+			handleErr := func(err error, out ...any) {
+				Out(out...); Nl()
+				Out(err.Error()); Nnl(2)
+				Out(Am(AC_SET_DIM), "[Press Enter to go back]", Am(AC_RESET_DIM))
+				Readline()
+				mode = UiShowEntry
+			}
+
+			if j.ReadOnly() {
+				handleErr(JournalReadOnly, "Couldn't edit entry")
+				continue
+			}
+			e := j.GetEntry(selEntry)
+			if e == nil {
+				handleErr(EntryNotFound, "Couldn't edit entry")
+				continue
+			}
+			p, err := j.DecryptPayload(e)
+			if err != nil {
+				handleErr(err, "Entry could not be decrypted!")
+				continue
+			}
+			p.Text = ""
+			txt := "# One field per line (key: value), tags are separated by spaces,\n" +
+				fmt.Sprintf("# the mood is rated from 1 to %v. Empty fields are removed.\n", MaxMood) +
+				p.EntryMetadata.String() + "\n"
+			lb, saved, err := EditText("Edit title, tags, mood and fields", txt, useEditor)
+			txt = ""
+			if err != nil {
+				handleErr(err, "Couldn't read terminal input")
+				continue
+			}
+			if !saved {
+				mode = UiShowEntry
+				continue
+			}
+			m, err := ParseMetadata(lb.String())
+			if err != nil {
+				lb.Destroy()
+				handleErr(err, "Couldn't edit metadata")
+				continue
+			}
+			Out("[Saving ...] ")
+			err = j.EditMetadata(selEntry, m)
+			Out("\r", AS_ERASE_LINE)
+			m = EntryMetadata{}
+			lb.Destroy()
+			if err != nil {
+				handleErr(err, "Couldn't edit metadata")
+				continue
+			}
+
+			// Update journal file
+			statusCode := writeJournalFile()
+			if statusCode >= 0 {
+				return statusCode
+			}
+
+			mode = UiShowEntry
+
+		} else if mode == UiRevisions {
+
+			// List older revisions of the selected entry
+
+			handleErr := func(err error, out ...any) {
+				Out(out...); Nl()
+				Out(err.Error()); Nnl(2)
+				Out(Am(AC_SET_DIM), "[Press Enter to go back]", Am(AC_RESET_DIM))
+				Readline()
+				mode = UiShowEntry
+			}
+
+			revs, err := j.GetRevisions(selEntry)
+			if err != nil {
+				handleErr(err, "Couldn't read revisions")
+				continue
+			}
+			choices := [][2]string{}
+			for i, ts := range revs {
+				choices = append(choices, [2]string{
+					strconv.Itoa(i+1),
+					"replaced " + time.UnixMicro(int64(ts)).Format(EntryTimeFormat)})
+			}
+			prompt := "Older revisions " + Am(AC_COL_RESET_FG, AC_SET_DIM) + "(select one to compare it to the following revision)" + Am(AC_RESET_DIM)
+			if len(revs) == 0 {
+				prompt = "Older revisions (This entry was never edited)"
+			}
+			sel := MultiChoiceOrCommand(
+				choices,
+				[]string{""},
+				Am(AC_COL_BRIGHT_GREEN_FG) + prompt + Am(AC_COL_RESET_FG),
+				getHelp())
+			if sel < 0 {
+				mode = UiShowEntry
+				continue
+			}
+
+			// show the selected revision compared to the following one
+			next := selEntry
+			if sel < len(revs) - 1 { next = revs[sel+1] }
+			oldTxt, err := j.Decrypt(j.GetEntry(revs[sel]))
+			if err != nil {
+				handleErr(err, "Revision could not be decrypted!")
+				continue
+			}
+			newTxt, err := j.Decrypt(j.GetEntry(next))
+			if err != nil {
+				handleErr(err, "Revision could not be decrypted!")
+				continue
+			}
+			Out(AS_RESET, AS_CUR_HOME)
+			Out(Am(AC_SET_UNDERLINE), "Revision replaced ",
+				time.UnixMicro(int64(revs[sel])).Format(EntryTimeFormat), Am(AC_RESET_UNDERLINE))
+			Nnl(3)
+			for _, l := range DiffLines(oldTxt, newTxt) {
+				switch l.Op {
+				case DiffOp_Equal:
+					Out("  ", l.Text)
+				case DiffOp_Delete:
+					Out(Am(AC_COL_RED_FG), "- ", l.Text, Am(AC_COL_RESET_FG))
+				case DiffOp_Insert:
+					Out(Am(AC_COL_GREEN_FG), "+ ", l.Text, Am(AC_COL_RESET_FG))
+				}
+				Nl()
+			}
+			oldTxt, newTxt = "", ""
+			Nnl(2)
+			Out(Am(AC_SET_DIM), "[Press Enter to go back]", Am(AC_RESET_DIM))
+			Readline()
+
+		} else if mode == UiSearch {
+
+			// Search all entries and list the hits
+
+			handleErr := func(err error, out ...any) {
+				Out(out...); Nl()
+				Out(err.Error()); Nnl(2)
+				Out(Am(AC_SET_DIM), "[Press Enter to go back]", Am(AC_RESET_DIM))
+				Readline()
+				mode = UiListYears
+			}
+
+			if searchHits == nil {
+				Out(Am(AC_COL_GREEN_FG), "Search", Am(AC_COL_RESET_FG, AC_SET_DIM),
+					" (prefix with ", Am(AC_RESET_DIM), "re:", Am(AC_SET_DIM),
+					" for a regular expression or ", Am(AC_RESET_DIM), "w:", Am(AC_SET_DIM),
+					" for a whole word, leave empty to go back)", Am(AC_RESET_DIM))
+				Nnl(2)
+				Out(Am(AC_SET_BOLD, AC_COL_BRIGHT_YELLOW_FG), "> ", Am(AC_RESET_BOLD, AC_COL_RESET_FG))
+				input, err := Readline()
+				if err != nil || input == "" {
+					mode = UiListYears
+					continue
+				}
+				query, queryMode := ParseSearchQuery(input)
+				_, err = CompileSearchQuery(query, queryMode)
+				if err != nil {
+					handleErr(err, "Invalid search query")
+					continue
+				}
+				Nl()
+				searchHits, err = j.Search(query, queryMode, func(done int, total int) {
+					Out("\r", AS_ERASE_LINE, fmt.Sprintf("[Searching ... %v/%v]", done, total))
+				})
+				Out("\r", AS_ERASE_LINE)
+				if err != nil {
+					searchHits = nil
+					handleErr(err, "Couldn't search the journal")
+					continue
+				}
+				Out(AS_RESET, AS_CUR_HOME)
+			}
+
+			// entries may have been deleted in the meantime
+			hits := []SearchHit{}
+			es := j.GetEntries()
+			for _, h := range searchHits {
+				if slices.Contains(es, h.Timestamp) { hits = append(hits, h) }
+			}
+			searchHits = hits
+			choices := [][2]string{}
+			for i, h := range hits {
+				desc := time.UnixMicro(int64(h.Timestamp)).Format(EntryTimeFormat)
+				if h.Count > 1 { desc += Am(AC_SET_DIM) + fmt.Sprintf(" (%v matches)", h.Count) + Am(AC_RESET_DIM) }
+				for _, sn := range h.Snippets {
+					desc += "\n      " + sn.Before + Am(AC_SET_INVERTED) + sn.Match + Am(AC_RESET_INVERTED) + sn.After
+				}
+				choices = append(choices, [2]string{strconv.Itoa(i+1), desc})
+			}
+			prompt := fmt.Sprintf("Found %v entries", len(hits))
+			if len(hits) == 1 { prompt = "Found 1 entry" }
+			if len(hits) == 0 { prompt = "Nothing found" }
+			sel := MultiChoiceOrCommand(
+				choices,
+				[]string{"", "s"},
+				Am(AC_COL_BRIGHT_GREEN_FG) + prompt + Am(AC_COL_RESET_FG),
+				getHelp())
+			if sel == -1 {
+				searchHits = nil
+				mode = UiListYears
+			} else if sel == -2 {
+				searchHits = nil
+			} else {
+				selEntry = hits[sel].Timestamp
+				lastMode = UiSearch
+				mode = UiShowEntry
+			}
+
+		} else if mode == UiChangePassword {
+
+			// Change the password of the journal
+
+			handleErr := func(err error, out ...any) {
+				Out(out...); Nl()
+				Out(err.Error()); Nnl(2)
+				Out(Am(AC_SET_DIM), "[Press Enter to go back]", Am(AC_RESET_DIM))
+				Readline()
+				mode = lastMode
+			}
+
+			Out(Am(AC_COL_GREEN_FG), "Change the password", Am(AC_COL_RESET_FG)); Nnl(2)
+			passwd, err := ReadKey("Please enter the current password.", keyfile)
+			if err != nil {
+				handleErr(err, "Couldn't get password from commandline safely.")
+				continue
+			}
+			slot, err := j.FindKeySlot(passwd)
+			if err != nil {
+				handleErr(err, "Couldn't change the password.")
+				continue
+			}
+			newPasswd, err := ReadNewKey(keyfile)
+			if err != nil {
+				handleErr(err, "Couldn't get password from commandline safely.")
+				continue
+			}
+			Out("[Changing password ...] ")
+			err = j.ChangePassword(slot, newPasswd, j.GetKeySlots()[slot].Kdf)
+			Out("\r", AS_ERASE_LINE)
+			if err != nil {
+				handleErr(err, "Couldn't change the password.")
+				continue
+			}
+
+			// Update journal file
+			statusCode := writeJournalFile()
+			if statusCode >= 0 {
+				return statusCode
+			}
+
+			Out("The password was changed."); Nnl(2)
+			Out(Am(AC_SET_DIM), "[Press Enter to go back]", Am(AC_RESET_DIM))
+			Readline()
+			mode = lastMode
+
+		} else if mode == UiKeySlots {
+
+			// List, add and remove key slots
+
+			handleErr := func(err error, out ...any) {
+				Out(out...); Nl()
+				Out(err.Error()); Nnl(2)
+				Out(Am(AC_SET_DIM), "[Press Enter to go back]", Am(AC_RESET_DIM))
+				Readline()
+			}
+
+			choices := [][2]string{}
+			slots := j.GetKeySlots()
+			for i, slot := range slots {
+				choices = append(choices, [2]string{strconv.Itoa(i), KeySlotDescription(&slot, i == j.UnlockedKeySlot())})
+			}
+
+			sel := MultiChoiceOrCommand(
+				choices,
+				[]string{"", "add"},
+				Am(AC_COL_BRIGHT_GREEN_FG) + "Key slots " + Am(AC_COL_RESET_FG, AC_SET_DIM) + "(select one to remove it)" + Am(AC_RESET_DIM),
+				getHelp())
+
+			if sel == -1 {
+				mode = UiListYears
+			} else if sel == -2 {
+				Out("Path to a keyfile (optional): ")
+				kfPath, _ := Readline()
+				Nl()
+				kf, err := LoadKeyfile(strings.TrimSpace(kfPath))
+				if err != nil {
+					handleErr(err, "Couldn't read keyfile.")
+					continue
+				}
+				newPasswd, err := ReadNewKey(kf)
+				if err != nil {
+					handleErr(err, "Couldn't get password from commandline safely.")
+					continue
+				}
+				Out("Label (optional): ")
+				label, _ := Readline()
+				Nl()
+				Out("[Adding key slot ...] ")
+				_, err = j.AddKeySlot(newPasswd, strings.TrimSpace(label), j.Header.Kdf)
+				Out("\r", AS_ERASE_LINE)
+				if err != nil {
+					handleErr(err, "Couldn't add key slot.")
+					continue
+				}
+				statusCode := writeJournalFile()
+				if statusCode >= 0 {
+					return statusCode
+				}
+			} else {
+				Nl(); Out(AS_ERASE_REST_OF_SCREEN)
+				answer := MultiChoiceOrCommand(
+					[][2]string{{"yes", ""}, {"no", ""}},
+					[]string{},
+					fmt.Sprintf("Do you really want to remove key slot %v?", sel), "")
+				if answer == 0 {
+					err := j.RemoveKeySlot(sel)
+					if err != nil {
+						handleErr(err, "Couldn't remove key slot.")
+						continue
+					}
+					statusCode := writeJournalFile()
+					if statusCode >= 0 {
+						return statusCode
+					}
+				}
+			}
+
+		} else if mode == UiTrash {
+
+			// List the entries in the trash, to restore or delete them permanently
+
+			handleErr := func(err error, out ...any) {
+				Out(out...); Nl()
+				Out(err.Error()); Nnl(2)
+				Out(Am(AC_SET_DIM), "[Press Enter to go back]", Am(AC_RESET_DIM))
+				Readline()
+				mode = UiListYears
+			}
+
+			trash := j.GetTrash()
+			choices := [][2]string{}
+			Out("[Decrypting ...] ")
+			for i, ts := range trash {
+				p, err := j.DecryptPayload(j.GetEntry(ts))
+				p.Text = ""
+				if err != nil {
+					Out("\r", AS_ERASE_LINE)
+					handleErr(err, "Entry could not be decrypted!")
+					break
+				}
+				desc := time.UnixMicro(int64(ts)).Format(EntryTimeFormat)
+				if p.Title != "" { desc += " – " + p.Title }
+				desc += Am(AC_SET_DIM) + " (deleted " + time.UnixMicro(int64(p.Trashed)).Format(EntryTimeFormat) + ")" + Am(AC_RESET_DIM)
+				choices = append(choices, [2]string{strconv.Itoa(i+1), desc})
+			}
+			Out("\r", AS_ERASE_LINE)
+			if mode != UiTrash { continue }
+
+			prompt := "Trash " + Am(AC_COL_RESET_FG, AC_SET_DIM) + "(select an entry to restore it or delete it permanently)" + Am(AC_RESET_DIM)
+			if len(trash) == 0 {
+				prompt = "Trash (There are no deleted entries)"
+			}
+			sel := MultiChoiceOrCommand(
+				choices,
+				[]string{"", "empty"},
+				Am(AC_COL_BRIGHT_GREEN_FG) + prompt + Am(AC_COL_RESET_FG),
+				getHelp())
+
+			if sel == -1 {
+				mode = UiListYears
+				continue
+			} else if sel == -2 {
+				Nl(); Out(AS_ERASE_REST_OF_SCREEN)
+				answer := MultiChoiceOrCommand(
+					[][2]string{{"yes", ""}, {"no", ""}},
+					[]string{},
+					"Do you really want to delete all entries in the trash permanently?", "")
+				if answer == 0 {
+					_, err := j.EmptyTrash(0)
+					if err != nil {
+						handleErr(err, "Couldn't empty the trash")
+						continue
+					}
+					statusCode := writeJournalFile()
+					if statusCode >= 0 {
+						return statusCode
+					}
+				}
+				continue
+			}
+
+			// show the selected entry
+			ts := trash[sel]
+			p, err := j.DecryptPayload(j.GetEntry(ts))
+			if err != nil {
+				handleErr(err, "Entry could not be decrypted!")
+				continue
+			}
+			Out(AS_RESET, AS_CUR_HOME)
+			Out(Am(AC_SET_UNDERLINE), time.UnixMicro(int64(ts)).Format(EntryTimeFormat), Am(AC_RESET_UNDERLINE))
+			Out(Am(AC_SET_DIM), " (deleted ", time.UnixMicro(int64(p.Trashed)).Format(EntryTimeFormat), ")", Am(AC_RESET_DIM))
+			Nnl(2)
+			ShowMetadata(&p.EntryMetadata)
+			Nnl(2); Out(p.Text); Nnl(3)
+			p = EntryPayload{}
+			answer := MultiChoiceOrCommand(
+				[][2]string{{"restore", "Restore this entry"}, {"purge", "Delete this entry permanently"}},
+				[]string{""},
+				"", "Enter " + Am(AC_SET_DIM) + "back" + Am(AC_RESET_DIM))
+			if answer == 0 {
+				err = j.RestoreEntry(ts)
+				if err != nil {
+					handleErr(err, "Couldn't restore entry")
+					continue
+				}
+				statusCode := writeJournalFile()
+				if statusCode >= 0 {
+					return statusCode
+				}
+				selEntry = ts
+				lastMode = UiTrash
+				mode = UiShowEntry
+			} else if answer == 1 {
+				Nl(); Out(AS_ERASE_REST_OF_SCREEN)
+				answer = MultiChoiceOrCommand(
+					[][2]string{{"yes", ""}, {"no", ""}},
+					[]string{},
+					"Do you really want to delete this entry permanently?", "")
+				if answer == 0 {
+					err = j.PurgeEntry(ts)
+					if err != nil {
+						handleErr(err, "Couldn't delete entry")
+						continue
+					}
+					statusCode := writeJournalFile()
+					if statusCode >= 0 {
+						return statusCode
+					}
+				}
+			}
+
 		} else {
 
 			mode = UiListYears
@@ -539,6 +1452,31 @@ func mainloop(passwd *memguard.Enclave) int {
 	}
 }
 
+func MigrateInteractive(file string, passwd *memguard.Enclave) error {
+	// Ask the user if the journal file should be migrated to the current
+	// format version. If the user declines, an error is returned when
+	// the journal can't be used without migrating it.
+	version, err := JournalFileVersion(file)
+	if err != nil { return err }
+	answer := MultiChoiceOrCommand(
+		[][2]string{{"yes", ""}, {"no", ""}},
+		[]string{},
+		fmt.Sprintf("This journal file has an older format (version %v).\n", version) +
+			fmt.Sprintf("Do you want to migrate it to the current format (version %v)?\n", JournalFormatVersion) +
+			"A backup of the original file will be written to " +
+			Am(AC_SET_DIM) + MigrationBackupPath(file, version) + Am(AC_RESET_DIM),
+		"")
+	if answer != 0 {
+		if version < MinReadableJournalVersion { return JournalNeedsMigration }
+		return nil // older versions that can still be read
+	}
+	Out("Migrating journal file ..."); Nnl(2)
+	backup, err := MigrateJournalFile(file, passwd)
+	if err != nil { return err }
+	Out("Done. The original file was saved to ", Am(AC_SET_DIM), backup, Am(AC_RESET_DIM)); Nnl(2)
+	return nil
+}
+
 func PrintVersion() {
 	Out(Am(AC_SET_BOLD), "Journal " + Am(AC_RESET_BOLD, AC_COL_CYAN_FG) + Version + Am(AC_COL_RESET_FG)); Nnl(2)
 }
@@ -548,8 +1486,26 @@ func ShowUsageAndExit(a0 string, code int) {
 	a0Parts := strings.Split(a0, "/")
 	binName := a0Parts[len(a0Parts)-1]
 	Out("Usage: ",
-		binName,
-		" <path>\n\nPositional arguments\n\n\t<path>  Path to the journal file\n\n")
+		binName, " <path>\n",
+		"       ", binName, " <subcommand> [args...]",
+		"\n\nPositional arguments\n\n\t<path>  Path to the journal file\n\n",
+		"Options\n\n\t--keyfile <path>  Use a keyfile instead of, or together with a password\n",
+		"\t--editor          Write entries using $VISUAL or $EDITOR\n",
+		"\nPassword options (also for all subcommands that need a password)\n\n",
+		"\t--password-fd <n>          Read the password(s) from this file descriptor, one per line\n",
+		"\t--password-file <path>     Read the password(s) from this file, one per line\n",
+		"\t--password-env <name>      Read the password from this environment variable (it is unset afterwards)\n",
+		"\t--pinentry <program>       Ask for the password using a pinentry program, e.g. pinentry-curses\n",
+		"\nKdf options (when creating a new journal, also for passwd and keyslots add)\n\n",
+		"\t--kdf-memory <MiB>           Argon2id memory\n",
+		"\t--kdf-time <passes>          Argon2id passes\n",
+		"\t--kdf-threads <n>            Argon2id threads\n",
+		"\t--kdf-calibrate <duration>   Pick the number of passes so that unlocking takes about this long, e.g. 2s\n\n")
+	Out("Subcommands\n\n")
+	for _, sc := range subcommands {
+		Out("\t", sc.Name, " ", sc.Args, "  ", sc.Description, "\n")
+	}
+	Nl()
 	os.Exit(code)
 }
 
@@ -562,9 +1518,32 @@ func Entrypoint() {
 	if len(args) < 2 {
 		ShowUsageAndExit(args[0], 1)
 	}
-	a1 := args[1]
-	if a1 == "-h" || a1 == "--help" {
+	if sc := GetSubcommand(args[1]); sc != nil {
+		memguard.SafeExit(sc.Run(args[2:]))
+	}
+	flags := NewFlagSet()
+	keyfilePath := flags.String("keyfile", "", "")
+	kdfFlags := AddKdfFlags(flags)
+	pwFlags := AddPasswordFlags(flags)
+	useEditor := flags.Bool("editor", false, "")
+	err := ParseFlags(flags, args[1:])
+	if err == flag.ErrHelp {
 		ShowUsageAndExit(args[0], 0)
+	} else if err != nil || flags.NArg() != 1 {
+		ShowUsageAndExit(args[0], 1)
+	}
+	if err := pwFlags.Use(); err != nil {
+		Out("Invalid password options!"); Nl()
+		Out(err); Nl()
+		memguard.SafeExit(1)
+	}
+	a1 := flags.Arg(0)
+
+	keyfile, err := LoadKeyfile(*keyfilePath)
+	if err != nil {
+		Out("Couldn't read keyfile."); Nl()
+		Out(err); Nl()
+		memguard.SafeExit(1)
 	}
 
 	// clear screen and go to top left corner
@@ -572,17 +1551,58 @@ func Entrypoint() {
 
 	PrintVersion()
 
-	Out("Please enter your encryption key."); Nl()
-	passwd, err := ReadPass()
+	// another instance may have opened the journal
+	readOnly := false
+	if l, err := LockJournalFile(a1); err == JournalLocked {
+		Out(Am(AC_COL_RED_FG), "The journal is opened by another process")
+		if pid := JournalLockHolder(a1); pid > 0 { Out(" (PID ", pid, ")") }
+		Out(".", Am(AC_COL_RESET_FG)); Nnl(2)
+		answer := MultiChoiceOrCommand(
+			[][2]string{{"yes", ""}, {"no", ""}},
+			[]string{},
+			"Do you want to open it read-only?", "")
+		if answer != 0 { memguard.SafeExit(1) }
+		readOnly = true
+	} else if err == nil {
+		l.Unlock()
+	}
+
+	passwd, err := ReadKey("Please enter your encryption key.", keyfile)
 	if err != nil || passwd == nil {
 		Out("Couldn't get password from commandline safely."); Nl()
 		Out(err); Nl()
 		memguard.SafeExit(1)
 	}
 
+	if _, err := os.Stat(a1); os.IsNotExist(err) {
+		kdf, err := kdfFlags.Params(DefaultKdfParams)
+		if err == nil {
+			Out("Creating journal file at ", Am(AC_SET_DIM), a1, Am(AC_RESET_DIM), " (", kdf, ") ...")
+			Nnl(2)
+			err = CreateJournalFile(a1, passwd, kdf)
+		}
+		if err != nil {
+			Out(Am(AC_COL_RED_FG), "Couldn't create journal file!", Am(AC_COL_RESET_FG)); Nl()
+			Out(err); Nl()
+			memguard.SafeExit(1)
+		}
+	}
+
 	Out("Opening journal file at ", Am(AC_SET_DIM), a1, Am(AC_RESET_DIM), " ...")
 	Nnl(2);
-	j, err = OpenJournalFile(a1, passwd)
+	if readOnly {
+		j, err = OpenJournalFileReadOnly(a1, passwd)
+	} else {
+		j, err = OpenJournalFile(a1, passwd)
+	}
+	if !readOnly && (err == JournalNeedsMigration || (err == nil && j.Version < JournalFormatVersion)) {
+		if err == nil { j.Close() }
+		j = nil
+		err = MigrateInteractive(a1, passwd)
+		if err == nil {
+			j, err = OpenJournalFile(a1, passwd)
+		}
+	}
 	if err != nil { 
 		Out(Am(AC_COL_RED_FG), "Couldn't open journal file!", Am(AC_COL_RESET_FG))
 		Nl()
@@ -592,5 +1612,5 @@ func Entrypoint() {
 	}
 	defer j.Close()
 
-	memguard.SafeExit(mainloop(passwd))
+	memguard.SafeExit(mainloop(keyfile, *useEditor))
 }